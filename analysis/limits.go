@@ -0,0 +1,232 @@
+package analysis
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/buildbuildio/pebbles/common"
+	"github.com/buildbuildio/pebbles/gqlerrors"
+
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// listSizeArguments are arguments, which limit size of returned list
+var listSizeArguments = []string{"first", "last", "limit"}
+
+// maxListSize caps list size taken from arguments, so single argument can't saturate the cost
+const maxListSize = math.MaxInt32
+
+// Limits configures static analysis of operations. Zero value of any limit means there's no limit.
+type Limits struct {
+	MaxDepth      int
+	MaxAliases    int
+	MaxRootFields int
+	MaxCost       int
+
+	// FieldCosts sets weights of the fields by "Type.field" key, f.e. "Query.users"
+	FieldCosts map[string]int
+	// DefaultListSize is used as a multiplier for list fields without valid first, last or limit argument.
+	// If it's not set, such lists are counted as single object.
+	DefaultListSize int
+}
+
+// Result contains computed values of the operation
+type Result struct {
+	Depth      int
+	Aliases    int
+	RootFields int
+	Cost       int
+}
+
+type analyzer struct {
+	limits    *Limits
+	variables map[string]interface{}
+	aliases   int
+}
+
+// Analyze computes depth, number of aliases and root fields and cost of the operation.
+// Introspection fields are not taken into account.
+func (l *Limits) Analyze(operation *ast.OperationDefinition, variables map[string]interface{}) *Result {
+	a := &analyzer{
+		limits:    l,
+		variables: variables,
+	}
+
+	depth, cost := a.walk(operation.SelectionSet)
+
+	return &Result{
+		Depth:      depth,
+		Aliases:    a.aliases,
+		RootFields: len(fields(operation.SelectionSet)),
+		Cost:       cost,
+	}
+}
+
+// Check analyzes the operation and returns errors for each exceeded limit
+func (l *Limits) Check(operation *ast.OperationDefinition, variables map[string]interface{}) gqlerrors.ErrorList {
+	res := l.Analyze(operation, variables)
+
+	var errs gqlerrors.ErrorList
+	for _, check := range []struct {
+		code  string
+		name  string
+		limit int
+		value int
+	}{
+		{code: gqlerrors.MaxDepthExceededError, name: "depth", limit: l.MaxDepth, value: res.Depth},
+		{code: gqlerrors.MaxAliasesExceededError, name: "number of aliases", limit: l.MaxAliases, value: res.Aliases},
+		{code: gqlerrors.MaxRootFieldsExceededError, name: "number of root fields", limit: l.MaxRootFields, value: res.RootFields},
+		{code: gqlerrors.MaxCostExceededError, name: "cost", limit: l.MaxCost, value: res.Cost},
+	} {
+		if check.limit <= 0 || check.value <= check.limit {
+			continue
+		}
+
+		err := gqlerrors.NewError(check.code, fmt.Errorf("query %s %d exceeds maximum %d", check.name, check.value, check.limit))
+		err.Extensions["limit"] = check.limit
+		err.Extensions["value"] = check.value
+		errs = append(errs, err)
+	}
+
+	return errs
+}
+
+// walk returns depth and cost of the selection set, counting aliases along the way
+func (a *analyzer) walk(selectionSet ast.SelectionSet) (int, int) {
+	var maxDepth, totalCost int
+
+	for _, field := range fields(selectionSet) {
+		if field.Alias != "" && field.Alias != field.Name {
+			a.aliases++
+		}
+
+		depth, cost := a.walk(field.SelectionSet)
+		if depth+1 > maxDepth {
+			maxDepth = depth + 1
+		}
+
+		totalCost = addCost(totalCost, addCost(a.fieldCost(field), mulCost(a.listSize(field), cost)))
+	}
+
+	return maxDepth, totalCost
+}
+
+// fieldCost returns weight of the field. By default objects cost 1 and scalars are free.
+func (a *analyzer) fieldCost(field *ast.Field) int {
+	if field.ObjectDefinition != nil {
+		if cost, ok := a.limits.FieldCosts[field.ObjectDefinition.Name+"."+field.Name]; ok {
+			return cost
+		}
+	}
+
+	if len(field.SelectionSet) > 0 {
+		return 1
+	}
+
+	return 0
+}
+
+// listSize returns expected number of items returned by the field.
+// Negative sizes are ignored, so they can't lower the cost of the operation.
+func (a *analyzer) listSize(field *ast.Field) int {
+	if field.Definition == nil || field.Definition.Type.Elem == nil {
+		return 1
+	}
+
+	for _, name := range listSizeArguments {
+		if arg := field.Arguments.ForName(name); arg != nil {
+			if size, ok := toInt(arg.Value, a.variables); ok && size >= 0 {
+				if size > maxListSize {
+					return maxListSize
+				}
+				return size
+			}
+		}
+	}
+
+	if a.limits.DefaultListSize > 0 {
+		return a.limits.DefaultListSize
+	}
+
+	return 1
+}
+
+// addCost returns a+b, clamped to the range of int, so huge costs can't overflow and pass the limit
+func addCost(a, b int) int {
+	if b > 0 && a > math.MaxInt-b {
+		return math.MaxInt
+	}
+	if b < 0 && a < math.MinInt-b {
+		return math.MinInt
+	}
+
+	return a + b
+}
+
+// mulCost returns size*cost, clamped to the range of int. Size is never negative.
+func mulCost(size, cost int) int {
+	if size == 0 {
+		return 0
+	}
+	if cost > math.MaxInt/size {
+		return math.MaxInt
+	}
+	if cost < math.MinInt/size {
+		return math.MinInt
+	}
+
+	return size * cost
+}
+
+// fields returns fields of the selection set, expanding fragments. Introspection fields are skipped.
+func fields(selectionSet ast.SelectionSet) []*ast.Field {
+	var result []*ast.Field
+
+	for _, selection := range selectionSet {
+		switch selection := selection.(type) {
+		case *ast.Field:
+			if common.IsBuiltinName(selection.Name) {
+				continue
+			}
+			result = append(result, selection)
+		case *ast.InlineFragment:
+			result = append(result, fields(selection.SelectionSet)...)
+		case *ast.FragmentSpread:
+			if selection.Definition != nil {
+				result = append(result, fields(selection.Definition.SelectionSet)...)
+			}
+		}
+	}
+
+	return result
+}
+
+func toInt(value *ast.Value, variables map[string]interface{}) (int, bool) {
+	v, err := value.Value(variables)
+	if err != nil {
+		return 0, false
+	}
+
+	switch v := v.(type) {
+	case int:
+		return v, true
+	case int64:
+		return int(v), true
+	case float64:
+		// conversion of values out of int range is undefined
+		if math.IsNaN(v) || v < math.MinInt || v >= math.MaxInt {
+			return 0, false
+		}
+		return int(v), true
+	case json.Number:
+		i, err := strconv.Atoi(string(v))
+		return i, err == nil
+	case string:
+		i, err := strconv.Atoi(v)
+		return i, err == nil
+	}
+
+	return 0, false
+}
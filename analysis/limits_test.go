@@ -0,0 +1,149 @@
+package analysis
+
+import (
+	"math"
+	"testing"
+
+	"github.com/buildbuildio/pebbles/gqlerrors"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+var testSchema = `
+	type Book {
+		id: ID!
+		title: String!
+		author: User!
+	}
+
+	type User {
+		id: ID!
+		name: String!
+		friends(first: Int): [User!]!
+		books(limit: Int): [Book!]!
+	}
+
+	type Query {
+		me: User
+		users: [User!]!
+	}
+`
+
+func mustLoadOperation(t *testing.T, query string) *ast.OperationDefinition {
+	t.Helper()
+
+	schema := gqlparser.MustLoadSchema(&ast.Source{Name: "fixture", Input: testSchema})
+	doc, err := gqlparser.LoadQuery(schema, query)
+	require.Nil(t, err)
+	require.Len(t, doc.Operations, 1)
+
+	return doc.Operations[0]
+}
+
+func TestAnalyze(t *testing.T) {
+	for _, tc := range []struct {
+		Name      string
+		Query     string
+		Variables map[string]interface{}
+		Limits    Limits
+		Expected  Result
+	}{
+		{
+			Name:     "scalars are free",
+			Query:    `{ me { id name } }`,
+			Expected: Result{Depth: 2, Aliases: 0, RootFields: 1, Cost: 1},
+		},
+		{
+			Name:     "aliases and fragments",
+			Query:    `{ a: me { ...F } b: me { id } } fragment F on User { friends { n: name } }`,
+			Expected: Result{Depth: 3, Aliases: 3, RootFields: 2, Cost: 3},
+		},
+		{
+			Name:     "introspection is skipped",
+			Query:    `{ __schema { types { fields { type { ofType { name } } } } } me { __typename id } }`,
+			Expected: Result{Depth: 2, Aliases: 0, RootFields: 1, Cost: 1},
+		},
+		{
+			Name:  "list multipliers",
+			Query: `query ($n: Int) { me { friends(first: 10) { books(limit: $n) { author { id } } } } }`,
+			Variables: map[string]interface{}{
+				"n": float64(3),
+			},
+			Limits: Limits{FieldCosts: map[string]int{"User.books": 5}},
+			// me(1) + friends(1 + 10 * (books(5) + 3 * author(1)))
+			Expected: Result{Depth: 5, Aliases: 0, RootFields: 1, Cost: 1 + 1 + 10*(5+3*1)},
+		},
+		{
+			Name:   "config costs and default list size",
+			Query:  `{ users { books { id } } }`,
+			Limits: Limits{FieldCosts: map[string]int{"User.books": 2}, DefaultListSize: 10},
+			// users(1 + 10 * books(2))
+			Expected: Result{Depth: 3, Aliases: 0, RootFields: 1, Cost: 1 + 10*2},
+		},
+		{
+			Name:   "negative list size",
+			Query:  `query ($n: Int) { me { friends(first: -5) { books(limit: $n) { id } } } }`,
+			Limits: Limits{DefaultListSize: 10},
+			Variables: map[string]interface{}{
+				"n": float64(-1),
+			},
+			// me(1) + friends(1 + 10 * books(1))
+			Expected: Result{Depth: 4, Aliases: 0, RootFields: 1, Cost: 1 + 1 + 10*1},
+		},
+		{
+			Name:  "huge list size",
+			Query: `query ($n: Int) { me { friends(first: $n) { books(limit: 2) { id } } } }`,
+			Variables: map[string]interface{}{
+				"n": float64(1e300),
+			},
+			Limits: Limits{DefaultListSize: 10},
+			// me(1) + friends(1 + 10 * books(1))
+			Expected: Result{Depth: 4, Aliases: 0, RootFields: 1, Cost: 1 + 1 + 10*1},
+		},
+		{
+			Name:  "list size is capped",
+			Query: `{ me { friends(first: 3037000500) { friends(first: 3037000500) { friends(first: 3037000500) { id } } } } }`,
+			// me(1) + friends(1 + n * friends(1 + n * friends(1)))
+			Expected: Result{Depth: 5, Aliases: 0, RootFields: 1, Cost: 1 + 1 + maxListSize*(1+maxListSize*1)},
+		},
+		{
+			Name:  "cost overflow",
+			Query: `{ me { friends(first: 3037000500) { friends(first: 3037000500) { friends(first: 3037000500) { friends(first: 3037000500) { id } } } } } }`,
+			// cost is saturated instead of wrapping around
+			Expected: Result{Depth: 6, Aliases: 0, RootFields: 1, Cost: math.MaxInt},
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			res := tc.Limits.Analyze(mustLoadOperation(t, tc.Query), tc.Variables)
+			assert.Equal(t, tc.Expected, *res)
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	limits := Limits{
+		MaxDepth:      2,
+		MaxAliases:    1,
+		MaxRootFields: 1,
+		MaxCost:       2,
+	}
+
+	assert.Empty(t, limits.Check(mustLoadOperation(t, `{ me { id } }`), nil))
+
+	errs := limits.Check(mustLoadOperation(t, `{ a: me { friends { id } } b: me { id } }`), nil)
+	require.Len(t, errs, 4)
+
+	assert.Equal(t, gqlerrors.MaxDepthExceededError, errs[0].Extensions["code"])
+	assert.Equal(t, 2, errs[0].Extensions["limit"])
+	assert.Equal(t, 3, errs[0].Extensions["value"])
+	assert.Equal(t, gqlerrors.MaxAliasesExceededError, errs[1].Extensions["code"])
+	assert.Equal(t, gqlerrors.MaxRootFieldsExceededError, errs[2].Extensions["code"])
+	assert.Equal(t, gqlerrors.MaxCostExceededError, errs[3].Extensions["code"])
+
+	errs = (&Limits{MaxCost: 1000}).Check(mustLoadOperation(t, `{ me { friends(first: 3037000500) { friends(first: 3037000500) { friends(first: 3037000500) { id } } } } }`), nil)
+	require.Len(t, errs, 1)
+	assert.Equal(t, gqlerrors.MaxCostExceededError, errs[0].Extensions["code"])
+}
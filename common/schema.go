@@ -5,9 +5,11 @@ import (
 )
 
 const (
-	IDFieldName = "id"
+	IDFieldName  = "id"
+	IDsFieldName = "ids"
 
 	NodeFieldName     = "node"
+	NodesFieldName    = "nodes"
 	NodeInterfaceName = "Node"
 
 	QueryObjectName        = "Query"
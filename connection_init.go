@@ -0,0 +1,69 @@
+package pebbles
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/buildbuildio/pebbles/requests"
+)
+
+// ConnectionInitHandler validates payload of connection_init message sent by subscriptions client, f.e. auth token.
+// Returned context is used for planning and execution of each subscription of the connection,
+// returning an error rejects the connection.
+type ConnectionInitHandler func(ctx context.Context, payload map[string]interface{}) (context.Context, error)
+
+type connectionInitPayloadKey struct{}
+
+// WithConnectionInitHandler sets handler of connection_init payload.
+// Legacy graphql-ws clients are rejected with connection_error message, graphql-transport-ws ones with 4403 close code.
+func WithConnectionInitHandler(handler ConnectionInitHandler) GatewayOption {
+	return func(g *Gateway) {
+		g.connectionInitHandler = handler
+	}
+}
+
+// WithConnectionInitForwarding sets keys of connection_init payload, which are forwarded
+// to services in connection_init message of upstream subscriptions
+func WithConnectionInitForwarding(keys ...string) GatewayOption {
+	return func(g *Gateway) {
+		g.connectionInitForwarding = append(g.connectionInitForwarding, keys...)
+	}
+}
+
+// initConnection handles connection_init message and returns request with context used for subscriptions of the connection
+func (g *Gateway) initConnection(r *http.Request, msg []byte) (*http.Request, error) {
+	var initMsg requests.ClientInitMsg
+	if err := json.Unmarshal(msg, &initMsg); err != nil {
+		return nil, err
+	}
+
+	ctx := r.Context()
+	if g.connectionInitHandler != nil {
+		handlerCtx, err := g.connectionInitHandler(ctx, initMsg.Payload)
+		if err != nil {
+			return nil, err
+		}
+		if handlerCtx != nil {
+			ctx = handlerCtx
+		}
+	}
+
+	forwarded := make(map[string]interface{})
+	for _, key := range g.connectionInitForwarding {
+		if value, ok := initMsg.Payload[key]; ok {
+			forwarded[key] = value
+		}
+	}
+	if len(forwarded) != 0 {
+		ctx = context.WithValue(ctx, connectionInitPayloadKey{}, forwarded)
+	}
+
+	return r.WithContext(ctx), nil
+}
+
+// connectionInitPayloadFromContext returns part of client connection_init payload, which should be forwarded to services
+func connectionInitPayloadFromContext(ctx context.Context) map[string]interface{} {
+	payload, _ := ctx.Value(connectionInitPayloadKey{}).(map[string]interface{})
+	return payload
+}
@@ -0,0 +1,181 @@
+package pebbles
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/buildbuildio/pebbles/planner"
+	"github.com/buildbuildio/pebbles/requests"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+type testUserKey struct{}
+
+func testConnectionInitHandler(ctx context.Context, payload map[string]interface{}) (context.Context, error) {
+	token, _ := payload["token"].(string)
+	if token != "secret" {
+		return nil, errors.New("invalid token")
+	}
+	return context.WithValue(ctx, testUserKey{}, "user"), nil
+}
+
+func writeClientInitMsg(t *testing.T, conn net.Conn, payload map[string]interface{}) {
+	b, err := json.Marshal(requests.ClientInitMsg{Type: requests.SubConnectionInit, Payload: payload})
+	require.NoError(t, err)
+	require.NoError(t, wsutil.WriteClientText(conn, b))
+}
+
+func TestGatewayConnectionInitHandler(t *testing.T) {
+	users := make(chan interface{}, 1)
+
+	mq := mockStreamQueryer{MockQueryer{ResCh: make(chan *requests.Response)}}
+	server := newSubscriptionTestServer(
+		t, mq,
+		WithConnectionInitHandler(testConnectionInitHandler),
+		WithHooks(Hooks{OnOperation: func(ctx *planner.PlanningContext) error {
+			users <- ctx.Request.Context().Value(testUserKey{})
+			return nil
+		}}),
+	)
+	defer server.Close()
+
+	subscribe := requests.ClientSubMsg{
+		ID:      "1",
+		Type:    requests.SubStart,
+		Payload: &requests.Request{Query: "subscription { test }"},
+	}
+
+	t.Run("legacy rejected", func(t *testing.T) {
+		conn, _ := dialSubscriptionServer(t, server, requests.GraphQLWSProtocol)
+		defer conn.Close()
+
+		writeClientInitMsg(t, conn, map[string]interface{}{"token": "wrong"})
+		msg := readServerMsg(t, conn)
+		assert.Equal(t, requests.SubConnectionError, msg["type"])
+		assert.Equal(t, "invalid token", msg["payload"].([]interface{})[0].(map[string]interface{})["message"])
+
+		readCloseCode(t, conn)
+	})
+
+	t.Run("legacy not initialised", func(t *testing.T) {
+		conn, _ := dialSubscriptionServer(t, server, requests.GraphQLWSProtocol)
+		defer conn.Close()
+
+		writeClientMsg(t, conn, subscribe)
+		msg := readServerMsg(t, conn)
+		assert.Equal(t, "1", msg["id"])
+		assert.Equal(t, requests.SubError, msg["type"])
+	})
+
+	t.Run("transport ws rejected", func(t *testing.T) {
+		conn, _ := dialSubscriptionServer(t, server, requests.GraphQLTransportWSProtocol)
+		defer conn.Close()
+
+		writeClientInitMsg(t, conn, nil)
+		assert.EqualValues(t, requests.CloseForbidden, readCloseCode(t, conn))
+	})
+
+	t.Run("accepted", func(t *testing.T) {
+		conn, _ := dialSubscriptionServer(t, server, requests.GraphQLWSProtocol)
+		defer conn.Close()
+
+		writeClientInitMsg(t, conn, map[string]interface{}{"token": "secret"})
+		assert.Equal(t, requests.SubConnectionAck, readServerMsg(t, conn)["type"])
+
+		writeClientMsg(t, conn, subscribe)
+		assert.Equal(t, "user", <-users)
+
+		mq.ResCh <- &requests.Response{Data: map[string]interface{}{"test": "YES"}}
+		assert.Equal(t, requests.SubData, readServerMsg(t, conn)["type"])
+	})
+}
+
+func TestGatewayConnectionInitForwarding(t *testing.T) {
+	initPayloads := make(chan map[string]interface{}, 1)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, _, _, err := ws.HTTPUpgrader{
+			Protocol: func(subprotocol string) bool {
+				return subprotocol == requests.GraphQLWSProtocol
+			},
+		}.Upgrade(r, w)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		for {
+			msg, err := wsutil.ReadClientText(conn)
+			if err != nil {
+				return
+			}
+
+			var subMsg requests.ClientSubMsg
+			require.NoError(t, json.Unmarshal(msg, &subMsg))
+
+			switch subMsg.Type {
+			case requests.SubConnectionInit:
+				var initMsg requests.ClientInitMsg
+				require.NoError(t, json.Unmarshal(msg, &initMsg))
+				initPayloads <- initMsg.Payload
+			case requests.SubStart:
+				b, _ := json.Marshal(requests.ServerSubMsg{
+					ID:      subMsg.ID,
+					Type:    requests.SubData,
+					Payload: &requests.Response{Data: map[string]interface{}{"test": "YES"}},
+				})
+				wsutil.WriteServerText(conn, b)
+			}
+		}
+	}))
+	defer upstream.Close()
+
+	schema := gqlparser.MustLoadSchema(&ast.Source{Name: "fixture", Input: `
+		type Query {
+			test: String!
+		}
+
+		type Subscription {
+			test: String!
+		}
+	`})
+
+	gw, err := NewGateway(
+		[]string{upstream.URL},
+		WithRemoteSchemaIntrospector(&MockRemoteSchemaIntrospector{Res: []*ast.Schema{schema}}),
+		WithConnectionInitHandler(testConnectionInitHandler),
+		WithConnectionInitForwarding("token", "tenant"),
+	)
+	require.NoError(t, err)
+
+	server := httptest.NewServer(http.HandlerFunc(gw.Handler))
+	defer server.Close()
+
+	conn, _ := dialSubscriptionServer(t, server, requests.GraphQLWSProtocol)
+	defer conn.Close()
+
+	writeClientInitMsg(t, conn, map[string]interface{}{"token": "secret", "password": "123"})
+	assert.Equal(t, requests.SubConnectionAck, readServerMsg(t, conn)["type"])
+
+	writeClientMsg(t, conn, requests.ClientSubMsg{
+		ID:      "1",
+		Type:    requests.SubStart,
+		Payload: &requests.Request{Query: "subscription { test }"},
+	})
+
+	msg := readServerMsg(t, conn)
+	assert.Equal(t, requests.SubData, msg["type"])
+	assert.Equal(t, map[string]interface{}{"test": "YES"}, msg["payload"].(map[string]interface{})["data"])
+
+	assert.Equal(t, map[string]interface{}{"token": "secret"}, <-initPayloads)
+}
@@ -1,6 +1,7 @@
 package executor
 
 import (
+	"context"
 	"errors"
 
 	"github.com/buildbuildio/pebbles/common"
@@ -15,9 +16,20 @@ type DepthExecutor struct {
 	QueryPlanSteps     []*planner.QueryPlanStep
 	PointDataExtractor PointDataExtractor
 	Depth              int
+
+	// queryCtx is passed to queryers, so downstream requests are nested under the span of the caller
+	queryCtx context.Context
+}
+
+// withQueryContext returns copy of the executor, which binds queries to ctx
+func (de *DepthExecutor) withQueryContext(ctx context.Context) *DepthExecutor {
+	bound := *de
+	bound.queryCtx = ctx
+	return &bound
 }
 
-// Execute takes execution requests and runs them async, gathering all errors and results
+// Execute takes execution requests and runs them async, gathering all errors and results.
+// Failure of some requests doesn't affect others, failed ones are returned in FailedExecutionRequests.
 func (de *DepthExecutor) Execute(ers []*ExecutionRequest) (*DepthExecutorResponse, error) {
 	if len(ers) == 0 {
 		return nil, errors.New("empty request list")
@@ -28,34 +40,24 @@ func (de *DepthExecutor) Execute(ers []*ExecutionRequest) (*DepthExecutorRespons
 		return x.QueryPlanStep.URL
 	})
 
-	res, err := common.AsyncMapReduce(
+	res, _ := common.AsyncMapReduce(
 		groupedRequests,
 		new(DepthExecutorResponse),
 		func(field []*ExecutionRequest) (*DepthExecutorResponse, error) {
 			// compute general values like query variables, operationName and etc.
 			qResps, err := de.executeRequests(field)
 			if err != nil {
-				return nil, err
+				// the whole group failed, f.e. service is unavailable
+				return de.failedResponse(field, err), nil
 			}
 
 			// parse response
-			res, err := de.parseRespones(qResps)
-			if err != nil {
-				return nil, err
-			}
-
-			return res, nil
+			return de.parseRespones(qResps), nil
 		},
 		func(acc *DepthExecutorResponse, value *DepthExecutorResponse) *DepthExecutorResponse {
-			acc.ExecutionResults = append(acc.ExecutionResults, value.ExecutionResults...)
-			acc.NextExecutionRequests = append(acc.NextExecutionRequests, value.NextExecutionRequests...)
-			return acc
+			return acc.merge(value)
 		},
 	)
 
-	if err != nil {
-		return nil, err
-	}
-
 	return res, nil
 }
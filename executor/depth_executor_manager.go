@@ -3,6 +3,7 @@ package executor
 import (
 	"github.com/buildbuildio/pebbles/gqlerrors"
 	"github.com/buildbuildio/pebbles/planner"
+	"github.com/buildbuildio/pebbles/tracing"
 )
 
 type DepthExecutorManager struct {
@@ -12,6 +13,8 @@ type DepthExecutorManager struct {
 	maxDepth              int
 	result                map[string]interface{}
 	initialInsertionPoint []string
+	// fieldTypes are collected lazily on first failed request
+	fieldTypes fieldTypes
 }
 
 func NewDepthExecutorManager(ctx *ExecutionContext) *DepthExecutorManager {
@@ -52,9 +55,10 @@ func NewDepthExecutorManager(ctx *ExecutionContext) *DepthExecutorManager {
 }
 
 // Execute takes ExecutionContext and performs sequential execution for each depth in the plan.
+// If some requests fail, it returns partial result along with the errors.
 func (dem *DepthExecutorManager) Execute() (map[string]interface{}, error) {
 	executionRequests := make([]*ExecutionRequest, 0)
-	errs := gqlerrors.ErrorList{}
+	var errs gqlerrors.ErrorList
 
 	// for initial step construct root queries
 	for _, step := range dem.depthExecutors[0].QueryPlanSteps {
@@ -69,6 +73,10 @@ func (dem *DepthExecutorManager) Execute() (map[string]interface{}, error) {
 		})
 	}
 
+	if dem.ctx.Incremental != nil {
+		return dem.executeIncrementally(executionRequests)
+	}
+
 	for depth := 0; depth <= dem.maxDepth; depth++ {
 		if len(executionRequests) == 0 {
 			break
@@ -76,25 +84,61 @@ func (dem *DepthExecutorManager) Execute() (map[string]interface{}, error) {
 
 		de := dem.depthExecutors[depth]
 
-		// execute each request async
-		// TODO: propagate all errors if possible
-		exResp, executionErr := de.Execute(executionRequests)
-		if executionErr != nil {
-			errs = gqlerrors.ExtendErrorList(errs, executionErr)
-			return nil, errs
+		exResp, depthErrs, ok := dem.executeDepth(de, executionRequests)
+		errs = append(errs, depthErrs...)
+		if !ok {
+			break
 		}
 
-		// merge into acc body
-		if err := dem.merge(exResp); err != nil {
-			return nil, err
-		}
-		// set next execution requests, obtained from current depth
 		executionRequests = exResp.NextExecutionRequests
 	}
 
+	if len(errs) != 0 {
+		return dem.result, errs
+	}
+
 	return dem.result, nil
 }
 
+// executeDepth executes requests of single depth and merges their results.
+// It returns response of the depth with requests for the next depth and false if execution must be stopped.
+func (dem *DepthExecutorManager) executeDepth(de *DepthExecutor, executionRequests []*ExecutionRequest) (_ *DepthExecutorResponse, errs gqlerrors.ErrorList, _ bool) {
+	ctx, span := tracing.Start(dem.ctx.Request.Context(), "graphql.depth")
+	span.SetAttributes(
+		tracing.Int("graphql.depth", de.Depth),
+		tracing.Int("graphql.requests", len(executionRequests)),
+	)
+	defer func() {
+		if len(errs) != 0 {
+			span.RecordError(errs)
+		}
+		span.End()
+	}()
+
+	// execute each request async
+	exResp, executionErr := de.withQueryContext(ctx).Execute(executionRequests)
+	if executionErr != nil {
+		return nil, gqlerrors.ExtendErrorList(errs, executionErr), false
+	}
+
+	errs = append(errs, exResp.Errors...)
+
+	// merge into acc body
+	if err := dem.merge(exResp); err != nil {
+		return nil, gqlerrors.ExtendErrorList(errs, err), false
+	}
+
+	// null fields of failed requests
+	dem.nullify(exResp.FailedExecutionRequests)
+	if dem.result == nil {
+		return nil, errs, false
+	}
+
+	// set next execution requests, obtained from current depth, skipping ones which point to nulled objects
+	exResp.NextExecutionRequests = dem.filterNulled(exResp.NextExecutionRequests)
+	return exResp, errs, true
+}
+
 func (dem *DepthExecutorManager) merge(resp *DepthExecutorResponse) error {
 	for _, res := range resp.ExecutionResults {
 		// this will triggers when we reach depth > 1, because at this point dem.Result will not be emty
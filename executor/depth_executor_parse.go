@@ -4,10 +4,13 @@ import (
 	"errors"
 
 	"github.com/buildbuildio/pebbles/common"
+	"github.com/buildbuildio/pebbles/gqlerrors"
+	"github.com/buildbuildio/pebbles/planner"
 )
 
 // ExecutionResult contains result of DepthExecutor executing single ExecutionRequest
 type ExecutionResult struct {
+	QueryPlanStep  *planner.QueryPlanStep
 	InsertionPoint []string
 	Result         map[string]interface{}
 }
@@ -16,10 +19,36 @@ type ExecutionResult struct {
 type DepthExecutorResponse struct {
 	ExecutionResults      []*ExecutionResult
 	NextExecutionRequests []*ExecutionRequest
+	// FailedExecutionRequests contains requests for which no data was obtained,
+	// their fields must be nulled in the result
+	FailedExecutionRequests []*ExecutionRequest
+	// Errors contains all errors occurred while executing requests
+	Errors gqlerrors.ErrorList
 }
 
-func (de *DepthExecutor) parseRespones(queryerResponses []*queryerResponse) (*DepthExecutorResponse, error) {
-	res, err := common.AsyncMapReduce(
+func (der *DepthExecutorResponse) merge(value *DepthExecutorResponse) *DepthExecutorResponse {
+	der.ExecutionResults = append(der.ExecutionResults, value.ExecutionResults...)
+	der.NextExecutionRequests = append(der.NextExecutionRequests, value.NextExecutionRequests...)
+	der.FailedExecutionRequests = append(der.FailedExecutionRequests, value.FailedExecutionRequests...)
+	der.Errors = append(der.Errors, value.Errors...)
+	return der
+}
+
+// failedResponse marks all provided requests as failed with the same error
+func (de *DepthExecutor) failedResponse(ers []*ExecutionRequest, err error) *DepthExecutorResponse {
+	res := &DepthExecutorResponse{
+		FailedExecutionRequests: ers,
+	}
+
+	for _, er := range ers {
+		res.Errors = append(res.Errors, er.ToGqlErrors(de.PointDataExtractor, err)...)
+	}
+
+	return res
+}
+
+func (de *DepthExecutor) parseRespones(queryerResponses []*queryerResponse) *DepthExecutorResponse {
+	res, _ := common.AsyncMapReduce(
 		queryerResponses,
 		new(DepthExecutorResponse),
 		func(field *queryerResponse) (*DepthExecutorResponse, error) {
@@ -27,6 +56,14 @@ func (de *DepthExecutor) parseRespones(queryerResponses []*queryerResponse) (*De
 			req := field.ExecutionRequest
 			step := req.QueryPlanStep
 
+			// remote service couldn't resolve the request at all
+			if queryResult == nil && len(field.Errors) != 0 {
+				return &DepthExecutorResponse{
+					FailedExecutionRequests: []*ExecutionRequest{req},
+					Errors:                  field.Errors,
+				}, nil
+			}
+
 			// NOTE: this insertion point could point to a list of values. If it did, we have to have
 			//       passed it to the this invocation of this function. It is safe to trust this
 			//       InsertionPoint as the right place to insert this result.
@@ -37,46 +74,60 @@ func (de *DepthExecutor) parseRespones(queryerResponses []*queryerResponse) (*De
 				// get the result from the response that we have to stitch there
 				qr, ok := queryResult[common.NodeFieldName]
 				if !ok {
-					return nil, req.ToGqlError(errors.New("missing node key when expected"))
+					if len(field.Errors) != 0 {
+						return de.failedResponse([]*ExecutionRequest{req}, field.Errors), nil
+					}
+					return de.failedResponse([]*ExecutionRequest{req}, errors.New("missing node key when expected")), nil
 				}
 
 				// if node returned nil, we expect queryResult to be empty map
 				if qr == nil {
+					// node is nulled by remote service because of errors
+					if len(field.Errors) != 0 {
+						return &DepthExecutorResponse{
+							FailedExecutionRequests: []*ExecutionRequest{req},
+							Errors:                  field.Errors,
+						}, nil
+					}
 					queryResult = make(map[string]interface{})
 				} else {
 					qrMap, ok := qr.(map[string]interface{})
 					if !ok {
-						return nil, req.ToGqlError(errors.New("node is not a map"))
+						return de.failedResponse([]*ExecutionRequest{req}, errors.New("node is not a map")), nil
 					}
 
 					queryResult = qrMap
 				}
 			}
 
-			// if there are next steps
-			nextExecutionRequests, err := de.findNextExecutionRequests(req.InsertionPoint, step, queryResult)
-			if err != nil {
-				return nil, req.ToGqlError(err)
+			if queryResult == nil {
+				queryResult = make(map[string]interface{})
 			}
 
-			return &DepthExecutorResponse{
-				NextExecutionRequests: nextExecutionRequests,
+			res := &DepthExecutorResponse{
 				ExecutionResults: []*ExecutionResult{{
+					QueryPlanStep:  step,
 					InsertionPoint: req.InsertionPoint,
 					Result:         queryResult,
 				}},
-			}, nil
+				Errors: field.Errors,
+			}
+
+			// if there are next steps
+			nextExecutionRequests, err := de.findNextExecutionRequests(req.InsertionPoint, step, queryResult)
+			if err != nil {
+				// result itself is fine, but it's not possible to continue with it
+				res.Errors = append(res.Errors, req.ToGqlErrors(de.PointDataExtractor, err)...)
+				return res, nil
+			}
+
+			res.NextExecutionRequests = nextExecutionRequests
+			return res, nil
 		},
 		func(acc *DepthExecutorResponse, value *DepthExecutorResponse) *DepthExecutorResponse {
-			acc.ExecutionResults = append(acc.ExecutionResults, value.ExecutionResults...)
-			acc.NextExecutionRequests = append(acc.NextExecutionRequests, value.NextExecutionRequests...)
-			return acc
+			return acc.merge(value)
 		},
 	)
 
-	if err != nil {
-		return nil, err
-	}
-
-	return res, nil
+	return res
 }
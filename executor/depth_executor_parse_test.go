@@ -10,7 +10,9 @@ import (
 )
 
 func TestParseResponseEmpty(t *testing.T) {
-	de := &DepthExecutor{}
+	de := &DepthExecutor{
+		PointDataExtractor: &CachedPointDataExtractor{cache: make(map[string]*PointData)},
+	}
 
 	type Case struct {
 		ParentType string
@@ -48,26 +50,30 @@ func TestParseResponseEmpty(t *testing.T) {
 		Response:   map[string]interface{}{common.NodeFieldName: "1"},
 		IsErr:      true,
 	}} {
+		step := &planner.QueryPlanStep{
+			ParentType: c.ParentType,
+		}
 		resps := []*queryerResponse{{
 			ExecutionRequest: &ExecutionRequest{
-				QueryPlanStep: &planner.QueryPlanStep{
-					ParentType: c.ParentType,
-				},
+				QueryPlanStep:  step,
 				InsertionPoint: nil,
 			},
 			Response: c.Response,
 		}}
 
-		actual, err := de.parseRespones(resps)
+		actual := de.parseRespones(resps)
 		if c.IsErr {
-			assert.Error(t, err)
+			assert.NotEmpty(t, actual.Errors)
+			assert.Len(t, actual.FailedExecutionRequests, 1)
+			assert.Empty(t, actual.ExecutionResults)
 			continue
 		}
 
-		require.NoError(t, err)
+		require.Empty(t, actual.Errors)
 
 		assert.EqualValues(t, DepthExecutorResponse{
 			ExecutionResults: []*ExecutionResult{{
+				QueryPlanStep:  step,
 				InsertionPoint: nil,
 				Result:         c.Result,
 			}},
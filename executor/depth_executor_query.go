@@ -7,7 +7,9 @@ import (
 
 	"github.com/buildbuildio/pebbles/common"
 	"github.com/buildbuildio/pebbles/gqlerrors"
+	"github.com/buildbuildio/pebbles/metrics"
 	"github.com/buildbuildio/pebbles/planner"
+	"github.com/buildbuildio/pebbles/queryer"
 	"github.com/buildbuildio/pebbles/requests"
 	"github.com/samber/lo"
 )
@@ -51,23 +53,107 @@ type ExecutionRequest struct {
 	InsertionPoint []string
 }
 
-// ToGqlError takes error and produces *gqlerrors.Error using er.InsertionPoint as path and err.Error as message.
-// If err is already *gqlerrors.Error, it returns it as is.
+// Path returns path of the object, which request is inserted into, as it's represented in gateway response.
+// F.e. insertion point ["users:3#User_4", "books"] becomes ["users", 3, "books"]
+func (er ExecutionRequest) Path(extractor PointDataExtractor) []interface{} {
+	var path []interface{}
+	for _, point := range er.InsertionPoint {
+		pointData, err := extractor.Extract(point)
+		if err != nil {
+			path = append(path, point)
+			continue
+		}
+
+		path = append(path, pointData.Field)
+		if pointData.Index >= 0 {
+			path = append(path, pointData.Index)
+		}
+	}
+
+	return path
+}
+
+// ToGqlError takes error and produces *gqlerrors.Error using er.InsertionPoint as path.
+// If err is already *gqlerrors.Error, it returns it as is. ToGqlErrors should be preferred, as it keeps all errors
+// and points them to the place in gateway response.
 func (er ExecutionRequest) ToGqlError(err error) *gqlerrors.Error {
 	if e, ok := err.(*gqlerrors.Error); ok {
 		return e
 	}
 
-	return &gqlerrors.Error{
-		Path:       lo.Map(er.InsertionPoint, func(el string, i int) interface{} { return el }),
-		Extensions: nil,
-		Message:    err.Error(),
+	errs := er.ToGqlErrors(rawPointDataExtractor{}, err)
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return errs[0]
+}
+
+// rawPointDataExtractor fails to extract any point, so insertion points are used in paths as is
+type rawPointDataExtractor struct{}
+
+func (rawPointDataExtractor) Extract(point string) (*PointData, error) {
+	return nil, errors.New("point data isn't available")
+}
+
+// ToGqlErrors takes error and produces gqlerrors.ErrorList using er.Path and the first field of request as path.
+// Each error is copied, so it's safe to pass the same error for different requests.
+func (er ExecutionRequest) ToGqlErrors(extractor PointDataExtractor, err error) gqlerrors.ErrorList {
+	path := er.Path(extractor)
+	if field := firstResponseFieldName(er.QueryPlanStep); field != "" {
+		path = append(path, field)
+	}
+
+	return lo.Map(gqlerrors.FormatError(err), func(e *gqlerrors.Error, _ int) *gqlerrors.Error {
+		cpy := *e
+		if len(cpy.Path) == 0 {
+			cpy.Path = path
+		}
+		return &cpy
+	})
+}
+
+// RemapErrors rewrites errors returned by remote service, so they point to the place in gateway response.
+// Errors of node queries have path relative to synthetic node query, f.e. ["node", "books"], which becomes ["users", 3, "books"].
+// Locations refer to the query sent to the service, so they are dropped. Service url is added to extensions.
+func (er ExecutionRequest) RemapErrors(extractor PointDataExtractor, errs gqlerrors.ErrorList) gqlerrors.ErrorList {
+	if len(errs) == 0 {
+		return nil
 	}
+
+	isNodeQuery := !common.IsRootObjectName(er.QueryPlanStep.ParentType)
+	path := er.Path(extractor)
+
+	return lo.Map(errs, func(e *gqlerrors.Error, _ int) *gqlerrors.Error {
+		cpy := *e
+		cpy.Locations = nil
+
+		cpy.Extensions = make(map[string]interface{}, len(e.Extensions)+1)
+		for k, v := range e.Extensions {
+			cpy.Extensions[k] = v
+		}
+		cpy.Extensions[gqlerrors.ServiceExtension] = er.QueryPlanStep.URL
+
+		if isNodeQuery && len(e.Path) > 0 {
+			remapped := make([]interface{}, 0, len(path)+len(e.Path))
+			remapped = append(remapped, path...)
+			if e.Path[0] == common.NodeFieldName {
+				remapped = append(remapped, e.Path[1:]...)
+			} else {
+				remapped = append(remapped, e.Path...)
+			}
+			cpy.Path = remapped
+		}
+
+		return &cpy
+	})
 }
 
 type queryerResponse struct {
 	ExecutionRequest *ExecutionRequest
 	Response         map[string]interface{}
+	// Errors contains errors returned by the remote service for the request
+	Errors gqlerrors.ErrorList
 }
 
 // getVariables determines which variables we need to send with provided request
@@ -130,10 +216,9 @@ func (de *DepthExecutor) isNeedToQuery(req *ExecutionRequest, variables map[stri
 	return parentType == req.QueryPlanStep.ParentType
 }
 
-func (de *DepthExecutor) setIMap(index int, req *ExecutionRequest, variables map[string]interface{}, iMap indexMap) bool {
+func (de *DepthExecutor) setIMap(index, nextTargetIndex int, req *ExecutionRequest, variables map[string]interface{}, iMap indexMap) bool {
 	// exclude same requests to optimize queryer
 	// check for child query which is always node
-	nextTargetIndex := len(iMap)
 	if !common.IsRootObjectName(req.QueryPlanStep.ParentType) && len(variables) == 1 {
 		if id, ok := variables[common.IDFieldName]; ok {
 			return iMap.Set(
@@ -157,11 +242,15 @@ func (de *DepthExecutor) executeRequests(ers []*ExecutionRequest) ([]*queryerRes
 	batchRequest := make([]*requests.Request, 0, len(ers))
 	iMap := make(indexMap, len(ers))
 	nillResps := make(map[int]struct{})
+	failedResps := make(map[int]error)
+	nodesBatches := make(map[[32]byte]*nodesBatch)
+	nodesBatchesByTarget := make(map[int]*nodesBatch)
 
 	for i, req := range ers {
 		variables, err := de.getVariables(req)
 		if err != nil {
-			return nil, err
+			failedResps[i] = err
+			continue
 		}
 
 		if !de.isNeedToQuery(req, variables) {
@@ -169,7 +258,19 @@ func (de *DepthExecutor) executeRequests(ers []*ExecutionRequest) ([]*queryerRes
 			continue
 		}
 
-		if isNewValue := de.setIMap(i, req, variables, iMap); !isNewValue {
+		if de.isNodesBatchingEnabled(req) {
+			nb, ok := nodesBatches[req.QueryPlanStep.QueryStringHash]
+			if !ok {
+				nb = newNodesBatch(len(batchRequest), req.QueryPlanStep, variables)
+				nodesBatches[req.QueryPlanStep.QueryStringHash] = nb
+				nodesBatchesByTarget[nb.targetIndex] = nb
+				batchRequest = append(batchRequest, nb.request)
+			}
+			nb.add(i, variables[common.IDFieldName])
+			continue
+		}
+
+		if isNewValue := de.setIMap(i, len(batchRequest), req, variables, iMap); !isNewValue {
 			continue
 		}
 
@@ -187,9 +288,28 @@ func (de *DepthExecutor) executeRequests(ers []*ExecutionRequest) ([]*queryerRes
 		return nil, fmt.Errorf("unable to find queryer for: %s", ers[0].QueryPlanStep.URL)
 	}
 
-	resps, err := q.Query(batchRequest)
-	if err != nil {
-		return nil, err
+	var resps []map[string]interface{}
+	var respErrs queryer.ResponseErrors
+	if len(batchRequest) > 0 {
+		var err error
+		if de.queryCtx != nil {
+			resps, err = queryer.QueryContext(de.queryCtx, q, batchRequest)
+		} else {
+			resps, err = q.Query(batchRequest)
+		}
+		if err != nil {
+			// remote service responded with errors for some requests, results are still usable
+			if !errors.As(err, &respErrs) {
+				metrics.FromContext(de.ctx.Request.Context()).ObserveDownstreamRequest(ers[0].QueryPlanStep.URL, metrics.OutcomeError)
+				return nil, err
+			}
+		}
+
+		outcome := metrics.OutcomeSuccess
+		if !respErrs.IsEmpty() {
+			outcome = metrics.OutcomePartial
+		}
+		metrics.FromContext(de.ctx.Request.Context()).ObserveDownstreamRequest(ers[0].QueryPlanStep.URL, outcome)
 	}
 
 	if len(resps) != len(batchRequest) {
@@ -198,6 +318,23 @@ func (de *DepthExecutor) executeRequests(ers []*ExecutionRequest) ([]*queryerRes
 
 	qResps := make([]*queryerResponse, len(ers))
 	for i, resp := range resps {
+		var errs gqlerrors.ErrorList
+		if i < len(respErrs) {
+			errs = respErrs[i]
+		}
+
+		if nb, ok := nodesBatchesByTarget[i]; ok {
+			splitResps, splitErrs := nb.split(resp, errs)
+			for ind, splitResp := range splitResps {
+				qResps[ind] = &queryerResponse{
+					Response:         splitResp,
+					ExecutionRequest: ers[ind],
+					Errors:           ers[ind].RemapErrors(de.PointDataExtractor, splitErrs[ind]),
+				}
+			}
+			continue
+		}
+
 		indexes := iMap.GetSameIndexes(i)
 		if len(indexes) == 0 {
 			return nil, errors.New("missing mapping for indexes")
@@ -212,10 +349,18 @@ func (de *DepthExecutor) executeRequests(ers []*ExecutionRequest) ([]*queryerRes
 			qResps[ind] = &queryerResponse{
 				Response:         copyResp,
 				ExecutionRequest: ers[ind],
+				Errors:           ers[ind].RemapErrors(de.PointDataExtractor, errs),
 			}
 		}
 	}
 
+	for ind, err := range failedResps {
+		qResps[ind] = &queryerResponse{
+			ExecutionRequest: ers[ind],
+			Errors:           ers[ind].ToGqlErrors(de.PointDataExtractor, err),
+		}
+	}
+
 	for ind := range nillResps {
 		qResps[ind] = &queryerResponse{
 			Response: map[string]interface{}{
@@ -1,13 +1,16 @@
 package executor
 
 import (
+	"errors"
 	"strconv"
 	"testing"
 
 	"github.com/buildbuildio/pebbles/common"
+	"github.com/buildbuildio/pebbles/gqlerrors"
 	"github.com/buildbuildio/pebbles/planner"
 	"github.com/buildbuildio/pebbles/queryer"
 	"github.com/buildbuildio/pebbles/requests"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -199,3 +202,65 @@ func TestExecuteRequestsWithGetParentTypeFromIDFunc(t *testing.T) {
 		}
 	}
 }
+
+func TestExecutionRequestRemapErrors(t *testing.T) {
+	pointDataExtractor := &CachedPointDataExtractor{cache: make(map[string]*PointData)}
+
+	original := gqlerrors.ErrorList{{
+		Message:    "failed",
+		Path:       []interface{}{common.NodeFieldName, "books", 1},
+		Locations:  []gqlerrors.Location{{Line: 1, Column: 10}},
+		Extensions: map[string]interface{}{"code": "SOME_CODE"},
+	}}
+
+	er := ExecutionRequest{
+		QueryPlanStep: &planner.QueryPlanStep{
+			URL:        "0",
+			ParentType: "User",
+		},
+		InsertionPoint: []string{"users:3#User_4"},
+	}
+
+	errs := er.RemapErrors(pointDataExtractor, original)
+	require.Len(t, errs, 1)
+	assert.Equal(t, []interface{}{"users", 3, "books", 1}, errs[0].Path)
+	assert.Nil(t, errs[0].Locations)
+	assert.Equal(t, map[string]interface{}{
+		"code":                     "SOME_CODE",
+		gqlerrors.ServiceExtension: "0",
+	}, errs[0].Extensions)
+
+	// original error must stay untouched as it can be shared between requests
+	assert.Equal(t, []interface{}{common.NodeFieldName, "books", 1}, original[0].Path)
+	assert.Len(t, original[0].Locations, 1)
+	assert.NotContains(t, original[0].Extensions, gqlerrors.ServiceExtension)
+
+	rootEr := ExecutionRequest{
+		QueryPlanStep: &planner.QueryPlanStep{
+			URL:        "1",
+			ParentType: "Query",
+		},
+	}
+
+	errs = rootEr.RemapErrors(pointDataExtractor, gqlerrors.ErrorList{{
+		Message: "failed",
+		Path:    []interface{}{"users", 0},
+	}})
+	require.Len(t, errs, 1)
+	assert.Equal(t, []interface{}{"users", 0}, errs[0].Path)
+	assert.Equal(t, "1", errs[0].Extensions[gqlerrors.ServiceExtension])
+}
+
+func TestExecutionRequestToGqlError(t *testing.T) {
+	er := ExecutionRequest{
+		QueryPlanStep:  &planner.QueryPlanStep{ParentType: "User"},
+		InsertionPoint: []string{"users:3#User_4"},
+	}
+
+	err := er.ToGqlError(errors.New("failed"))
+	assert.Equal(t, "failed", err.Message)
+	assert.Equal(t, []interface{}{"users:3#User_4"}, err.Path)
+
+	gqlErr := &gqlerrors.Error{Message: "failed"}
+	assert.Same(t, gqlErr, er.ToGqlError(gqlErr))
+}
@@ -21,6 +21,12 @@ type ExecutionContext struct {
 	// f.e. when having id like user_10 and querying node(id: "user_10") (... on Book { id name })
 	// it's obvious in advance that result will be null
 	GetParentTypeFromIDFunc GetParentTypeFromIDFunc
+	// NodesBatchingURLs contains urls of services, which support nodes(ids: [ID!]!) query.
+	// For them all objects of the same step are fetched with single nodes query instead of node query for each object.
+	NodesBatchingURLs map[string]struct{}
+	// Incremental receives parts of the result as soon as they're ready, when operation contains @defer or @stream.
+	// If it's not set, deferred steps are executed along with the rest of the plan.
+	Incremental IncrementalDelivery
 }
 
 type Executor interface {
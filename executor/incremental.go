@@ -0,0 +1,472 @@
+package executor
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/buildbuildio/pebbles/gqlerrors"
+)
+
+// IncrementalResult is a part of the result, which is delivered after the initial one:
+// data of deferred fragment or items of streamed list
+type IncrementalResult struct {
+	Data   map[string]interface{}
+	Items  []interface{}
+	Path   []interface{}
+	Label  string
+	Errors gqlerrors.ErrorList
+}
+
+// MarshalJSON marshals result in incremental delivery format. Items are set for streamed lists only, otherwise data is set.
+func (ir *IncrementalResult) MarshalJSON() ([]byte, error) {
+	res := map[string]interface{}{
+		"path": ir.Path,
+	}
+
+	if ir.Items != nil {
+		res["items"] = ir.Items
+	} else {
+		res["data"] = ir.Data
+	}
+
+	if ir.Label != "" {
+		res["label"] = ir.Label
+	}
+
+	if len(ir.Errors) != 0 {
+		res["errors"] = ir.Errors
+	}
+
+	return json.Marshal(res)
+}
+
+// IncrementalDelivery receives parts of the result of operation, which contains @defer or @stream directives
+type IncrementalDelivery interface {
+	// Initial receives result of the plan without deferred steps and without items of streamed lists beyond initial count
+	Initial(data map[string]interface{}, errs gqlerrors.ErrorList, hasNext bool)
+	// Subsequent receives results of deferred steps executed at the same depth. Items of streamed lists are sent last.
+	Subsequent(results []*IncrementalResult, hasNext bool)
+}
+
+// activeStream is a streamed list field with arguments evaluated for the request
+type activeStream struct {
+	path         []string
+	initialCount int
+	label        string
+}
+
+// streamedList is a list in the initial result, items of which beyond initial count are sent later
+type streamedList struct {
+	path         []interface{}
+	aliasPath    []string
+	initialCount int
+	label        string
+}
+
+// contains returns true if path points to the item of the list, which isn't sent in initial result, or inside it
+func (sl *streamedList) contains(path []interface{}) bool {
+	if len(path) <= len(sl.path) || !isPathPrefix(sl.path, path) {
+		return false
+	}
+
+	index, ok := path[len(sl.path)].(int)
+	return ok && index >= sl.initialCount
+}
+
+// executeIncrementally executes the plan in two passes. At first steps, which aren't deferred, are executed and their result
+// is passed to IncrementalDelivery as initial one. Then deferred steps are executed depth by depth and results of each depth
+// are sent as subsequent ones. Objects of streamed lists beyond initial count are completed in the second pass as well.
+func (dem *DepthExecutorManager) executeIncrementally(executionRequests []*ExecutionRequest) (map[string]interface{}, error) {
+	var errs gqlerrors.ErrorList
+	streams := dem.activeStreams()
+	deferred := make(map[int][]*ExecutionRequest)
+
+	for depth := 0; depth <= dem.maxDepth; depth++ {
+		var immediate []*ExecutionRequest
+		for _, er := range executionRequests {
+			if dem.isDeferred(er, streams) {
+				deferred[depth] = append(deferred[depth], er)
+			} else {
+				immediate = append(immediate, er)
+			}
+		}
+
+		if len(immediate) == 0 {
+			break
+		}
+
+		exResp, depthErrs, ok := dem.executeDepth(dem.depthExecutors[depth], immediate)
+		errs = append(errs, depthErrs...)
+		if !ok {
+			dem.ctx.Incremental.Initial(dem.initialData(nil), errs, false)
+			return dem.result, nilIfEmpty(errs)
+		}
+
+		executionRequests = exResp.NextExecutionRequests
+	}
+
+	var lists []*streamedList
+	data := dem.initialData(func(data map[string]interface{}) {
+		for _, s := range streams {
+			lists = append(lists, truncateStreamedLists(data, s, nil, 0)...)
+		}
+	})
+
+	hasNext := len(deferred) != 0 || len(lists) != 0
+	dem.ctx.Incremental.Initial(data, errs, hasNext)
+	if !hasNext {
+		return dem.result, nilIfEmpty(errs)
+	}
+
+	// errors, which can't be attached to results yet
+	var pendingErrs gqlerrors.ErrorList
+
+	executionRequests = nil
+	for depth := 0; depth <= dem.maxDepth; depth++ {
+		// objects could be nulled after requests were deferred
+		executionRequests = append(dem.filterNulled(deferred[depth]), executionRequests...)
+		delete(deferred, depth)
+		if len(executionRequests) == 0 {
+			continue
+		}
+
+		exResp, depthErrs, ok := dem.executeDepth(dem.depthExecutors[depth], executionRequests)
+		errs = append(errs, depthErrs...)
+		pendingErrs = append(pendingErrs, depthErrs...)
+
+		var results []*IncrementalResult
+		if exResp != nil {
+			results = dem.deferredResults(exResp, lists)
+			executionRequests = exResp.NextExecutionRequests
+		}
+		pendingErrs = assignErrors(results, pendingErrs)
+
+		hasNext := ok && (len(executionRequests) != 0 || len(deferred) != 0 || len(lists) != 0)
+		if len(results) != 0 || !hasNext {
+			dem.ctx.Incremental.Subsequent(results, hasNext)
+		}
+
+		if !hasNext {
+			return dem.result, nilIfEmpty(errs)
+		}
+	}
+
+	results := dem.streamedItems(lists)
+	assignErrors(results, pendingErrs)
+	dem.ctx.Incremental.Subsequent(results, false)
+
+	return dem.result, nilIfEmpty(errs)
+}
+
+func (dem *DepthExecutorManager) activeStreams() []*activeStream {
+	var res []*activeStream
+	for _, sf := range dem.ctx.QueryPlan.StreamFields {
+		enabled, initialCount, label := sf.Arguments(dem.ctx.Request.Variables)
+		if !enabled {
+			continue
+		}
+
+		res = append(res, &activeStream{
+			path:         sf.Path,
+			initialCount: initialCount,
+			label:        label,
+		})
+	}
+
+	return res
+}
+
+// isDeferred returns true if request belongs to deferred fragment or to the item of streamed list beyond initial count
+func (dem *DepthExecutorManager) isDeferred(er *ExecutionRequest, streams []*activeStream) bool {
+	if er.QueryPlanStep.IsDeferred(dem.ctx.Request.Variables) {
+		return true
+	}
+
+	for _, s := range streams {
+		if len(er.InsertionPoint) < len(s.path) {
+			continue
+		}
+
+		for i, field := range s.path {
+			pointData, err := dem.pointDataExtractor.Extract(er.InsertionPoint[i])
+			if err != nil || pointData.Field != field {
+				break
+			}
+
+			if i == len(s.path)-1 && pointData.Index >= s.initialCount {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// initialData returns cleaned copy of current result. Copy can be modified with provided function before it's cleaned.
+func (dem *DepthExecutorManager) initialData(modify func(map[string]interface{})) map[string]interface{} {
+	if dem.result == nil {
+		return nil
+	}
+
+	data := copyValue(dem.result).(map[string]interface{})
+	if modify != nil {
+		modify(data)
+	}
+	dem.ctx.QueryPlan.ScrubFields.CleanAt(data, nil)
+
+	return data
+}
+
+// deferredResults returns results of executed deferred requests. Results inside streamed items are skipped,
+// as they're sent along with the items.
+func (dem *DepthExecutorManager) deferredResults(exResp *DepthExecutorResponse, lists []*streamedList) []*IncrementalResult {
+	var res []*IncrementalResult
+	variables := dem.ctx.Request.Variables
+
+	for _, er := range exResp.ExecutionResults {
+		path := ExecutionRequest{InsertionPoint: er.InsertionPoint}.Path(dem.pointDataExtractor)
+		if isInStreamedLists(lists, path) {
+			continue
+		}
+
+		data := copyValue(er.Result).(map[string]interface{})
+		dem.ctx.QueryPlan.ScrubFields.CleanAt(data, dem.aliasPath(er.InsertionPoint))
+
+		res = append(res, &IncrementalResult{
+			Data:  data,
+			Path:  path,
+			Label: er.QueryPlanStep.DeferLabel(variables),
+		})
+	}
+
+	// deferred fragment, which couldn't be fetched, is nulled
+	for _, er := range exResp.FailedExecutionRequests {
+		path := er.Path(dem.pointDataExtractor)
+		if isInStreamedLists(lists, path) {
+			continue
+		}
+
+		res = append(res, &IncrementalResult{
+			Path:  path,
+			Label: er.QueryPlanStep.DeferLabel(variables),
+		})
+	}
+
+	// requests are executed concurrently, so results are sorted to keep payload stable
+	sort.SliceStable(res, func(i, j int) bool {
+		return isPathLess(res[i].Path, res[j].Path)
+	})
+
+	return res
+}
+
+// streamedItems returns items of streamed lists, which weren't sent in initial result
+func (dem *DepthExecutorManager) streamedItems(lists []*streamedList) []*IncrementalResult {
+	var res []*IncrementalResult
+	for _, sl := range lists {
+		list, ok := valueAtPath(dem.result, sl.path).([]interface{})
+		if !ok || len(list) <= sl.initialCount {
+			continue
+		}
+
+		items := copyValue(list[sl.initialCount:]).([]interface{})
+		for _, item := range items {
+			if obj, ok := item.(map[string]interface{}); ok {
+				dem.ctx.QueryPlan.ScrubFields.CleanAt(obj, sl.aliasPath)
+			}
+		}
+
+		res = append(res, &IncrementalResult{
+			Items: items,
+			Path:  append(append([]interface{}{}, sl.path...), sl.initialCount),
+			Label: sl.label,
+		})
+	}
+
+	return res
+}
+
+// aliasPath returns fields of insertion point without indexes and ids
+func (dem *DepthExecutorManager) aliasPath(insertionPoint []string) []string {
+	res := make([]string, 0, len(insertionPoint))
+	for _, point := range insertionPoint {
+		if pointData, err := dem.pointDataExtractor.Extract(point); err == nil {
+			res = append(res, pointData.Field)
+		}
+	}
+
+	return res
+}
+
+// truncateStreamedLists cuts lists of streamed field to initial count and returns ones which were cut
+func truncateStreamedLists(obj map[string]interface{}, s *activeStream, path []interface{}, i int) []*streamedList {
+	field := s.path[i]
+	fieldPath := append(append([]interface{}{}, path...), field)
+
+	if i == len(s.path)-1 {
+		list, ok := obj[field].([]interface{})
+		if !ok || len(list) <= s.initialCount {
+			return nil
+		}
+
+		obj[field] = list[:s.initialCount]
+		return []*streamedList{{
+			path:         fieldPath,
+			aliasPath:    s.path,
+			initialCount: s.initialCount,
+			label:        s.label,
+		}}
+	}
+
+	var res []*streamedList
+	switch v := obj[field].(type) {
+	case map[string]interface{}:
+		res = truncateStreamedLists(v, s, fieldPath, i+1)
+	case []interface{}:
+		for index, item := range v {
+			if itemObj, ok := item.(map[string]interface{}); ok {
+				itemPath := append(append([]interface{}{}, fieldPath...), index)
+				res = append(res, truncateStreamedLists(itemObj, s, itemPath, i+1)...)
+			}
+		}
+	}
+
+	return res
+}
+
+// assignErrors attaches errors to results, which paths contain them. Errors without such result are attached to the first one.
+// If there are no results, errors are returned back.
+func assignErrors(results []*IncrementalResult, errs gqlerrors.ErrorList) gqlerrors.ErrorList {
+	if len(results) == 0 {
+		return errs
+	}
+
+	for _, e := range errs {
+		target := results[0]
+		longest := -1
+		for _, res := range results {
+			path := res.Path
+			if res.Items != nil {
+				// items result covers all items of the list starting from its index
+				path = path[:len(path)-1]
+			}
+
+			if len(path) > longest && isPathPrefix(path, e.Path) {
+				target = res
+				longest = len(path)
+			}
+		}
+
+		target.Errors = append(target.Errors, e)
+	}
+
+	return nil
+}
+
+func isInStreamedLists(lists []*streamedList, path []interface{}) bool {
+	for _, sl := range lists {
+		if sl.contains(path) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isPathPrefix compares paths by values, as indexes of errors returned by services are decoded as floats
+func isPathPrefix(prefix, path []interface{}) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+
+	for i, p := range prefix {
+		if fmt.Sprint(p) != fmt.Sprint(path[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isPathLess compares paths element by element. List indexes are compared as numbers and go before field names,
+// though both kinds of elements never meet at the same position of paths of one result.
+func isPathLess(a, b []interface{}) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		switch av := a[i].(type) {
+		case int:
+			bv, ok := b[i].(int)
+			if !ok {
+				return true
+			}
+			if av != bv {
+				return av < bv
+			}
+		case string:
+			bv, ok := b[i].(string)
+			if !ok {
+				return false
+			}
+			if av != bv {
+				return av < bv
+			}
+		}
+	}
+
+	return len(a) < len(b)
+}
+
+func valueAtPath(value interface{}, path []interface{}) interface{} {
+	for _, p := range path {
+		switch p := p.(type) {
+		case string:
+			obj, ok := value.(map[string]interface{})
+			if !ok {
+				return nil
+			}
+			value = obj[p]
+		case int:
+			list, ok := value.([]interface{})
+			if !ok || p >= len(list) {
+				return nil
+			}
+			value = list[p]
+		}
+	}
+
+	return value
+}
+
+// copyValue returns deep copy of the result value, so it can be modified while execution continues
+func copyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		res := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			res[key] = copyValue(item)
+		}
+		return res
+	case []interface{}:
+		res := make([]interface{}, len(v))
+		for i, item := range v {
+			res[i] = copyValue(item)
+		}
+		return res
+	case []map[string]interface{}:
+		res := make([]interface{}, len(v))
+		for i, item := range v {
+			res[i] = copyValue(item)
+		}
+		return res
+	}
+
+	return value
+}
+
+func nilIfEmpty(errs gqlerrors.ErrorList) error {
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return errs
+}
@@ -0,0 +1,232 @@
+package executor
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/buildbuildio/pebbles/gqlerrors"
+	"github.com/buildbuildio/pebbles/merger"
+	"github.com/buildbuildio/pebbles/planner"
+	"github.com/buildbuildio/pebbles/queryer"
+	"github.com/buildbuildio/pebbles/requests"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+var incrementalSchema = `
+	interface Node {
+		id: ID!
+	}
+
+	type Movie implements Node {
+		id: ID!
+		title: String!
+		author: Author!
+	}
+
+	type Author implements Node {
+		id: ID!
+		name: String!
+	}
+
+	type Query {
+		movies: [Movie!]!
+		node(id: ID!): Node
+	}
+` + planner.IncrementalDirectivesSchema
+
+var incrementalTum = merger.TypeURLMap{
+	"Query": {
+		Fields: map[string]string{
+			"movies": "0",
+		},
+	},
+	"Movie": {
+		Fields: map[string]string{
+			"title":  "0",
+			"author": "0",
+		},
+		IsImplementsNode: true,
+	},
+	"Author": {
+		Fields: map[string]string{
+			"name": "1",
+		},
+		IsImplementsNode: true,
+	},
+}
+
+var incrementalQueryers = map[string]queryer.Queryer{
+	"0": MockQueryerFunc{F: func(inputs []*requests.Request) ([]map[string]interface{}, error) {
+		var res []map[string]interface{}
+		for _, input := range inputs {
+			if !strings.Contains(input.Query, "node") {
+				var movies []interface{}
+				for i, title := range []string{"A", "B", "C"} {
+					movie := map[string]interface{}{"id": fmt.Sprint(i + 1), "title": title}
+					if strings.Contains(input.Query, "author") {
+						movie["author"] = map[string]interface{}{"id": fmt.Sprintf("a%d", i+1)}
+					}
+					movies = append(movies, movie)
+				}
+				res = append(res, map[string]interface{}{"movies": movies})
+				continue
+			}
+
+			id := input.Variables["id"]
+			node := map[string]interface{}{}
+			if strings.Contains(input.Query, "title") {
+				node["title"] = fmt.Sprintf("T%v", id)
+			}
+			if strings.Contains(input.Query, "author") {
+				node["author"] = map[string]interface{}{"id": fmt.Sprintf("a%v", id)}
+			}
+			res = append(res, map[string]interface{}{"node": node})
+		}
+		return res, nil
+	}},
+	"1": MockQueryerFunc{F: func(inputs []*requests.Request) ([]map[string]interface{}, error) {
+		var res []map[string]interface{}
+		for _, input := range inputs {
+			res = append(res, map[string]interface{}{
+				"node": map[string]interface{}{"name": fmt.Sprintf("Author %v", input.Variables["id"])},
+			})
+		}
+		return res, nil
+	}},
+}
+
+// mockIncrementalDelivery records parts of the result as json
+type mockIncrementalDelivery struct {
+	parts []string
+}
+
+func (d *mockIncrementalDelivery) Initial(data map[string]interface{}, errs gqlerrors.ErrorList, hasNext bool) {
+	b, _ := json.Marshal(map[string]interface{}{"data": data, "hasNext": hasNext})
+	d.parts = append(d.parts, string(b))
+}
+
+func (d *mockIncrementalDelivery) Subsequent(results []*IncrementalResult, hasNext bool) {
+	b, _ := json.Marshal(map[string]interface{}{"incremental": results, "hasNext": hasNext})
+	d.parts = append(d.parts, string(b))
+}
+
+func mustExecuteIncrementally(t *testing.T, query string, variables map[string]interface{}) []string {
+	t.Helper()
+
+	schema := gqlparser.MustLoadSchema(&ast.Source{Input: incrementalSchema})
+	operation := gqlparser.MustLoadQuery(schema, query).Operations[0]
+
+	request := &requests.Request{Query: query, Variables: variables}
+	plan, err := (planner.SequentialPlanner)(nil).Plan(&planner.PlanningContext{
+		Operation:  operation,
+		Request:    request,
+		Schema:     schema,
+		TypeURLMap: incrementalTum,
+	})
+	require.NoError(t, err)
+
+	delivery := &mockIncrementalDelivery{}
+	_, err = parallelExecutor.Execute(&ExecutionContext{
+		QueryPlan:   plan,
+		Request:     request,
+		Queryers:    incrementalQueryers,
+		Incremental: delivery,
+	})
+	require.NoError(t, err)
+
+	return delivery.parts
+}
+
+func TestExecuteDeferredFragment(t *testing.T) {
+	parts := mustExecuteIncrementally(t, `{ movies { title ... @defer(label: "author") { author { name } } } }`, nil)
+
+	require.Len(t, parts, 3)
+	assert.JSONEq(t, `{"data": {"movies": [{"title": "A"}, {"title": "B"}, {"title": "C"}]}, "hasNext": true}`, parts[0])
+	assert.JSONEq(t, `{"incremental": [
+		{"data": {"author": {}}, "path": ["movies", 0], "label": "author"},
+		{"data": {"author": {}}, "path": ["movies", 1], "label": "author"},
+		{"data": {"author": {}}, "path": ["movies", 2], "label": "author"}
+	], "hasNext": true}`, parts[1])
+	assert.JSONEq(t, `{"incremental": [
+		{"data": {"name": "Author a1"}, "path": ["movies", 0, "author"], "label": "author"},
+		{"data": {"name": "Author a2"}, "path": ["movies", 1, "author"], "label": "author"},
+		{"data": {"name": "Author a3"}, "path": ["movies", 2, "author"], "label": "author"}
+	], "hasNext": false}`, parts[2])
+}
+
+func TestExecuteDeferredFragmentDisabled(t *testing.T) {
+	parts := mustExecuteIncrementally(
+		t,
+		`query ($deferred: Boolean!) { movies { ... @defer(if: $deferred) { title } } }`,
+		map[string]interface{}{"deferred": false},
+	)
+
+	// deferred step is executed with the rest of the plan
+	require.Len(t, parts, 1)
+	assert.JSONEq(t, `{"data": {"movies": [{"title": "T1"}, {"title": "T2"}, {"title": "T3"}]}, "hasNext": false}`, parts[0])
+}
+
+func TestExecuteStreamedList(t *testing.T) {
+	parts := mustExecuteIncrementally(t, `{ movies @stream(initialCount: 1) { title author { name } } }`, nil)
+
+	require.Len(t, parts, 2)
+	assert.JSONEq(t, `{"data": {"movies": [{"title": "A", "author": {"name": "Author a1"}}]}, "hasNext": true}`, parts[0])
+	assert.JSONEq(t, `{"incremental": [
+		{"items": [{"title": "B", "author": {"name": "Author a2"}}, {"title": "C", "author": {"name": "Author a3"}}], "path": ["movies", 1]}
+	], "hasNext": false}`, parts[1])
+}
+
+func TestExecuteWithoutIncrementalDelivery(t *testing.T) {
+	schema := gqlparser.MustLoadSchema(&ast.Source{Input: incrementalSchema})
+	query := `{ movies { title ... @defer { author { name } } } }`
+	operation := gqlparser.MustLoadQuery(schema, query).Operations[0]
+
+	request := &requests.Request{Query: query}
+	plan, err := (planner.SequentialPlanner)(nil).Plan(&planner.PlanningContext{
+		Operation:  operation,
+		Request:    request,
+		Schema:     schema,
+		TypeURLMap: incrementalTum,
+	})
+	require.NoError(t, err)
+
+	// deferred steps are executed along with the rest
+	result, err := parallelExecutor.Execute(&ExecutionContext{
+		QueryPlan: plan,
+		Request:   request,
+		Queryers:  incrementalQueryers,
+	})
+	require.NoError(t, err)
+
+	plan.ScrubFields.Clean(result)
+	b, _ := json.Marshal(result)
+	assert.JSONEq(t, `{"movies": [
+		{"title": "A", "author": {"name": "Author a1"}},
+		{"title": "B", "author": {"name": "Author a2"}},
+		{"title": "C", "author": {"name": "Author a3"}}
+	]}`, string(b))
+}
+
+func TestIsPathLess(t *testing.T) {
+	testCases := []struct {
+		a, b     []interface{}
+		expected bool
+	}{
+		{[]interface{}{"movies", 2}, []interface{}{"movies", 10}, true},
+		{[]interface{}{"movies", 10}, []interface{}{"movies", 2}, false},
+		{[]interface{}{"authors", 10}, []interface{}{"movies", 2}, true},
+		{[]interface{}{"movies", 1}, []interface{}{"movies", 1, "author"}, true},
+		{[]interface{}{"movies", 1, "author"}, []interface{}{"movies", 1}, false},
+		{[]interface{}{"movies", 1}, []interface{}{"movies", 1}, false},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, isPathLess(tc.a, tc.b), "%v < %v", tc.a, tc.b)
+	}
+}
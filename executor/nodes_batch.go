@@ -0,0 +1,135 @@
+package executor
+
+import (
+	"github.com/buildbuildio/pebbles/common"
+	"github.com/buildbuildio/pebbles/gqlerrors"
+	"github.com/buildbuildio/pebbles/planner"
+	"github.com/buildbuildio/pebbles/requests"
+)
+
+// nodesBatch accumulates requests of the same step, so they can be fetched with single nodes(ids: $ids) query
+// instead of separate node(id: $id) query for each of them.
+type nodesBatch struct {
+	// targetIndex is an index of the batch request in the list of requests sent to the queryer
+	targetIndex int
+	request     *requests.Request
+	ids         []interface{}
+	// positions maps id to its position in ids
+	positions map[interface{}]int
+	// indexes maps index of execution request to position of its id in ids
+	indexes map[int]int
+}
+
+func newNodesBatch(targetIndex int, step *planner.QueryPlanStep, variables map[string]interface{}) *nodesBatch {
+	// all requests of the same step share variables except the id
+	batchVariables := make(map[string]interface{}, len(variables))
+	for k, v := range variables {
+		if k == common.IDFieldName {
+			continue
+		}
+		batchVariables[k] = v
+	}
+
+	return &nodesBatch{
+		targetIndex: targetIndex,
+		request: &requests.Request{
+			Query:     step.NodesQueryString,
+			Variables: batchVariables,
+		},
+		positions: make(map[interface{}]int),
+		indexes:   make(map[int]int),
+	}
+}
+
+// add adds execution request with provided index and id to the batch
+func (nb *nodesBatch) add(index int, id interface{}) {
+	pos, ok := nb.positions[id]
+	if !ok {
+		pos = len(nb.ids)
+		nb.positions[id] = pos
+		nb.ids = append(nb.ids, id)
+		nb.request.Variables[common.IDsFieldName] = nb.ids
+	}
+
+	nb.indexes[index] = pos
+}
+
+// split splits response of nodes query into responses as if they were obtained via node query for each request.
+// Errors with path pointing to specific object are assigned only to corresponding requests.
+func (nb *nodesBatch) split(resp map[string]interface{}, errs gqlerrors.ErrorList) (map[int]map[string]interface{}, map[int]gqlerrors.ErrorList) {
+	nodes, isList := resp[common.NodesFieldName].([]interface{})
+
+	posErrs := make(map[int]gqlerrors.ErrorList)
+	var commonErrs gqlerrors.ErrorList
+	for _, e := range errs {
+		pos, cpy, ok := nb.splitError(e)
+		if !ok {
+			commonErrs = append(commonErrs, cpy)
+			continue
+		}
+		posErrs[pos] = append(posErrs[pos], cpy)
+	}
+
+	resps := make(map[int]map[string]interface{}, len(nb.indexes))
+	respErrs := make(map[int]gqlerrors.ErrorList, len(nb.indexes))
+	for index, pos := range nb.indexes {
+		switch {
+		case isList && pos < len(nodes):
+			resps[index] = map[string]interface{}{
+				common.NodeFieldName: nodes[pos],
+			}
+		case resp[common.NodesFieldName] == nil && len(errs) != 0:
+			// nodes are nulled by remote service because of errors
+			resps[index] = map[string]interface{}{
+				common.NodeFieldName: nil,
+			}
+		default:
+			// no node key in response, so request is considered as failed
+			resps[index] = make(map[string]interface{})
+		}
+
+		if len(commonErrs)+len(posErrs[pos]) > 0 {
+			respErrs[index] = append(append(gqlerrors.ErrorList{}, commonErrs...), posErrs[pos]...)
+		}
+	}
+
+	return resps, respErrs
+}
+
+// splitError converts error of nodes query into error of node query, returning position of the object it's related to.
+// F.e. path ["nodes", 2, "books"] becomes ["node", "books"] with position 2.
+func (nb *nodesBatch) splitError(e *gqlerrors.Error) (int, *gqlerrors.Error, bool) {
+	cpy := *e
+	if len(e.Path) == 0 || e.Path[0] != common.NodesFieldName {
+		return 0, &cpy, false
+	}
+
+	cpy.Path = append([]interface{}{common.NodeFieldName}, e.Path[1:]...)
+	if len(e.Path) < 2 {
+		return 0, &cpy, false
+	}
+
+	var pos int
+	switch v := e.Path[1].(type) {
+	case int:
+		pos = v
+	case float64:
+		pos = int(v)
+	default:
+		return 0, &cpy, false
+	}
+
+	cpy.Path = append([]interface{}{common.NodeFieldName}, e.Path[2:]...)
+	return pos, &cpy, true
+}
+
+// isNodesBatchingEnabled returns true if request can be fetched via nodes query
+func (de *DepthExecutor) isNodesBatchingEnabled(req *ExecutionRequest) bool {
+	step := req.QueryPlanStep
+	if step.NodesQueryString == "" || common.IsRootObjectName(step.ParentType) {
+		return false
+	}
+
+	_, ok := de.ctx.NodesBatchingURLs[step.URL]
+	return ok
+}
@@ -0,0 +1,129 @@
+package executor
+
+import (
+	"testing"
+
+	"github.com/buildbuildio/pebbles/common"
+	"github.com/buildbuildio/pebbles/gqlerrors"
+	"github.com/buildbuildio/pebbles/queryer"
+	"github.com/buildbuildio/pebbles/requests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+func TestExecutorNodesBatching(t *testing.T) {
+	queryers := usersQueryers(nil, nil)
+	queryers["0"] = &MockSuccessQueryer{
+		Value: map[string]interface{}{
+			"users": []interface{}{
+				map[string]interface{}{"id": "1", "name": "first"},
+				map[string]interface{}{"id": "2", "name": "second"},
+				map[string]interface{}{"id": "1", "name": "first"},
+				map[string]interface{}{"id": "3", "name": "third"},
+			},
+		},
+	}
+	queryers["1"] = MockQueryerFunc{
+		F: func(inputs []*requests.Request) ([]map[string]interface{}, error) {
+			require.Len(t, inputs, 1)
+			assert.Contains(t, inputs[0].Query, "nodes(ids: $ids)")
+			assert.Equal(t, []interface{}{"1", "2", "3"}, inputs[0].Variables[common.IDsFieldName])
+			assert.NotContains(t, inputs[0].Variables, common.IDFieldName)
+
+			return []map[string]interface{}{{
+				common.NodesFieldName: []interface{}{
+					map[string]interface{}{"address": map[string]interface{}{"street": "street 1"}},
+					map[string]interface{}{"address": nil},
+					map[string]interface{}{"address": map[string]interface{}{"street": "street 3"}},
+				},
+			}}, queryer.ResponseErrors{{{
+				Message: "address failed",
+				Path:    []interface{}{common.NodesFieldName, float64(1), "address"},
+			}}}
+		},
+	}
+
+	actual, errs := mustExecutePartial(t, &ExecutionContext{
+		Queryers: queryers,
+		Request:  testRequest,
+		QueryPlan: usersPlan(
+			ast.ListType(ast.NamedType("User", nil), nil),
+			ast.NamedType("Address", nil),
+		),
+		NodesBatchingURLs: map[string]struct{}{"1": {}},
+	})
+
+	assert.JSONEq(t, `{
+		"users": [
+			{"id": "1", "name": "first", "address": {"street": "street 1"}},
+			{"id": "2", "name": "second", "address": null},
+			{"id": "1", "name": "first", "address": {"street": "street 1"}},
+			{"id": "3", "name": "third", "address": {"street": "street 3"}}
+		],
+		"other": "value"
+	}`, actual)
+
+	require.Len(t, errs, 1)
+	assert.Equal(t, "address failed", errs[0].Message)
+	assert.Equal(t, []interface{}{"users", 1, "address"}, errs[0].Path)
+}
+
+func TestExecutorNodesBatchingFallback(t *testing.T) {
+	var calls int
+	queryers := usersQueryers(nil, nil)
+	queryers["1"] = MockQueryerFunc{
+		F: func(inputs []*requests.Request) ([]map[string]interface{}, error) {
+			calls++
+			require.Len(t, inputs, 2)
+
+			var res []map[string]interface{}
+			for _, input := range inputs {
+				assert.Contains(t, input.Query, "node(id: $id)")
+				res = append(res, map[string]interface{}{
+					common.NodeFieldName: map[string]interface{}{
+						"address": map[string]interface{}{"street": "street " + input.Variables[common.IDFieldName].(string)},
+					},
+				})
+			}
+			return res, nil
+		},
+	}
+
+	mustCheckEqual(t, &ExecutionContext{
+		Queryers: queryers,
+		Request:  testRequest,
+		QueryPlan: usersPlan(
+			ast.ListType(ast.NamedType("User", nil), nil),
+			ast.NamedType("Address", nil),
+		),
+		// service 1 doesn't support nodes query
+		NodesBatchingURLs: map[string]struct{}{"0": {}},
+	}, `{
+		"users": [
+			{"id": "1", "name": "first", "address": {"street": "street 1"}},
+			{"id": "2", "name": "second", "address": {"street": "street 2"}}
+		],
+		"other": "value"
+	}`)
+
+	assert.Equal(t, 1, calls)
+}
+
+func TestNodesBatchSplitNullNodes(t *testing.T) {
+	nb := &nodesBatch{
+		indexes: map[int]int{0: 0, 1: 1, 2: 0},
+	}
+
+	resps, errs := nb.split(
+		map[string]interface{}{common.NodesFieldName: nil},
+		gqlerrors.ErrorList{{Message: "failed", Path: []interface{}{common.NodesFieldName}}},
+	)
+
+	require.Len(t, resps, 3)
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, map[string]interface{}{common.NodeFieldName: nil}, resps[i])
+		require.Len(t, errs[i], 1)
+		assert.Equal(t, []interface{}{common.NodeFieldName}, errs[i][0].Path)
+	}
+}
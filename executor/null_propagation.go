@@ -0,0 +1,240 @@
+package executor
+
+import (
+	"strings"
+
+	"github.com/buildbuildio/pebbles/common"
+	"github.com/buildbuildio/pebbles/planner"
+
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// fieldTypes maps response path of the field (without list indexes and ids) to the type of the field.
+// It's used to determine how far null should be propagated when some request fails.
+type fieldTypes map[string]*ast.Type
+
+func collectFieldTypes(steps []*planner.QueryPlanStep) fieldTypes {
+	ft := make(fieldTypes)
+	for _, step := range steps {
+		ft.collectStep(step)
+	}
+	return ft
+}
+
+func (ft fieldTypes) collectStep(step *planner.QueryPlanStep) {
+	ft.collect(step.InsertionPoint, stepSelectionSet(step))
+
+	for _, then := range step.Then {
+		ft.collectStep(then)
+	}
+}
+
+func (ft fieldTypes) collect(path []string, selectionSet ast.SelectionSet) {
+	for _, f := range common.SelectionSetToFields(selectionSet, nil) {
+		if f.Definition == nil {
+			continue
+		}
+
+		fieldPath := make([]string, len(path), len(path)+1)
+		copy(fieldPath, path)
+		fieldPath = append(fieldPath, getFieldDisplayName(f))
+
+		key := strings.Join(fieldPath, ".")
+		if _, ok := ft[key]; !ok {
+			ft[key] = f.Definition.Type
+		}
+
+		ft.collect(fieldPath, f.SelectionSet)
+	}
+}
+
+// stepSelectionSet returns selection set of the step as it's inserted into result, i.e. without wrapping node query
+func stepSelectionSet(step *planner.QueryPlanStep) ast.SelectionSet {
+	if common.IsRootObjectName(step.ParentType) || len(step.SelectionSet) != 1 {
+		return step.SelectionSet
+	}
+
+	if f, ok := step.SelectionSet[0].(*ast.Field); ok && f.Name == common.NodeFieldName {
+		return f.SelectionSet
+	}
+
+	return step.SelectionSet
+}
+
+// firstResponseFieldName returns name of the first field step is responsible for
+func firstResponseFieldName(step *planner.QueryPlanStep) string {
+	if step == nil {
+		return ""
+	}
+
+	for _, f := range common.SelectionSetToFields(stepSelectionSet(step), nil) {
+		if common.IsBuiltinName(f.Name) {
+			continue
+		}
+		return getFieldDisplayName(f)
+	}
+
+	return ""
+}
+
+// affectedFields returns fields of selection set which are missing in target.
+// It also returns true if any of them is non null.
+func affectedFields(selectionSet ast.SelectionSet, parentType string, target map[string]interface{}) ([]string, bool) {
+	var res []string
+	var isNonNull bool
+
+	typename, hasTypename := target[common.TypenameFieldName]
+
+	for _, selection := range selectionSet {
+		switch selection := selection.(type) {
+		case *ast.Field:
+			if common.IsBuiltinName(selection.Name) {
+				continue
+			}
+
+			name := getFieldDisplayName(selection)
+			// value was provided by other request
+			if v, ok := target[name]; ok && v != nil {
+				continue
+			}
+
+			res = append(res, name)
+			if selection.Definition != nil && selection.Definition.Type.NonNull {
+				isNonNull = true
+			}
+		case *ast.InlineFragment:
+			// fragment for other implementation of interface or union
+			if hasTypename && selection.TypeCondition != "" && selection.TypeCondition != parentType && selection.TypeCondition != typename {
+				continue
+			}
+
+			fields, isFragmentNonNull := affectedFields(selection.SelectionSet, parentType, target)
+			res = append(res, fields...)
+			isNonNull = isNonNull || isFragmentNonNull
+		}
+	}
+
+	return res, isNonNull
+}
+
+// getObject returns the object located by insertion point.
+// It returns false if object is missing or nulled.
+func (dem *DepthExecutorManager) getObject(insertionPoint []string) (map[string]interface{}, bool) {
+	recent := dem.result
+	if recent == nil {
+		return nil, false
+	}
+
+	for _, point := range insertionPoint {
+		pointData, err := dem.pointDataExtractor.Extract(point)
+		if err != nil {
+			return nil, false
+		}
+
+		value, ok := recent[pointData.Field]
+		if !ok || value == nil {
+			return nil, false
+		}
+
+		if pointData.Index >= 0 {
+			list, ok := value.([]interface{})
+			if !ok || pointData.Index >= len(list) {
+				return nil, false
+			}
+			value = list[pointData.Index]
+		}
+
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		recent = obj
+	}
+
+	return recent, true
+}
+
+func (dem *DepthExecutorManager) pathKey(insertionPoint []string) string {
+	names := make([]string, len(insertionPoint))
+	for i, point := range insertionPoint {
+		names[i] = point
+		if pointData, err := dem.pointDataExtractor.Extract(point); err == nil {
+			names[i] = pointData.Field
+		}
+	}
+
+	return strings.Join(names, ".")
+}
+
+// nullify sets fields of failed requests to null.
+// If any of the fields is non null, null is propagated to the nearest nullable parent as the GraphQL spec requires.
+func (dem *DepthExecutorManager) nullify(ers []*ExecutionRequest) {
+	if len(ers) == 0 {
+		return
+	}
+
+	if dem.fieldTypes == nil {
+		dem.fieldTypes = collectFieldTypes(dem.ctx.QueryPlan.RootSteps)
+	}
+
+	for _, er := range ers {
+		target, ok := dem.getObject(er.InsertionPoint)
+		if !ok {
+			// already nulled
+			continue
+		}
+
+		fields, isNonNull := affectedFields(stepSelectionSet(er.QueryPlanStep), er.QueryPlanStep.ParentType, target)
+		if isNonNull {
+			dem.nullifyPath(er.InsertionPoint)
+			continue
+		}
+
+		for _, f := range fields {
+			target[f] = nil
+		}
+	}
+}
+
+// nullifyPath sets value located by insertion point to null going up while values are non null.
+// If root is reached, the whole result becomes null.
+func (dem *DepthExecutorManager) nullifyPath(insertionPoint []string) {
+	for i := len(insertionPoint); i > 0; i-- {
+		parent, ok := dem.getObject(insertionPoint[:i-1])
+		if !ok {
+			return
+		}
+
+		pointData, err := dem.pointDataExtractor.Extract(insertionPoint[i-1])
+		if err != nil {
+			return
+		}
+
+		fieldType := dem.fieldTypes[dem.pathKey(insertionPoint[:i])]
+
+		if pointData.Index >= 0 && fieldType != nil && fieldType.Elem != nil && !fieldType.Elem.NonNull {
+			if list, ok := parent[pointData.Field].([]interface{}); ok && pointData.Index < len(list) {
+				list[pointData.Index] = nil
+				return
+			}
+		}
+
+		if fieldType == nil || !fieldType.NonNull {
+			parent[pointData.Field] = nil
+			return
+		}
+	}
+
+	dem.result = nil
+}
+
+// filterNulled removes requests which are inserted into nulled objects
+func (dem *DepthExecutorManager) filterNulled(ers []*ExecutionRequest) []*ExecutionRequest {
+	var res []*ExecutionRequest
+	for _, er := range ers {
+		if _, ok := dem.getObject(er.InsertionPoint); ok {
+			res = append(res, er)
+		}
+	}
+	return res
+}
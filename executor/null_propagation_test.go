@@ -0,0 +1,337 @@
+package executor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/buildbuildio/pebbles/common"
+	"github.com/buildbuildio/pebbles/gqlerrors"
+	"github.com/buildbuildio/pebbles/planner"
+	"github.com/buildbuildio/pebbles/queryer"
+	"github.com/buildbuildio/pebbles/requests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+func mustExecutePartial(t *testing.T, ctx *ExecutionContext) (string, gqlerrors.ErrorList) {
+	t.Helper()
+
+	pc := &planner.PlanningContext{
+		Operation: &ast.OperationDefinition{
+			Name:      *ctx.Request.OperationName,
+			Operation: ast.Query,
+		},
+	}
+	ctx.QueryPlan = ctx.QueryPlan.SetComputedValues(pc)
+	result, err := parallelExecutor.Execute(ctx)
+	require.Error(t, err)
+
+	errs, ok := err.(gqlerrors.ErrorList)
+	require.True(t, ok)
+
+	b, err := json.Marshal(result)
+	require.NoError(t, err)
+
+	return string(b), errs
+}
+
+// usersPlan is equivalent to
+//
+//	{
+//		users {              <- from serviceA
+//			id
+//			name             <- from serviceA
+//			address {        <- from serviceB
+//				street
+//			}
+//		}
+//		other                <- from serviceC
+//	}
+func usersPlan(usersType *ast.Type, addressType *ast.Type) *planner.QueryPlan {
+	return &planner.QueryPlan{
+		RootSteps: []*planner.QueryPlanStep{{
+			URL:        "0",
+			ParentType: "Query",
+			SelectionSet: ast.SelectionSet{
+				&ast.Field{
+					Name:  "users",
+					Alias: "users",
+					Definition: &ast.FieldDefinition{
+						Type: usersType,
+					},
+					SelectionSet: ast.SelectionSet{
+						&ast.Field{
+							Name: "id",
+							Definition: &ast.FieldDefinition{
+								Type: ast.NonNullNamedType("ID", nil),
+							},
+						},
+						&ast.Field{
+							Name: "name",
+							Definition: &ast.FieldDefinition{
+								Type: ast.NamedType("String", nil),
+							},
+						},
+					},
+				},
+			},
+			Then: []*planner.QueryPlanStep{{
+				URL:            "1",
+				ParentType:     "User",
+				InsertionPoint: []string{"users"},
+				SelectionSet: selectionSetWithNodeDef(ast.SelectionSet{
+					&ast.InlineFragment{
+						TypeCondition: "User",
+						SelectionSet: ast.SelectionSet{
+							&ast.Field{
+								Name:  "address",
+								Alias: "address",
+								Definition: &ast.FieldDefinition{
+									Type: addressType,
+								},
+								SelectionSet: ast.SelectionSet{
+									&ast.Field{
+										Name: "street",
+										Definition: &ast.FieldDefinition{
+											Type: ast.NamedType("String", nil),
+										},
+									},
+								},
+							},
+						},
+					},
+				}),
+			}},
+		}, {
+			URL:        "2",
+			ParentType: "Query",
+			SelectionSet: ast.SelectionSet{
+				&ast.Field{
+					Name:  "other",
+					Alias: "other",
+					Definition: &ast.FieldDefinition{
+						Type: ast.NamedType("String", nil),
+					},
+				},
+			},
+		}},
+	}
+}
+
+func usersQueryers(otherErr error, addressFunc func(id interface{}) (map[string]interface{}, gqlerrors.ErrorList)) map[string]queryer.Queryer {
+	return map[string]queryer.Queryer{
+		"0": &MockSuccessQueryer{
+			Value: map[string]interface{}{
+				"users": []interface{}{
+					map[string]interface{}{"id": "1", "name": "first"},
+					map[string]interface{}{"id": "2", "name": "second"},
+				},
+			},
+		},
+		"1": MockQueryerFunc{
+			F: func(inputs []*requests.Request) ([]map[string]interface{}, error) {
+				var res []map[string]interface{}
+				respErrs := make(queryer.ResponseErrors, len(inputs))
+				for i, input := range inputs {
+					data, errs := addressFunc(input.Variables[common.IDFieldName])
+					res = append(res, data)
+					respErrs[i] = errs
+				}
+				if respErrs.IsEmpty() {
+					return res, nil
+				}
+				return res, respErrs
+			},
+		},
+		"2": MockQueryerFunc{
+			F: func(inputs []*requests.Request) ([]map[string]interface{}, error) {
+				if otherErr != nil {
+					return nil, otherErr
+				}
+				return []map[string]interface{}{{"other": "value"}}, nil
+			},
+		},
+	}
+}
+
+func failingAddress(failedID string) func(id interface{}) (map[string]interface{}, gqlerrors.ErrorList) {
+	return func(id interface{}) (map[string]interface{}, gqlerrors.ErrorList) {
+		if id == failedID {
+			return map[string]interface{}{common.NodeFieldName: nil}, gqlerrors.ErrorList{{
+				Message: "address failed",
+				Path:    []interface{}{common.NodeFieldName, "address"},
+			}}
+		}
+		return map[string]interface{}{
+			common.NodeFieldName: map[string]interface{}{
+				"address": map[string]interface{}{"street": "street " + id.(string)},
+			},
+		}, nil
+	}
+}
+
+func TestExecutorPartialResultRootFailed(t *testing.T) {
+	actual, errs := mustExecutePartial(t, &ExecutionContext{
+		Queryers: usersQueryers(errors.New("service unavailable"), failingAddress("")),
+		Request:  testRequest,
+		QueryPlan: usersPlan(
+			ast.ListType(ast.NamedType("User", nil), nil),
+			ast.NamedType("Address", nil),
+		),
+	})
+
+	assert.JSONEq(t, `{
+		"users": [
+			{"id": "1", "name": "first", "address": {"street": "street 1"}},
+			{"id": "2", "name": "second", "address": {"street": "street 2"}}
+		],
+		"other": null
+	}`, actual)
+
+	require.Len(t, errs, 1)
+	assert.Equal(t, "service unavailable", errs[0].Message)
+	assert.Equal(t, []interface{}{"other"}, errs[0].Path)
+}
+
+func TestExecutorPartialResultNullableField(t *testing.T) {
+	actual, errs := mustExecutePartial(t, &ExecutionContext{
+		Queryers: usersQueryers(nil, failingAddress("2")),
+		Request:  testRequest,
+		QueryPlan: usersPlan(
+			ast.ListType(ast.NamedType("User", nil), nil),
+			ast.NamedType("Address", nil),
+		),
+	})
+
+	assert.JSONEq(t, `{
+		"users": [
+			{"id": "1", "name": "first", "address": {"street": "street 1"}},
+			{"id": "2", "name": "second", "address": null}
+		],
+		"other": "value"
+	}`, actual)
+
+	require.Len(t, errs, 1)
+	assert.Equal(t, "address failed", errs[0].Message)
+	assert.Equal(t, []interface{}{"users", 1, "address"}, errs[0].Path)
+	assert.Equal(t, "1", errs[0].Extensions[gqlerrors.ServiceExtension])
+}
+
+func TestExecutorPartialResultWrappedErrors(t *testing.T) {
+	queryers := usersQueryers(nil, failingAddress("2"))
+	addressQueryer := queryers["1"].(MockQueryerFunc)
+	queryers["1"] = MockQueryerFunc{
+		F: func(inputs []*requests.Request) ([]map[string]interface{}, error) {
+			res, err := addressQueryer.F(inputs)
+			if err != nil {
+				err = fmt.Errorf("query failed: %w", err)
+			}
+			return res, err
+		},
+	}
+
+	actual, errs := mustExecutePartial(t, &ExecutionContext{
+		Queryers: queryers,
+		Request:  testRequest,
+		QueryPlan: usersPlan(
+			ast.ListType(ast.NamedType("User", nil), nil),
+			ast.NamedType("Address", nil),
+		),
+	})
+
+	// results of other requests of the group are kept
+	assert.JSONEq(t, `{
+		"users": [
+			{"id": "1", "name": "first", "address": {"street": "street 1"}},
+			{"id": "2", "name": "second", "address": null}
+		],
+		"other": "value"
+	}`, actual)
+
+	require.Len(t, errs, 1)
+	assert.Equal(t, "address failed", errs[0].Message)
+}
+
+func TestExecutorPartialResultNullableListElement(t *testing.T) {
+	actual, _ := mustExecutePartial(t, &ExecutionContext{
+		Queryers: usersQueryers(nil, failingAddress("2")),
+		Request:  testRequest,
+		QueryPlan: usersPlan(
+			ast.NonNullListType(ast.NamedType("User", nil), nil),
+			ast.NonNullNamedType("Address", nil),
+		),
+	})
+
+	assert.JSONEq(t, `{
+		"users": [
+			{"id": "1", "name": "first", "address": {"street": "street 1"}},
+			null
+		],
+		"other": "value"
+	}`, actual)
+}
+
+func TestExecutorPartialResultNullableList(t *testing.T) {
+	actual, _ := mustExecutePartial(t, &ExecutionContext{
+		Queryers: usersQueryers(nil, failingAddress("2")),
+		Request:  testRequest,
+		QueryPlan: usersPlan(
+			ast.ListType(ast.NonNullNamedType("User", nil), nil),
+			ast.NonNullNamedType("Address", nil),
+		),
+	})
+
+	assert.JSONEq(t, `{
+		"users": null,
+		"other": "value"
+	}`, actual)
+}
+
+func TestExecutorPartialResultNullRoot(t *testing.T) {
+	actual, errs := mustExecutePartial(t, &ExecutionContext{
+		Queryers: usersQueryers(nil, failingAddress("2")),
+		Request:  testRequest,
+		QueryPlan: usersPlan(
+			ast.NonNullListType(ast.NonNullNamedType("User", nil), nil),
+			ast.NonNullNamedType("Address", nil),
+		),
+	})
+
+	assert.Equal(t, "null", actual)
+	assert.Len(t, errs, 1)
+}
+
+func TestExecutorPartialResultSkipsChildrenOfNulled(t *testing.T) {
+	plan := usersPlan(
+		ast.ListType(ast.NonNullNamedType("User", nil), nil),
+		ast.NonNullNamedType("Address", nil),
+	)
+	// users are fetched, but not their addresses
+	plan.RootSteps[0].Then[0].Then = []*planner.QueryPlanStep{{
+		URL:            "3",
+		ParentType:     "Address",
+		InsertionPoint: []string{"users", "address"},
+	}}
+
+	queryers := usersQueryers(nil, failingAddress("2"))
+	queryers["3"] = MockQueryerFunc{
+		F: func(inputs []*requests.Request) ([]map[string]interface{}, error) {
+			require.FailNow(t, "should not query children of nulled object")
+			return nil, nil
+		},
+	}
+
+	actual, _ := mustExecutePartial(t, &ExecutionContext{
+		Queryers:  queryers,
+		Request:   testRequest,
+		QueryPlan: plan,
+	})
+
+	assert.JSONEq(t, `{
+		"users": null,
+		"other": "value"
+	}`, actual)
+}
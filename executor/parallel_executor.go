@@ -29,17 +29,68 @@
 
 package executor
 
+import (
+	"github.com/buildbuildio/pebbles/common"
+	"github.com/buildbuildio/pebbles/gqlerrors"
+	"github.com/buildbuildio/pebbles/planner"
+)
+
 // ParallelExecutor executes the given query plan by starting at the root of the plan and
 // walking down the path stitching the results together
 type ParallelExecutor func(*ExecutionContext) (map[string]interface{}, error)
 
-// Execute returns the result of the query plan
+// Execute returns the result of the query plan. If some of the requests fail,
+// partial result is returned along with the errors.
 // for more information about usage, take a look at tests.
 func (executor ParallelExecutor) Execute(ctx *ExecutionContext) (map[string]interface{}, error) {
+	if isMutationPlan(ctx.QueryPlan) {
+		return executeSerially(ctx)
+	}
+
 	manager := NewDepthExecutorManager(ctx)
-	res, err := manager.Execute()
-	if err != nil {
-		return nil, err
+	return manager.Execute()
+}
+
+func isMutationPlan(qp *planner.QueryPlan) bool {
+	return len(qp.RootSteps) > 1 && qp.RootSteps[0].ParentType == common.MutationObjectName
+}
+
+// executeSerially executes root steps one by one in the order of the plan.
+// Each root step is executed along with all its dependent steps before the next one starts,
+// as top-level mutation fields must be executed serially.
+func executeSerially(ctx *ExecutionContext) (map[string]interface{}, error) {
+	result := ctx.InitialResult
+	if result == nil {
+		result = make(map[string]interface{})
 	}
-	return res, nil
+
+	var errs gqlerrors.ErrorList
+	for _, step := range ctx.QueryPlan.RootSteps {
+		stepCtx := *ctx
+		stepCtx.QueryPlan = &planner.QueryPlan{
+			RootSteps:   []*planner.QueryPlanStep{step},
+			ScrubFields: ctx.QueryPlan.ScrubFields,
+		}
+		stepCtx.InitialResult = result
+		// deferred parts of mutations are executed along with the rest
+		stepCtx.Incremental = nil
+
+		res, err := NewDepthExecutorManager(&stepCtx).Execute()
+		if err != nil {
+			errs = gqlerrors.ExtendErrorList(errs, err)
+		}
+
+		// error propagated up to the root, remaining mutations must not be executed
+		if res == nil {
+			return nil, errs
+		}
+
+		result = res
+	}
+
+	if len(errs) != 0 {
+		return result, errs
+	}
+
+	return result, nil
 }
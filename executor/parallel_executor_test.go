@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/buildbuildio/pebbles/common"
@@ -1296,3 +1297,104 @@ func TestExecutorPlansWithManyDeepDependencies(t *testing.T) {
 		}
 	}`)
 }
+
+func TestExecutorMutationSerial(t *testing.T) {
+	var calls []string
+	var mu sync.Mutex
+	recordingQueryer := func(url string, value map[string]interface{}) queryer.Queryer {
+		return MockQueryerFunc{
+			F: func(inputs []*requests.Request) ([]map[string]interface{}, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				calls = append(calls, url)
+				if value == nil {
+					return nil, errors.New("failed")
+				}
+				return []map[string]interface{}{value}, nil
+			},
+		}
+	}
+
+	mutationStep := func(url, field string, fieldType *ast.Type, then ...*planner.QueryPlanStep) *planner.QueryPlanStep {
+		return &planner.QueryPlanStep{
+			URL:        url,
+			ParentType: "Mutation",
+			SelectionSet: ast.SelectionSet{
+				&ast.Field{
+					Name:  field,
+					Alias: field,
+					Definition: &ast.FieldDefinition{
+						Type: fieldType,
+					},
+				},
+			},
+			Then: then,
+		}
+	}
+
+	createPlan := func() *planner.QueryPlan {
+		return &planner.QueryPlan{
+			RootSteps: []*planner.QueryPlanStep{
+				mutationStep("0", "createOrder", ast.NamedType("Order", nil), &planner.QueryPlanStep{
+					URL:            "1",
+					ParentType:     "Order",
+					InsertionPoint: []string{"createOrder"},
+					SelectionSet: selectionSetWithNodeDef(ast.SelectionSet{
+						&ast.InlineFragment{
+							TypeCondition: "Order",
+							SelectionSet: ast.SelectionSet{
+								&ast.Field{
+									Name: "status",
+									Definition: &ast.FieldDefinition{
+										Type: ast.NamedType("String", nil),
+									},
+								},
+							},
+						},
+					}),
+				}),
+				mutationStep("2", "chargeCard", ast.NonNullNamedType("Boolean", nil)),
+				mutationStep("3", "sendEmail", ast.NamedType("Boolean", nil)),
+			},
+		}
+	}
+
+	t.Run("ordered", func(t *testing.T) {
+		calls = nil
+		mustCheckEqual(t, &ExecutionContext{
+			Queryers: map[string]queryer.Queryer{
+				"0": recordingQueryer("0", map[string]interface{}{"createOrder": map[string]interface{}{"id": "1"}}),
+				"1": recordingQueryer("1", map[string]interface{}{"node": map[string]interface{}{"status": "new"}}),
+				"2": recordingQueryer("2", map[string]interface{}{"chargeCard": true}),
+				"3": recordingQueryer("3", map[string]interface{}{"sendEmail": true}),
+			},
+			Request:   testRequest,
+			QueryPlan: createPlan(),
+		}, `{
+			"createOrder": {"id": "1", "status": "new"},
+			"chargeCard": true,
+			"sendEmail": true
+		}`)
+
+		assert.Equal(t, []string{"0", "1", "2", "3"}, calls)
+	})
+
+	t.Run("stops on null root", func(t *testing.T) {
+		calls = nil
+		result, err := parallelExecutor.Execute(&ExecutionContext{
+			Queryers: map[string]queryer.Queryer{
+				"0": recordingQueryer("0", map[string]interface{}{"createOrder": map[string]interface{}{"id": "1"}}),
+				"1": recordingQueryer("1", nil),
+				"2": recordingQueryer("2", nil),
+				"3": recordingQueryer("3", map[string]interface{}{"sendEmail": true}),
+			},
+			Request:   testRequest,
+			QueryPlan: createPlan(),
+		})
+		assert.Error(t, err)
+		assert.Nil(t, result)
+
+		// nullable field failed, but execution continued until non-null field failed
+		assert.Equal(t, []string{"0", "1", "2"}, calls)
+	})
+}
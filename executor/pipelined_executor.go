@@ -0,0 +1,187 @@
+// PipelinedExecutor doesn't wait for the whole depth to finish before starting the next one.
+// Dependent steps of the request are scheduled as soon as its result is merged, so slow service under one branch
+// of the plan doesn't hold back other branches.
+//
+// Scheme as example (see parallel_executor.go):
+//
+// Plan 1 (users {id name}) -> Plan 2 (users {books {id name}})
+//                          -> Plan 3 (users {permissions {id}})
+// Plan 4 (shops {id})      -> Plan 5 (shops {inventory {id}})
+//
+// Plan 5 starts right after Plan 4 responds, even if Plan 1 is still running.
+//
+// To keep benefits of batching, requests to the same service scheduled within BatchWindow are sent in single batch.
+
+package executor
+
+import (
+	"sync"
+	"time"
+
+	"github.com/buildbuildio/pebbles/gqlerrors"
+	"github.com/buildbuildio/pebbles/tracing"
+)
+
+// PipelinedExecutor executes the query plan scheduling each step as soon as the step it depends on is merged
+type PipelinedExecutor struct {
+	// BatchWindow is the time requests to the same service are accumulated before they're sent as single batch.
+	// With zero window only requests scheduled at once, f.e. dependent steps of the same response, are batched.
+	BatchWindow time.Duration
+}
+
+// Execute returns the result of the query plan. If some of the requests fail,
+// partial result is returned along with the errors.
+// Mutations and operations delivered incrementally are executed by depths, as ParallelExecutor does.
+func (executor PipelinedExecutor) Execute(ctx *ExecutionContext) (map[string]interface{}, error) {
+	if isMutationPlan(ctx.QueryPlan) || ctx.Incremental != nil {
+		var e ParallelExecutor
+		return e.Execute(ctx)
+	}
+
+	p := newPipeline(ctx, executor.BatchWindow)
+	return p.execute()
+}
+
+// pipeline accumulates results of the requests executed concurrently
+type pipeline struct {
+	// dem holds the result along with helpers to merge and nullify it
+	dem      *DepthExecutorManager
+	executor *DepthExecutor
+	window   time.Duration
+
+	// mutex guards the result, the errors and the pending batches
+	mutex   sync.Mutex
+	errs    gqlerrors.ErrorList
+	batches map[string][]*ExecutionRequest
+	wg      sync.WaitGroup
+}
+
+func newPipeline(ctx *ExecutionContext, window time.Duration) *pipeline {
+	dem := NewDepthExecutorManager(ctx)
+
+	return &pipeline{
+		dem: dem,
+		executor: &DepthExecutor{
+			ctx:                ctx,
+			PointDataExtractor: dem.pointDataExtractor,
+		},
+		window:  window,
+		batches: make(map[string][]*ExecutionRequest),
+	}
+}
+
+func (p *pipeline) execute() (map[string]interface{}, error) {
+	executionRequests := make([]*ExecutionRequest, 0, len(p.dem.ctx.QueryPlan.RootSteps))
+	for _, step := range p.dem.ctx.QueryPlan.RootSteps {
+		insertionPoint := []string{}
+		if step.InsertionPoint != nil {
+			insertionPoint = step.InsertionPoint
+		}
+
+		executionRequests = append(executionRequests, &ExecutionRequest{
+			QueryPlanStep:  step,
+			InsertionPoint: insertionPoint,
+		})
+	}
+
+	p.schedule(executionRequests)
+	p.wg.Wait()
+
+	if len(p.errs) != 0 {
+		return p.dem.result, p.errs
+	}
+
+	return p.dem.result, nil
+}
+
+// schedule adds requests to pending batches of their services. New batch is sent after the window passes.
+func (p *pipeline) schedule(ers []*ExecutionRequest) {
+	if len(ers) == 0 {
+		return
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for _, er := range ers {
+		url := er.QueryPlanStep.URL
+		if _, ok := p.batches[url]; !ok {
+			p.wg.Add(1)
+			time.AfterFunc(p.window, func() { p.flush(url) })
+		}
+		p.batches[url] = append(p.batches[url], er)
+	}
+}
+
+// flush executes pending batch of the service, merges its results and schedules dependent requests
+func (p *pipeline) flush(url string) {
+	defer p.wg.Done()
+
+	p.mutex.Lock()
+	ers := p.batches[url]
+	delete(p.batches, url)
+	p.mutex.Unlock()
+
+	next := p.executeBatch(url, ers)
+
+	// dependent requests are scheduled before the batch is done, so execute doesn't return prematurely
+	p.schedule(next)
+}
+
+// executeBatch executes requests to single service and merges their results.
+// It returns dependent requests, which must be executed next.
+func (p *pipeline) executeBatch(url string, ers []*ExecutionRequest) []*ExecutionRequest {
+	ctx, span := tracing.Start(p.dem.ctx.Request.Context(), "graphql.batch")
+	span.SetAttributes(
+		tracing.String("http.url", url),
+		tracing.Int("graphql.requests", len(ers)),
+	)
+	defer span.End()
+
+	exResp, err := p.executor.withQueryContext(ctx).Execute(ers)
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	// result was nulled up to the root by another batch
+	if p.dem.result == nil {
+		return nil
+	}
+
+	if err != nil {
+		span.RecordError(err)
+		p.errs = gqlerrors.ExtendErrorList(p.errs, err)
+		return nil
+	}
+
+	if len(exResp.Errors) != 0 {
+		span.RecordError(exResp.Errors)
+		p.errs = append(p.errs, exResp.Errors...)
+	}
+
+	// requests of the batch may point to objects nulled by other batches meanwhile
+	exResp.ExecutionResults = p.filterNulledResults(exResp.ExecutionResults)
+
+	if err := p.dem.merge(exResp); err != nil {
+		p.errs = gqlerrors.ExtendErrorList(p.errs, err)
+		return nil
+	}
+
+	p.dem.nullify(exResp.FailedExecutionRequests)
+	if p.dem.result == nil {
+		return nil
+	}
+
+	return p.dem.filterNulled(exResp.NextExecutionRequests)
+}
+
+// filterNulledResults removes results which are inserted into nulled objects
+func (p *pipeline) filterNulledResults(results []*ExecutionResult) []*ExecutionResult {
+	var res []*ExecutionResult
+	for _, r := range results {
+		if _, ok := p.dem.getObject(r.InsertionPoint); ok {
+			res = append(res, r)
+		}
+	}
+	return res
+}
@@ -0,0 +1,215 @@
+package executor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/buildbuildio/pebbles/merger"
+	"github.com/buildbuildio/pebbles/planner"
+	"github.com/buildbuildio/pebbles/queryer"
+	"github.com/buildbuildio/pebbles/requests"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+var pipelinedSchema = `
+	interface Node {
+		id: ID!
+	}
+
+	type Movie implements Node {
+		id: ID!
+		title: String!
+		rating: Int!
+	}
+
+	type Author implements Node {
+		id: ID!
+		name: String!
+		bio: String
+	}
+
+	type Query {
+		movies: [Movie!]!
+		authors: [Author!]!
+		node(id: ID!): Node
+	}
+`
+
+var pipelinedTum = merger.TypeURLMap{
+	"Query": {
+		Fields: map[string]string{
+			"movies":  "movies",
+			"authors": "authors",
+		},
+	},
+	"Movie": {
+		Fields: map[string]string{
+			"title":  "movies",
+			"rating": "details",
+		},
+		IsImplementsNode: true,
+	},
+	"Author": {
+		Fields: map[string]string{
+			"name": "authors",
+			"bio":  "details",
+		},
+		IsImplementsNode: true,
+	},
+}
+
+func mustExecutePipelined(t *testing.T, query string, window time.Duration, queryers map[string]queryer.Queryer) (string, error) {
+	t.Helper()
+
+	schema := gqlparser.MustLoadSchema(&ast.Source{Input: pipelinedSchema})
+	operation := gqlparser.MustLoadQuery(schema, query).Operations[0]
+
+	request := &requests.Request{Query: query}
+	plan, err := (planner.SequentialPlanner)(nil).Plan(&planner.PlanningContext{
+		Operation:  operation,
+		Request:    request,
+		Schema:     schema,
+		TypeURLMap: pipelinedTum,
+	})
+	require.NoError(t, err)
+
+	result, err := PipelinedExecutor{BatchWindow: window}.Execute(&ExecutionContext{
+		QueryPlan: plan,
+		Request:   request,
+		Queryers:  queryers,
+	})
+
+	plan.ScrubFields.Clean(result)
+	b, _ := json.Marshal(result)
+	return string(b), err
+}
+
+// rootQueryer responds with list of two objects with ids prefixed by prefix and names (or titles) A and B
+func rootQueryer(field, nameField, prefix string) MockQueryerFunc {
+	return MockQueryerFunc{F: func(inputs []*requests.Request) ([]map[string]interface{}, error) {
+		var res []map[string]interface{}
+		for range inputs {
+			res = append(res, map[string]interface{}{field: []interface{}{
+				map[string]interface{}{"id": prefix + "1", nameField: "A"},
+				map[string]interface{}{"id": prefix + "2", nameField: "B"},
+			}})
+		}
+		return res, nil
+	}}
+}
+
+// detailsQueryer resolves rating of movies and bio of authors, recording sizes of received batches
+type detailsQueryer struct {
+	MockQueryerFunc
+
+	batches []int
+	sync.Mutex
+}
+
+func (q *detailsQueryer) Query(inputs []*requests.Request) ([]map[string]interface{}, error) {
+	q.Lock()
+	q.batches = append(q.batches, len(inputs))
+	q.Unlock()
+
+	var res []map[string]interface{}
+	for _, input := range inputs {
+		id := input.Variables["id"].(string)
+		if strings.Contains(input.Query, "rating") {
+			res = append(res, map[string]interface{}{"node": map[string]interface{}{"rating": len(id)}})
+			continue
+		}
+		res = append(res, map[string]interface{}{"node": map[string]interface{}{"bio": "Bio " + id}})
+	}
+	return res, nil
+}
+
+func TestPipelinedExecutorWithoutDepthBarrier(t *testing.T) {
+	ratingQueried := make(chan struct{})
+	var once sync.Once
+
+	queryers := map[string]queryer.Queryer{
+		"movies": rootQueryer("movies", "title", "m"),
+		// authors service responds only after dependent step of the other branch is executed
+		"authors": MockQueryerFunc{F: func(inputs []*requests.Request) ([]map[string]interface{}, error) {
+			select {
+			case <-ratingQueried:
+			case <-time.After(time.Second):
+				return nil, errors.New("rating wasn't queried")
+			}
+			return rootQueryer("authors", "name", "a").F(inputs)
+		}},
+		"details": MockQueryerFunc{F: func(inputs []*requests.Request) ([]map[string]interface{}, error) {
+			once.Do(func() { close(ratingQueried) })
+
+			var res []map[string]interface{}
+			for range inputs {
+				res = append(res, map[string]interface{}{"node": map[string]interface{}{"rating": 5}})
+			}
+			return res, nil
+		}},
+	}
+
+	actual, err := mustExecutePipelined(t, `{ movies { title rating } authors { name } }`, 0, queryers)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"movies": [{"title": "A", "rating": 5}, {"title": "B", "rating": 5}],
+		"authors": [{"name": "A"}, {"name": "B"}]
+	}`, actual)
+}
+
+func TestPipelinedExecutorBatchWindow(t *testing.T) {
+	details := &detailsQueryer{}
+	queryers := map[string]queryer.Queryer{
+		"movies":  rootQueryer("movies", "title", "m"),
+		"authors": rootQueryer("authors", "name", "a"),
+		"details": details,
+	}
+
+	actual, err := mustExecutePipelined(t, `{ movies { title rating } authors { name bio } }`, 50*time.Millisecond, queryers)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"movies": [{"title": "A", "rating": 2}, {"title": "B", "rating": 2}],
+		"authors": [{"name": "A", "bio": "Bio a1"}, {"name": "B", "bio": "Bio a2"}]
+	}`, actual)
+
+	// requests of both branches are sent together
+	assert.Equal(t, []int{4}, details.batches)
+}
+
+func TestPipelinedExecutorNullPropagation(t *testing.T) {
+	queryers := map[string]queryer.Queryer{
+		"movies":  rootQueryer("movies", "title", "m"),
+		"authors": rootQueryer("authors", "name", "a"),
+		"details": MockQueryerFunc{F: func(inputs []*requests.Request) ([]map[string]interface{}, error) {
+			return nil, fmt.Errorf("details are unavailable")
+		}},
+	}
+
+	actual, err := mustExecutePipelined(t, `{ authors { name bio } movies { title rating } }`, 0, queryers)
+	require.Error(t, err)
+
+	// non null rating nulls the whole result, as movies list is non null as well
+	assert.Equal(t, "null", actual)
+}
+
+func TestPipelinedExecutorNullableField(t *testing.T) {
+	queryers := map[string]queryer.Queryer{
+		"authors": rootQueryer("authors", "name", "a"),
+		"details": MockQueryerFunc{F: func(inputs []*requests.Request) ([]map[string]interface{}, error) {
+			return nil, fmt.Errorf("details are unavailable")
+		}},
+	}
+
+	actual, err := mustExecutePipelined(t, `{ authors { name bio } }`, 0, queryers)
+	require.Error(t, err)
+	assert.JSONEq(t, `{"authors": [{"name": "A", "bio": null}, {"name": "B", "bio": null}]}`, actual)
+}
@@ -1,20 +1,28 @@
 package pebbles
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/buildbuildio/pebbles/analysis"
 	"github.com/buildbuildio/pebbles/common"
 	"github.com/buildbuildio/pebbles/executor"
 	"github.com/buildbuildio/pebbles/gqlerrors"
 	"github.com/buildbuildio/pebbles/introspection"
 	"github.com/buildbuildio/pebbles/merger"
+	"github.com/buildbuildio/pebbles/metrics"
+	"github.com/buildbuildio/pebbles/persisted"
 	"github.com/buildbuildio/pebbles/planner"
 	"github.com/buildbuildio/pebbles/playground"
 	"github.com/buildbuildio/pebbles/queryer"
 	"github.com/buildbuildio/pebbles/requests"
+	"github.com/buildbuildio/pebbles/tracing"
 	"github.com/samber/lo"
 
 	"github.com/vektah/gqlparser/v2"
@@ -24,8 +32,25 @@ import (
 type QueryerFactory func(*planner.PlanningContext, string) queryer.Queryer
 
 type Gateway struct {
-	schema                   *ast.Schema
-	typeURLMap               merger.TypeURLMap
+	urls                     []string
+	serviceConfigs           map[string]ServiceConfig
+	headerForwarding         *queryer.HeaderForwardingPolicy
+	snapshot                 atomic.Value
+	reloadInterval           time.Duration
+	reloadStopCh             chan struct{}
+	reloadMutex              sync.Mutex
+	isNodesBatchingEnabled   bool
+	persistedQueryCache      persisted.Cache
+	trustedDocuments         *persisted.TrustedDocuments
+	limits                   *analysis.Limits
+	hooks                    hookList
+	tracer                   tracing.Tracer
+	metrics                  *metrics.Metrics
+	connectionInitTimeout    time.Duration
+	connectionInitHandler    ConnectionInitHandler
+	connectionInitForwarding []string
+	subscriptionPool         *queryer.SubscriptionPool
+	batchDispatcher          *queryer.BatchDispatcher
 	executor                 executor.Executor
 	getParentTypeFromIDFunc  executor.GetParentTypeFromIDFunc
 	planner                  planner.Planner
@@ -86,6 +111,37 @@ func WithGetParentTypeFromIDFunc(fn executor.GetParentTypeFromIDFunc) GatewayOpt
 	}
 }
 
+// WithNodesBatching enables fetching of objects via nodes(ids: [ID!]!) query.
+// All objects of the same plan step are fetched with single query instead of separate node(id: ID!) query for each of them.
+// Services, which schema lacks nodes query, are still queried via node.
+func WithNodesBatching() GatewayOption {
+	return func(g *Gateway) {
+		g.isNodesBatchingEnabled = true
+	}
+}
+
+// WithLimits enables static analysis of operations, rejecting the ones which exceed provided limits before planning
+func WithLimits(limits analysis.Limits) GatewayOption {
+	return func(g *Gateway) {
+		g.limits = &limits
+	}
+}
+
+// WithTracer enables tracing of requests: parsing, planning, execution of each depth,
+// downstream calls and scrubbing of the result are recorded as spans
+func WithTracer(tracer tracing.Tracer) GatewayOption {
+	return func(g *Gateway) {
+		g.tracer = tracer
+	}
+}
+
+// WithMetrics enables collection of metrics, which are served by MetricsHandler
+func WithMetrics(m *metrics.Metrics) GatewayOption {
+	return func(g *Gateway) {
+		g.metrics = m
+	}
+}
+
 func NewGateway(urls []string, options ...GatewayOption) (*Gateway, error) {
 	g := new(Gateway)
 
@@ -106,7 +162,7 @@ func NewGateway(urls []string, options ...GatewayOption) (*Gateway, error) {
 	if g.remoteSchemaIntrospector == nil {
 		g.remoteSchemaIntrospector = &introspection.ParallelRemoteSchemaIntrospector{
 			Factory: func(url string) queryer.Queryer {
-				return queryer.NewMultiOpQueryer(url, 1)
+				return g.newServiceQueryer(context.Background(), url, nil)
 			},
 		}
 	}
@@ -121,45 +177,30 @@ func NewGateway(urls []string, options ...GatewayOption) (*Gateway, error) {
 			ctx *planner.PlanningContext,
 			url string,
 		) queryer.Queryer {
-			return queryer.NewMultiOpQueryer(
-				url, 3000,
-			).WithHTTPClient(
-				http.DefaultClient,
-			).WithContext(
-				ctx.Request.Original.Context(),
-			)
+			return g.newServiceQueryer(ctx.Request.Context(), url, ctx.Request.Original)
 		}
 	}
 
-	// run introspection query against passed urls
-	schemas, err := g.remoteSchemaIntrospector.IntrospectRemoteSchemas(urls...)
-	if err != nil {
-		return nil, fmt.Errorf("unable to introspect remote schemas: %w", err)
-	}
+	g.urls = urls
 
-	mergeInputs := make([]*merger.MergeInput, len(schemas))
-	for i := 0; i < len(schemas); i++ {
-		mergeInputs[i] = &merger.MergeInput{
-			Schema: schemas[i],
-			URL:    urls[i],
-		}
+	// run introspection and merge for the first time
+	if err := g.Reload(); err != nil {
+		return nil, err
 	}
 
-	// merge schemas into one
-	mr, err := g.merger.Merge(mergeInputs)
-	if err != nil {
-		return nil, fmt.Errorf("unable to merge schemas: %w", err)
+	if g.reloadInterval > 0 {
+		g.reloadStopCh = make(chan struct{})
+		go g.pollSchemas(g.reloadStopCh)
 	}
 
-	g.schema = mr.Schema
-	g.typeURLMap = mr.TypeURLMap
-
 	return g, nil
 }
 
 type Result struct {
 	Errors gqlerrors.ErrorList    `json:"errors,omitempty"`
 	Data   map[string]interface{} `json:"data"`
+	// Incremental is set instead of Data for subsequent parts of incremental delivery
+	Incremental []*executor.IncrementalResult `json:"-"`
 
 	index int `json:"-"`
 }
@@ -199,114 +240,287 @@ func (g *Gateway) queryHandler(w http.ResponseWriter, r *http.Request) {
 		lo.Range(len(rs.Requests)),
 		make(Results, len(rs.Requests)),
 		func(index int) (*Result, error) {
-			request := rs.Requests[index]
-			// the result of the operation
-			result := make(map[string]interface{})
+			result := g.executeRequest(rs.Requests[index])
+			result.index = index
+			return result, nil
+		},
+		func(acc Results, value *Result) Results {
+			acc[value.index] = value
+			return acc
+		},
+	)
 
-			query, qerr := gqlparser.LoadQuery(g.schema, request.Query)
-			if qerr != nil {
-				return &Result{
-					Errors: gqlerrors.FormatError(qerr),
-					Data:   nil,
+	// emit the response
+	results.Emit(w, rs.IsBatchMode)
 
-					index: index,
-				}, nil
-			}
+}
 
-			var operation *ast.OperationDefinition
-			if request.OperationName != nil {
-				operation = query.Operations.ForName(*request.OperationName)
-			} else if len(query.Operations) == 1 {
-				operation = query.Operations[0]
-			}
+// executeRequest runs single request through all stages: query loading, planning and execution
+func (g *Gateway) executeRequest(request *requests.Request) *Result {
+	return g.executeIncrementalRequest(request, nil)
+}
 
-			if operation == nil {
-				var err error
-				if request.OperationName != nil {
-					err = fmt.Errorf(
-						"unable to extract query for operation %s",
-						*request.OperationName,
-					)
-				} else {
-					err = errors.New("many queries provided, but no operationName")
-				}
-				return &Result{
-					Errors: gqlerrors.ErrorList{
-						gqlerrors.NewError(
-							gqlerrors.ValidationFailedError,
-							err,
-						),
-					},
-					Data: nil,
-
-					index: index,
-				}, nil
-			}
+// executeIncrementalRequest runs request the same way as executeRequest, but parts of the result are written
+// to iw as soon as they're ready and hooks accept them. Complete result is returned anyway.
+func (g *Gateway) executeIncrementalRequest(request *requests.Request, iw *incrementalWriter) *Result {
+	start := time.Now()
+	span := g.initRequestContext(request)
+	defer span.End()
 
-			planningContext := &planner.PlanningContext{
-				Request:    request,
-				Operation:  operation,
-				Schema:     g.schema,
-				TypeURLMap: g.typeURLMap,
-			}
+	// use the same snapshot during whole request even if schema is reloaded meanwhile
+	snapshot := g.getSnapshot()
 
-			// get the plan for specific query
-			plan, err := g.planner.Plan(planningContext)
-			if err != nil {
-				return &Result{
-					Errors: gqlerrors.ErrorList{
-						gqlerrors.NewError(gqlerrors.ValidationFailedError, err),
-					},
-					Data: nil,
+	planningContext := &planner.PlanningContext{
+		Request:    request,
+		Schema:     snapshot.schema,
+		TypeURLMap: snapshot.typeURLMap,
+	}
 
-					index: index,
-				}, nil
-			}
+	var incremental executor.IncrementalDelivery
+	var delivery *hookedDelivery
+	if iw != nil {
+		delivery = &hookedDelivery{iw: iw, ctx: planningContext, hooks: g.hooks}
+		incremental = delivery
+	}
+
+	result := g.execute(planningContext, snapshot, incremental)
 
-			introspectionRes := g.parseIntrospectionQuery(plan, request)
-			if introspectionRes != nil {
-				introspectionRes.index = index
-				return introspectionRes, nil
+	if delivery != nil && delivery.err != nil {
+		// the error part is already sent to the client
+		result = &Result{
+			Errors: gqlerrors.FormatError(delivery.err),
+			Data:   nil,
+		}
+	} else if iw == nil || !iw.isStarted {
+		// hooks have received each part of the result already, if it was delivered incrementally
+		if err := g.hooks.onResult(planningContext, result); err != nil {
+			result = &Result{
+				Errors: gqlerrors.FormatError(err),
+				Data:   nil,
 			}
+		}
+	}
 
-			queryers := g.getQueryers(planningContext, plan.RootSteps)
+	var operationName, operationType string
+	if operation := planningContext.Operation; operation != nil {
+		operationName, operationType = operation.Name, string(operation.Operation)
+		span.SetAttributes(
+			tracing.String("graphql.operation.name", operationName),
+			tracing.String("graphql.operation.type", operationType),
+		)
+	}
 
-			// fire the query
-			result, err = g.executor.Execute(&executor.ExecutionContext{
-				QueryPlan:               plan,
-				Request:                 request,
-				Queryers:                queryers,
-				GetParentTypeFromIDFunc: g.getParentTypeFromIDFunc,
-			})
+	outcome := metrics.OutcomeSuccess
+	if len(result.Errors) != 0 {
+		span.RecordError(result.Errors)
 
-			plan.ScrubFields.Clean(result)
+		outcome = metrics.OutcomeError
+		if result.Data != nil {
+			outcome = metrics.OutcomePartial
+		}
+	}
+	g.metrics.ObserveRequest(operationName, operationType, outcome, time.Since(start))
 
-			return &Result{
-				Errors: gqlerrors.FormatError(err),
-				Data:   result,
+	return result
+}
 
-				index: index,
-			}, nil
-		},
-		func(acc Results, value *Result) Results {
-			acc[value.index] = value
-			return acc
-		},
-	)
+// initRequestContext starts root span of the request and stores it along with metrics in the context of original request,
+// so they're available to the planner, executor and queryers. Incoming traceparent header is used as parent span.
+func (g *Gateway) initRequestContext(request *requests.Request) tracing.Span {
+	if request.Original == nil {
+		return tracing.SpanFromContext(request.Context())
+	}
 
-	// emit the response
-	results.Emit(w, rs.IsBatchMode)
+	ctx := tracing.Extract(request.Original.Context(), request.Original.Header)
+	if g.tracer != nil {
+		ctx = tracing.ContextWithTracer(ctx, g.tracer)
+	}
+	if g.metrics != nil {
+		ctx = metrics.ContextWithMetrics(ctx, g.metrics)
+	}
+
+	ctx, span := tracing.Start(ctx, "graphql.request")
+	request.Original = request.Original.WithContext(ctx)
 
+	return span
 }
 
-func (g *Gateway) parseIntrospectionQuery(plan *planner.QueryPlan, request *requests.Request) *Result {
+func (g *Gateway) execute(planningContext *planner.PlanningContext, snapshot *schemaSnapshot, incremental executor.IncrementalDelivery) *Result {
+	request := planningContext.Request
+
+	if err := g.resolvePersistedQuery(request); err != nil {
+		return &Result{
+			Errors: gqlerrors.ErrorList{err},
+			Data:   nil,
+		}
+	}
+
+	_, parseSpan := tracing.Start(request.Context(), "graphql.parse")
+	query, qerr := g.loadQuery(snapshot.schema, request)
+	parseSpan.RecordError(qerr)
+	parseSpan.End()
+	if qerr != nil {
+		return &Result{
+			Errors: gqlerrors.FormatError(qerr),
+			Data:   nil,
+		}
+	}
+
+	if err := g.hooks.onParse(planningContext, query); err != nil {
+		return &Result{
+			Errors: gqlerrors.FormatError(err),
+			Data:   nil,
+		}
+	}
+
+	operation, operationErr := selectOperation(query, request)
+	if operationErr != nil {
+		return &Result{
+			Errors: gqlerrors.ErrorList{operationErr},
+			Data:   nil,
+		}
+	}
+
+	planningContext.Operation = operation
+
+	if err := g.hooks.onOperation(planningContext); err != nil {
+		return &Result{
+			Errors: gqlerrors.FormatError(err),
+			Data:   nil,
+		}
+	}
+
+	if errs := g.checkLimits(operation, request); len(errs) != 0 {
+		return &Result{
+			Errors: errs,
+			Data:   nil,
+		}
+	}
+
+	// get the plan for specific query
+	plan, err := g.plan(planningContext)
+	if err != nil {
+		return &Result{
+			Errors: gqlerrors.ErrorList{
+				gqlerrors.NewError(gqlerrors.ValidationFailedError, err),
+			},
+			Data: nil,
+		}
+	}
+
+	if err := g.hooks.onPlan(planningContext, plan); err != nil {
+		return &Result{
+			Errors: gqlerrors.FormatError(err),
+			Data:   nil,
+		}
+	}
+
+	introspectionRes := g.parseIntrospectionQuery(plan, planningContext)
+	if introspectionRes != nil {
+		return introspectionRes
+	}
+
+	queryers := g.getQueryers(planningContext, plan.RootSteps)
+
+	// fire the query
+	result, err := g.executor.Execute(&executor.ExecutionContext{
+		QueryPlan:               plan,
+		Request:                 request,
+		Queryers:                queryers,
+		GetParentTypeFromIDFunc: g.getParentTypeFromIDFunc,
+		NodesBatchingURLs:       snapshot.nodesBatchingURLs,
+		Incremental:             incremental,
+	})
+
+	_, scrubSpan := tracing.Start(request.Context(), "graphql.scrub")
+	plan.ScrubFields.Clean(result)
+	scrubSpan.End()
+
+	return &Result{
+		Errors: gqlerrors.FormatError(err),
+		Data:   result,
+	}
+}
+
+// plan builds query plan within its own tracing span, which is set as current span of the request during planning
+func (g *Gateway) plan(ctx *planner.PlanningContext) (*planner.QueryPlan, error) {
+	spanCtx, span := tracing.Start(ctx.Request.Context(), "graphql.plan")
+	defer span.End()
+
+	planningContext := ctx
+	if ctx.Request.Original != nil {
+		request := *ctx.Request
+		request.Original = request.Original.WithContext(spanCtx)
+
+		cpy := *ctx
+		cpy.Request = &request
+		planningContext = &cpy
+	}
+
+	plan, err := g.planner.Plan(planningContext)
+	span.RecordError(err)
+
+	return plan, err
+}
+
+// selectOperation returns operation of the query, which should be executed
+func selectOperation(query *ast.QueryDocument, request *requests.Request) (*ast.OperationDefinition, *gqlerrors.Error) {
+	var operation *ast.OperationDefinition
+	if request.OperationName != nil {
+		operation = query.Operations.ForName(*request.OperationName)
+	} else if len(query.Operations) == 1 {
+		operation = query.Operations[0]
+	}
+
+	if operation != nil {
+		return operation, nil
+	}
+
+	var err error
+	if request.OperationName != nil {
+		err = fmt.Errorf(
+			"unable to extract query for operation %s",
+			*request.OperationName,
+		)
+	} else {
+		err = errors.New("many queries provided, but no operationName")
+	}
+
+	return nil, gqlerrors.NewError(gqlerrors.ValidationFailedError, err)
+}
+
+// loadQuery parses and validates query of the request.
+// If trusted documents are set, only registered operations are allowed.
+func (g *Gateway) loadQuery(schema *ast.Schema, request *requests.Request) (*ast.QueryDocument, error) {
+	if g.trustedDocuments != nil {
+		return g.trustedDocuments.Load(schema, request)
+	}
+
+	query, qerr := gqlparser.LoadQuery(schema, request.Query)
+	if qerr != nil {
+		return nil, qerr
+	}
+
+	return query, nil
+}
+
+// checkLimits returns errors if operation exceeds configured limits
+func (g *Gateway) checkLimits(operation *ast.OperationDefinition, request *requests.Request) gqlerrors.ErrorList {
+	if g.limits == nil {
+		return nil
+	}
+
+	return g.limits.Check(operation, request.Variables)
+}
+
+func (g *Gateway) parseIntrospectionQuery(plan *planner.QueryPlan, ctx *planner.PlanningContext) *Result {
 	for _, rs := range plan.RootSteps {
 		if rs.URL == common.InternalServiceName {
 			ir := &introspection.IntrospectionResolver{
-				Variables: request.Variables,
+				Variables: ctx.Request.Variables,
 			}
 
-			introspectionFields := ir.ResolveIntrospectionFields(rs.SelectionSet, g.schema)
+			introspectionFields := ir.ResolveIntrospectionFields(rs.SelectionSet, ctx.Schema)
 			if introspectionFields != nil {
 				return &Result{
 					Data:   introspectionFields,
@@ -323,7 +537,11 @@ func (g *Gateway) getQueryers(planningCtx *planner.PlanningContext, planSteps []
 	queryers := make(map[string]queryer.Queryer)
 	for _, ps := range planSteps {
 		if _, ok := queryers[ps.URL]; !ok {
-			queryers[ps.URL] = g.queryerFactory(planningCtx, ps.URL)
+			q := g.queryerFactory(planningCtx, ps.URL)
+			if len(g.hooks) != 0 {
+				q = &hookedQueryer{Queryer: q, ctx: planningCtx, hooks: g.hooks}
+			}
+			queryers[ps.URL] = q
 		}
 
 		if ps.Then != nil {
@@ -337,6 +555,17 @@ func (g *Gateway) getQueryers(planningCtx *planner.PlanningContext, planSteps []
 	return queryers
 }
 
+// MetricsHandler serves collected metrics in Prometheus text exposition format.
+// It responds with 404, if metrics are not enabled with WithMetrics option.
+func (g *Gateway) MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	if g.metrics == nil {
+		emitError(w, http.StatusNotFound, errors.New("metrics are not enabled"))
+		return
+	}
+
+	g.metrics.Handler().ServeHTTP(w, r)
+}
+
 func emitError(w http.ResponseWriter, code int, err error) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(code)
@@ -350,8 +579,16 @@ func emitError(w http.ResponseWriter, code int, err error) {
 }
 
 func (g *Gateway) Handler(w http.ResponseWriter, r *http.Request) {
-	// via posts we recieve queries and mutations
+	// via posts we recieve queries and mutations, subscriptions are streamed if client accepts it
 	if r.Method == http.MethodPost {
+		if isStreamRequest(r) {
+			g.streamHandler(w, r)
+			return
+		}
+		if isIncrementalRequest(r) {
+			g.incrementalHandler(w, r)
+			return
+		}
 		g.queryHandler(w, r)
 		return
 	}
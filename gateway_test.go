@@ -9,8 +9,10 @@ import (
 	"sort"
 	"testing"
 
+	"github.com/buildbuildio/pebbles/analysis"
 	"github.com/buildbuildio/pebbles/common"
 	"github.com/buildbuildio/pebbles/executor"
+	"github.com/buildbuildio/pebbles/gqlerrors"
 	"github.com/buildbuildio/pebbles/planner"
 	"github.com/buildbuildio/pebbles/playground"
 	"github.com/buildbuildio/pebbles/queryer"
@@ -455,3 +457,38 @@ func TestGatewayIntrospectionQuery(t *testing.T) {
 
 	assert.NotEmpty(t, res["data"].(map[string]interface{}))
 }
+
+func TestGatewayLimits(t *testing.T) {
+	mp := &MockPlanner{
+		Res: &planner.QueryPlan{},
+	}
+	me := &MockExecutor{
+		Res: map[string]interface{}{
+			"test": "YES",
+		},
+	}
+
+	schema := `
+		type Query {
+			test: String!
+		}
+	`
+
+	s := gqlparser.MustLoadSchema(&ast.Source{Name: "fixture", Input: schema})
+	mi := &MockRemoteSchemaIntrospector{Res: []*ast.Schema{s}}
+	gw, err := NewGateway(
+		[]string{""},
+		WithExecutor(me),
+		WithPlanner(mp),
+		WithRemoteSchemaIntrospector(mi),
+		WithLimits(analysis.Limits{MaxAliases: 1}),
+	)
+	assert.NoError(t, err)
+
+	res := mustQueryGateway(t, gw, `{"query": "{ a: test }"}`)
+	assert.Empty(t, res["errors"])
+
+	res = mustQueryGateway(t, gw, `{"query": "{ a: test b: test }"}`)
+	assert.Nil(t, res["data"])
+	assert.Equal(t, gqlerrors.MaxAliasesExceededError, errorCode(t, res))
+}
@@ -8,10 +8,19 @@ import (
 )
 
 const (
-	ValidationFailedError = "GRAPHQL_VALIDATION_FAILED"
-	UndefinedError        = "UNDEFINED_ERROR"
+	ValidationFailedError           = "GRAPHQL_VALIDATION_FAILED"
+	UndefinedError                  = "UNDEFINED_ERROR"
+	PersistedQueryNotFoundError     = "PERSISTED_QUERY_NOT_FOUND"
+	PersistedQueryNotSupportedError = "PERSISTED_QUERY_NOT_SUPPORTED"
+	MaxDepthExceededError           = "MAX_DEPTH_EXCEEDED"
+	MaxAliasesExceededError         = "MAX_ALIASES_EXCEEDED"
+	MaxRootFieldsExceededError      = "MAX_ROOT_FIELDS_EXCEEDED"
+	MaxCostExceededError            = "MAX_COST_EXCEEDED"
 )
 
+// ServiceExtension is the extensions key containing url of the service, which returned an error
+const ServiceExtension = "service"
+
 type Location struct {
 	Line   int `json:"line,omitempty"`
 	Column int `json:"column,omitempty"`
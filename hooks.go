@@ -0,0 +1,157 @@
+package pebbles
+
+import (
+	"context"
+
+	"github.com/buildbuildio/pebbles/planner"
+	"github.com/buildbuildio/pebbles/queryer"
+	"github.com/buildbuildio/pebbles/requests"
+
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// Hooks are called on different stages of request processing. All of them are optional.
+// Returning an error from any hook stops processing of the request and the error is sent to the client.
+// Hooks may be called concurrently, so they must be safe for concurrent use.
+type Hooks struct {
+	// OnParse is called after query is parsed and validated against the schema.
+	// Operation of the context is not set yet.
+	OnParse func(ctx *planner.PlanningContext, query *ast.QueryDocument) error
+	// OnOperation is called after operation to execute is selected, before limits are checked and plan is built.
+	OnOperation func(ctx *planner.PlanningContext) error
+	// OnPlan is called after query plan is built.
+	OnPlan func(ctx *planner.PlanningContext, plan *planner.QueryPlan) error
+	// OnDownstreamRequest is called before inputs are sent to the service with provided url.
+	OnDownstreamRequest func(ctx *planner.PlanningContext, url string, inputs []*requests.Request) error
+	// OnDownstreamResponse is called after the service with provided url responded.
+	// err is an error returned by the queryer, if hook returns nil, it's kept as is.
+	OnDownstreamResponse func(ctx *planner.PlanningContext, url string, inputs []*requests.Request, outputs []map[string]interface{}, err error) error
+	// OnResult is called before result is sent to the client, result may be modified in place.
+	// Operation of the context is nil if request failed before operation was selected.
+	// Returned error replaces the result. When result is delivered incrementally, the hook is called on each part
+	// before it's sent, subsequent parts have Incremental set instead of Data, and returned error ends the stream.
+	OnResult func(ctx *planner.PlanningContext, result *Result) error
+}
+
+// WithHooks registers request lifecycle hooks. It may be used several times,
+// hooks are called in order of registration.
+func WithHooks(hooks Hooks) GatewayOption {
+	return func(g *Gateway) {
+		g.hooks = append(g.hooks, hooks)
+	}
+}
+
+type hookList []Hooks
+
+func (hl hookList) onParse(ctx *planner.PlanningContext, query *ast.QueryDocument) error {
+	for _, h := range hl {
+		if h.OnParse == nil {
+			continue
+		}
+		if err := h.OnParse(ctx, query); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (hl hookList) onOperation(ctx *planner.PlanningContext) error {
+	for _, h := range hl {
+		if h.OnOperation == nil {
+			continue
+		}
+		if err := h.OnOperation(ctx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (hl hookList) onPlan(ctx *planner.PlanningContext, plan *planner.QueryPlan) error {
+	for _, h := range hl {
+		if h.OnPlan == nil {
+			continue
+		}
+		if err := h.OnPlan(ctx, plan); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (hl hookList) onDownstreamRequest(ctx *planner.PlanningContext, url string, inputs []*requests.Request) error {
+	for _, h := range hl {
+		if h.OnDownstreamRequest == nil {
+			continue
+		}
+		if err := h.OnDownstreamRequest(ctx, url, inputs); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (hl hookList) onDownstreamResponse(ctx *planner.PlanningContext, url string, inputs []*requests.Request, outputs []map[string]interface{}, err error) error {
+	for _, h := range hl {
+		if h.OnDownstreamResponse == nil {
+			continue
+		}
+		if herr := h.OnDownstreamResponse(ctx, url, inputs, outputs, err); herr != nil {
+			return herr
+		}
+	}
+
+	return nil
+}
+
+func (hl hookList) onResult(ctx *planner.PlanningContext, result *Result) error {
+	for _, h := range hl {
+		if h.OnResult == nil {
+			continue
+		}
+		if err := h.OnResult(ctx, result); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// hookedQueryer calls downstream hooks around queries of wrapped queryer
+type hookedQueryer struct {
+	queryer.Queryer
+
+	ctx   *planner.PlanningContext
+	hooks hookList
+}
+
+func (q *hookedQueryer) Query(inputs []*requests.Request) ([]map[string]interface{}, error) {
+	return q.query(inputs, q.Queryer.Query)
+}
+
+func (q *hookedQueryer) QueryContext(ctx context.Context, inputs []*requests.Request) ([]map[string]interface{}, error) {
+	return q.query(inputs, func(inputs []*requests.Request) ([]map[string]interface{}, error) {
+		return queryer.QueryContext(ctx, q.Queryer, inputs)
+	})
+}
+
+func (q *hookedQueryer) query(
+	inputs []*requests.Request,
+	query func([]*requests.Request) ([]map[string]interface{}, error),
+) ([]map[string]interface{}, error) {
+	if err := q.hooks.onDownstreamRequest(q.ctx, q.URL(), inputs); err != nil {
+		return nil, err
+	}
+
+	outputs, err := query(inputs)
+
+	if herr := q.hooks.onDownstreamResponse(q.ctx, q.URL(), inputs, outputs, err); herr != nil {
+		return nil, herr
+	}
+
+	return outputs, err
+}
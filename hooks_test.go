@@ -0,0 +1,164 @@
+package pebbles
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/buildbuildio/pebbles/planner"
+	"github.com/buildbuildio/pebbles/queryer"
+	"github.com/buildbuildio/pebbles/requests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+type mockHooksQueryer struct {
+	queryer.Queryer
+
+	url string
+	res map[string]interface{}
+}
+
+func (q *mockHooksQueryer) URL() string {
+	return q.url
+}
+
+func (q *mockHooksQueryer) Query(inputs []*requests.Request) ([]map[string]interface{}, error) {
+	res := make([]map[string]interface{}, len(inputs))
+	for i := range inputs {
+		res[i] = q.res
+	}
+	return res, nil
+}
+
+func newHooksTestGateway(t *testing.T, hooks ...Hooks) *Gateway {
+	schema := gqlparser.MustLoadSchema(&ast.Source{Name: "fixture", Input: `
+		type Query {
+			test: String!
+		}
+	`})
+
+	options := []GatewayOption{
+		WithRemoteSchemaIntrospector(&MockRemoteSchemaIntrospector{Res: []*ast.Schema{schema}}),
+		WithQueryerFactory(func(ctx *planner.PlanningContext, url string) queryer.Queryer {
+			return &mockHooksQueryer{url: url, res: map[string]interface{}{"test": "YES"}}
+		}),
+	}
+	for _, h := range hooks {
+		options = append(options, WithHooks(h))
+	}
+
+	gw, err := NewGateway([]string{"a"}, options...)
+	require.NoError(t, err)
+
+	return gw
+}
+
+func TestGatewayHooksOrder(t *testing.T) {
+	var mu sync.Mutex
+	var calls []string
+	record := func(name string) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, name)
+	}
+
+	gw := newHooksTestGateway(t, Hooks{
+		OnParse: func(ctx *planner.PlanningContext, query *ast.QueryDocument) error {
+			assert.Nil(t, ctx.Operation)
+			assert.NotNil(t, query)
+			record("parse")
+			return nil
+		},
+		OnOperation: func(ctx *planner.PlanningContext) error {
+			assert.Equal(t, "Q", ctx.Operation.Name)
+			record("operation")
+			return nil
+		},
+		OnPlan: func(ctx *planner.PlanningContext, plan *planner.QueryPlan) error {
+			assert.Len(t, plan.RootSteps, 1)
+			record("plan")
+			return nil
+		},
+		OnDownstreamRequest: func(ctx *planner.PlanningContext, url string, inputs []*requests.Request) error {
+			assert.Equal(t, "a", url)
+			assert.Len(t, inputs, 1)
+			record("request")
+			return nil
+		},
+		OnDownstreamResponse: func(ctx *planner.PlanningContext, url string, inputs []*requests.Request, outputs []map[string]interface{}, err error) error {
+			assert.NoError(t, err)
+			assert.Len(t, outputs, 1)
+			record("response")
+			return nil
+		},
+		OnResult: func(ctx *planner.PlanningContext, result *Result) error {
+			result.Data["extra"] = true
+			record("result")
+			return nil
+		},
+	})
+
+	res := mustQueryGateway(t, gw, `{"query": "query Q { test }"}`)
+	assert.Equal(t, map[string]interface{}{
+		"data": map[string]interface{}{
+			"test":  "YES",
+			"extra": true,
+		},
+	}, res)
+	assert.Equal(t, []string{"parse", "operation", "plan", "request", "response", "result"}, calls)
+}
+
+func TestGatewayHooksShortCircuit(t *testing.T) {
+	for name, hooks := range map[string]Hooks{
+		"parse": {OnParse: func(*planner.PlanningContext, *ast.QueryDocument) error {
+			return errors.New("denied")
+		}},
+		"operation": {OnOperation: func(*planner.PlanningContext) error {
+			return errors.New("denied")
+		}},
+		"plan": {OnPlan: func(*planner.PlanningContext, *planner.QueryPlan) error {
+			return errors.New("denied")
+		}},
+		"request": {OnDownstreamRequest: func(*planner.PlanningContext, string, []*requests.Request) error {
+			return errors.New("denied")
+		}},
+		"response": {OnDownstreamResponse: func(*planner.PlanningContext, string, []*requests.Request, []map[string]interface{}, error) error {
+			return errors.New("denied")
+		}},
+		"result": {OnResult: func(*planner.PlanningContext, *Result) error {
+			return errors.New("denied")
+		}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			gw := newHooksTestGateway(t, hooks)
+
+			res := mustQueryGateway(t, gw, `{"query": "{ test }"}`)
+			assert.Nil(t, res["data"])
+			errs, ok := res["errors"].([]interface{})
+			require.True(t, ok)
+			require.Len(t, errs, 1)
+			assert.Equal(t, "denied", errs[0].(map[string]interface{})["message"])
+		})
+	}
+}
+
+func TestGatewayHooksMultipleRegistrations(t *testing.T) {
+	var calls []string
+	gw := newHooksTestGateway(t,
+		Hooks{OnOperation: func(*planner.PlanningContext) error {
+			calls = append(calls, "first")
+			return errors.New("denied")
+		}},
+		Hooks{OnOperation: func(*planner.PlanningContext) error {
+			calls = append(calls, "second")
+			return nil
+		}},
+	)
+
+	res := mustQueryGateway(t, gw, `{"query": "{ test }"}`)
+	assert.Nil(t, res["data"])
+	assert.Equal(t, []string{"first"}, calls)
+}
@@ -0,0 +1,178 @@
+package pebbles
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strings"
+
+	"github.com/buildbuildio/pebbles/executor"
+	"github.com/buildbuildio/pebbles/gqlerrors"
+	"github.com/buildbuildio/pebbles/planner"
+	"github.com/buildbuildio/pebbles/requests"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+const incrementalBoundary = "-"
+
+var incrementalDirectives = gqlparser.MustLoadSchema(&ast.Source{
+	Name:    "incremental",
+	Input:   planner.IncrementalDirectivesSchema,
+	BuiltIn: true,
+}).Directives
+
+// addIncrementalDirectives adds @defer and @stream definitions to merged schema, unless services define them
+func addIncrementalDirectives(schema *ast.Schema) {
+	if schema.Directives == nil {
+		schema.Directives = make(map[string]*ast.DirectiveDefinition)
+	}
+
+	for _, name := range []string{planner.DeferDirectiveName, planner.StreamDirectiveName} {
+		if _, ok := schema.Directives[name]; !ok {
+			schema.Directives[name] = incrementalDirectives[name]
+		}
+	}
+}
+
+// incrementalWriter sends result of operation with @defer or @stream as parts of multipart/mixed response,
+// see https://github.com/graphql/graphql-over-http/blob/main/rfcs/IncrementalDelivery.md
+type incrementalWriter struct {
+	mw      *multipart.Writer
+	flusher http.Flusher
+
+	isStarted bool
+}
+
+func newIncrementalWriter(w http.ResponseWriter, flusher http.Flusher) *incrementalWriter {
+	mw := multipart.NewWriter(w)
+	mw.SetBoundary(incrementalBoundary)
+
+	w.Header().Set("Content-Type", fmt.Sprintf(`%s; boundary="%s"; deferSpec=20220824`, multipartContentType, incrementalBoundary))
+	w.WriteHeader(http.StatusOK)
+
+	return &incrementalWriter{mw: mw, flusher: flusher}
+}
+
+func (iw *incrementalWriter) writePart(v interface{}) {
+	part, err := iw.mw.CreatePart(textproto.MIMEHeader{"Content-Type": []string{"application/json; charset=utf-8"}})
+	if err != nil {
+		return
+	}
+	if err := json.NewEncoder(part).Encode(v); err != nil {
+		return
+	}
+	iw.flusher.Flush()
+}
+
+func (iw *incrementalWriter) Initial(data map[string]interface{}, errs gqlerrors.ErrorList, hasNext bool) {
+	iw.isStarted = true
+
+	payload := map[string]interface{}{"data": data, "hasNext": hasNext}
+	if len(errs) != 0 {
+		payload["errors"] = errs
+	}
+	iw.writePart(payload)
+}
+
+func (iw *incrementalWriter) Subsequent(results []*executor.IncrementalResult, hasNext bool) {
+	payload := map[string]interface{}{"hasNext": hasNext}
+	if len(results) != 0 {
+		payload["incremental"] = results
+	}
+	iw.writePart(payload)
+}
+
+// fail ends the stream with the part containing errs
+func (iw *incrementalWriter) fail(errs gqlerrors.ErrorList) {
+	payload := map[string]interface{}{"errors": errs, "hasNext": false}
+	if !iw.isStarted {
+		payload["data"] = nil
+	}
+	iw.isStarted = true
+	iw.writePart(payload)
+}
+
+func (iw *incrementalWriter) close() {
+	iw.mw.Close()
+	iw.flusher.Flush()
+}
+
+// hookedDelivery runs OnResult hooks on each part of the result before it's written.
+// Once hooks reject a part, the stream is ended with the error part and following parts are dropped.
+type hookedDelivery struct {
+	iw    *incrementalWriter
+	ctx   *planner.PlanningContext
+	hooks hookList
+
+	err error
+}
+
+func (d *hookedDelivery) Initial(data map[string]interface{}, errs gqlerrors.ErrorList, hasNext bool) {
+	result := &Result{Data: data, Errors: errs}
+	if !d.accept(result) {
+		return
+	}
+	d.iw.Initial(result.Data, result.Errors, hasNext)
+}
+
+func (d *hookedDelivery) Subsequent(results []*executor.IncrementalResult, hasNext bool) {
+	result := &Result{Incremental: results}
+	if !d.accept(result) {
+		return
+	}
+	d.iw.Subsequent(result.Incremental, hasNext)
+}
+
+// accept returns true if hooks accepted the part, otherwise the error part is written instead
+func (d *hookedDelivery) accept(result *Result) bool {
+	if d.err != nil {
+		return false
+	}
+
+	if d.err = d.hooks.onResult(d.ctx, result); d.err != nil {
+		d.iw.fail(gqlerrors.FormatError(d.err))
+		return false
+	}
+
+	return true
+}
+
+// isIncrementalRequest returns true if client accepts result of operation in parts
+func isIncrementalRequest(r *http.Request) bool {
+	accept := r.Header.Get("Accept")
+	return strings.Contains(accept, multipartContentType) && strings.Contains(accept, "deferSpec")
+}
+
+// incrementalHandler executes operation and sends its result as multipart response, so fragments marked with @defer
+// and items of lists marked with @stream are sent as soon as they're ready. Other operations are sent as single part.
+func (g *Gateway) incrementalHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		emitError(w, http.StatusInternalServerError, errors.New("streaming is not supported"))
+		return
+	}
+
+	rs, err := requests.Parse(r)
+	if err != nil {
+		emitError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	if rs.IsBatchMode {
+		emitError(w, http.StatusUnprocessableEntity, errors.New("batch requests can't be delivered incrementally"))
+		return
+	}
+
+	iw := newIncrementalWriter(w, flusher)
+	result := g.executeIncrementalRequest(rs.Requests[0], iw)
+
+	// execution hasn't reached executor, f.e. query is invalid
+	if !iw.isStarted {
+		iw.Initial(result.Data, result.Errors, false)
+	}
+	iw.close()
+}
@@ -0,0 +1,187 @@
+package pebbles
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/buildbuildio/pebbles/planner"
+	"github.com/buildbuildio/pebbles/queryer"
+	"github.com/buildbuildio/pebbles/requests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// mockIncrementalQueryer returns movies and records queries sent to the service
+type mockIncrementalQueryer struct {
+	MockQueryer
+
+	queries []string
+	sync.Mutex
+}
+
+func (q *mockIncrementalQueryer) Query(inputs []*requests.Request) ([]map[string]interface{}, error) {
+	q.Lock()
+	defer q.Unlock()
+
+	var res []map[string]interface{}
+	for _, input := range inputs {
+		q.queries = append(q.queries, input.Query)
+
+		if strings.Contains(input.Query, "node") {
+			res = append(res, map[string]interface{}{
+				"node": map[string]interface{}{"title": "Title " + input.Variables["id"].(string)},
+			})
+			continue
+		}
+
+		res = append(res, map[string]interface{}{
+			"movies": []interface{}{map[string]interface{}{"id": "1"}, map[string]interface{}{"id": "2"}},
+		})
+	}
+	return res, nil
+}
+
+func newIncrementalTestServer(t *testing.T, q queryer.Queryer, opts ...GatewayOption) *httptest.Server {
+	schema := gqlparser.MustLoadSchema(&ast.Source{Name: "fixture", Input: `
+		interface Node {
+			id: ID!
+		}
+
+		type Movie implements Node {
+			id: ID!
+			title: String!
+		}
+
+		type Query {
+			movies: [Movie!]!
+			node(id: ID!): Node
+		}
+	`})
+
+	gw, err := NewGateway(
+		[]string{""},
+		append([]GatewayOption{
+			WithRemoteSchemaIntrospector(&MockRemoteSchemaIntrospector{Res: []*ast.Schema{schema}}),
+			WithQueryerFactory(func(pc *planner.PlanningContext, s string) queryer.Queryer {
+				return q
+			}),
+		}, opts...)...,
+	)
+	require.NoError(t, err)
+
+	return httptest.NewServer(http.HandlerFunc(gw.Handler))
+}
+
+func readIncrementalParts(t *testing.T, resp *http.Response) []string {
+	mediaType, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	assert.Equal(t, "multipart/mixed", mediaType)
+	assert.Equal(t, "20220824", params["deferspec"])
+
+	var parts []string
+	mr := multipart.NewReader(resp.Body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return parts
+		}
+		require.NoError(t, err)
+
+		b, err := io.ReadAll(part)
+		require.NoError(t, err)
+		parts = append(parts, string(b))
+	}
+}
+
+func TestGatewayDeferredFragment(t *testing.T) {
+	q := &mockIncrementalQueryer{}
+	server := newIncrementalTestServer(t, q)
+	defer server.Close()
+
+	resp := postStream(t, server.URL, `multipart/mixed;deferSpec=20220824,application/json`, `{ movies { id ... @defer(label: "title") { title } } }`)
+	defer resp.Body.Close()
+
+	parts := readIncrementalParts(t, resp)
+	require.Len(t, parts, 2)
+	assert.JSONEq(t, `{"data": {"movies": [{"id": "1"}, {"id": "2"}]}, "hasNext": true}`, parts[0])
+	assert.JSONEq(t, `{"incremental": [
+		{"data": {"title": "Title 1"}, "path": ["movies", 0], "label": "title"},
+		{"data": {"title": "Title 2"}, "path": ["movies", 1], "label": "title"}
+	], "hasNext": false}`, parts[1])
+
+	// directive is executed by the gateway
+	for _, query := range q.queries {
+		assert.NotContains(t, query, "@defer")
+	}
+}
+
+func TestGatewayIncrementalSinglePart(t *testing.T) {
+	server := newIncrementalTestServer(t, &mockIncrementalQueryer{})
+	defer server.Close()
+
+	resp := postStream(t, server.URL, `multipart/mixed;deferSpec=20220824`, `{ unknown }`)
+	defer resp.Body.Close()
+
+	parts := readIncrementalParts(t, resp)
+	require.Len(t, parts, 1)
+
+	var res map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(parts[0]), &res))
+	assert.Nil(t, res["data"])
+	assert.NotEmpty(t, res["errors"])
+	assert.Equal(t, false, res["hasNext"])
+}
+
+func TestGatewayIncrementalResultHooks(t *testing.T) {
+	var parts []*Result
+	server := newIncrementalTestServer(t, &mockIncrementalQueryer{}, WithHooks(Hooks{
+		OnResult: func(ctx *planner.PlanningContext, result *Result) error {
+			parts = append(parts, result)
+			if result.Incremental != nil {
+				return errors.New("rejected")
+			}
+			result.Data["movies"] = []interface{}{}
+			return nil
+		},
+	}))
+	defer server.Close()
+
+	resp := postStream(t, server.URL, `multipart/mixed;deferSpec=20220824`, `{ movies { id ... @defer { title } } }`)
+	defer resp.Body.Close()
+
+	// hooks are called on each part before it's sent, rejected part ends the stream
+	res := readIncrementalParts(t, resp)
+	require.Len(t, res, 2)
+	assert.JSONEq(t, `{"data": {"movies": []}, "hasNext": true}`, res[0])
+	assert.JSONEq(t, `{"errors": [{"message": "rejected", "extensions": {"code": "UNDEFINED_ERROR"}}], "hasNext": false}`, res[1])
+
+	require.Len(t, parts, 2)
+	assert.Len(t, parts[1].Incremental, 2)
+}
+
+func TestGatewayIncrementalResultHooksInitial(t *testing.T) {
+	q := &mockIncrementalQueryer{}
+	server := newIncrementalTestServer(t, q, WithHooks(Hooks{
+		OnResult: func(ctx *planner.PlanningContext, result *Result) error {
+			return errors.New("rejected")
+		},
+	}))
+	defer server.Close()
+
+	resp := postStream(t, server.URL, `multipart/mixed;deferSpec=20220824`, `{ movies { id ... @defer { title } } }`)
+	defer resp.Body.Close()
+
+	res := readIncrementalParts(t, resp)
+	require.Len(t, res, 1)
+	assert.JSONEq(t, `{"data": null, "errors": [{"message": "rejected", "extensions": {"code": "UNDEFINED_ERROR"}}], "hasNext": false}`, res[0])
+}
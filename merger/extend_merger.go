@@ -208,7 +208,7 @@ func mergePossibleTypes(sources []*ast.Schema, mergedTypes map[string]*ast.Defin
 func mergeRootObjects(aTypes, bTypes map[string]*ast.Definition, a, b *ast.Definition) (*ast.Definition, error) {
 	var fields ast.FieldList = a.Fields
 	for _, f := range b.Fields {
-		if common.IsBuiltinName(f.Name) || isNodeField(f) {
+		if common.IsBuiltinName(f.Name) || isNodeField(f) || isNodesField(f) {
 			continue
 		}
 
@@ -392,3 +392,26 @@ func isNodeField(f *ast.FieldDefinition) bool {
 		isIDType(arg.Type) &&
 		isNullableTypeNamed(f.Type, common.NodeInterfaceName)
 }
+
+// isNodesField returns true if field is nodes(ids: [ID!]!): [Node]!
+func isNodesField(f *ast.FieldDefinition) bool {
+	if f.Name != common.NodesFieldName || len(f.Arguments) != 1 {
+		return false
+	}
+	arg := f.Arguments[0]
+	return arg.Name == common.IDsFieldName &&
+		arg.Type.Elem != nil &&
+		isIDType(arg.Type.Elem) &&
+		f.Type.Elem != nil &&
+		isNullableTypeNamed(f.Type.Elem, common.NodeInterfaceName)
+}
+
+// HasNodesField returns true if schema allows to fetch multiple nodes at once via nodes(ids: [ID!]!) query
+func HasNodesField(schema *ast.Schema) bool {
+	if schema == nil || schema.Query == nil {
+		return false
+	}
+
+	f := schema.Query.Fields.ForName(common.NodesFieldName)
+	return f != nil && isNodesField(f)
+}
@@ -1,9 +1,11 @@
 package merger
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/vektah/gqlparser/v2"
 	"github.com/vektah/gqlparser/v2/ast"
 )
 
@@ -224,6 +226,61 @@ func TestSameQuery(t *testing.T) {
 	})
 }
 
+func TestSameNodesQuery(t *testing.T) {
+	schemas := []string{
+		`
+		interface Node { id: ID! }
+		type Human implements Node { id: ID!, name: String! }
+
+		type Query {
+			node(id: ID!): Node
+			nodes(ids: [ID!]!): [Node]!
+		}
+		`,
+		`
+		interface Node { id: ID! }
+		type Human implements Node { id: ID!, otherName: String! }
+
+		type Query {
+			node(id: ID!): Node
+			nodes(ids: [ID!]!): [Node]!
+		}
+		`,
+	}
+
+	assert.NotPanics(t, func() {
+		mustRunMerger(t, extendMerger, schemas)
+	})
+}
+
+func TestHasNodesField(t *testing.T) {
+	for _, tc := range []struct {
+		Query    string
+		Expected bool
+	}{
+		{Query: `node(id: ID!): Node`, Expected: false},
+		{Query: `nodes(ids: [ID!]!): [Node]!`, Expected: true},
+		{Query: `nodes(ids: [ID!]): [Node]`, Expected: true},
+		{Query: `nodes(ids: [ID]!): [Node]!`, Expected: false},
+		{Query: `nodes(id: [ID!]!): [Node]!`, Expected: false},
+		{Query: `nodes(ids: [ID!]!): [Node!]!`, Expected: false},
+		{Query: `nodes(ids: [ID!]!): Node`, Expected: false},
+	} {
+		t.Run(tc.Query, func(t *testing.T) {
+			schema := gqlparser.MustLoadSchema(&ast.Source{Name: "schema", Input: fmt.Sprintf(`
+				interface Node { id: ID! }
+				type Human implements Node { id: ID! }
+
+				type Query {
+					%s
+				}
+			`, tc.Query)})
+
+			assert.Equal(t, tc.Expected, HasNodesField(schema))
+		})
+	}
+}
+
 func TestSameMutation(t *testing.T) {
 	schemas := []string{
 		`
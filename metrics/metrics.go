@@ -0,0 +1,234 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Outcomes of requests
+const (
+	OutcomeSuccess = "success"
+	// OutcomePartial means that response contains both data and errors
+	OutcomePartial = "partial"
+	OutcomeError   = "error"
+)
+
+// Values of operation_name label, which replace names of operations
+const (
+	// OperationAnonymous is used for operations without name and requests, which operation is unknown
+	OperationAnonymous = "anonymous"
+	// OperationOther is used for operations, which names exceed the limit of distinct names
+	OperationOther = "other"
+)
+
+// DefaultMaxOperationNames is the number of distinct operation names recorded by default
+const DefaultMaxOperationNames = 100
+
+// DefaultDurationBuckets are upper bounds of latency histograms in seconds
+var DefaultDurationBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// DefaultBatchSizeBuckets are upper bounds of batch size histograms
+var DefaultBatchSizeBuckets = []float64{1, 2, 5, 10, 25, 50, 100, 250, 500, 1000}
+
+// Metrics are collected by gateway, executor, planner and queryer.
+// All methods are safe to call on nil Metrics, which means metrics are disabled.
+type Metrics struct {
+	registry *Registry
+
+	requests                *CounterVec
+	requestDuration         *HistogramVec
+	downstreamRequests      *CounterVec
+	downstreamDuration      *HistogramVec
+	downstreamBatchSize     *HistogramVec
+	downstreamHTTPResponses *CounterVec
+	planCacheLookups        *CounterVec
+	activeSubscriptions     *GaugeVec
+	activeConnections       *GaugeVec
+
+	// operation names are sent by clients, so only limited number of them is used as label values
+	operationNamesMu  sync.Mutex
+	operationNames    map[string]struct{}
+	maxOperationNames int
+}
+
+// New returns gateway metrics registered in new registry
+func New() *Metrics {
+	r := NewRegistry()
+
+	return &Metrics{
+		registry:          r,
+		operationNames:    make(map[string]struct{}),
+		maxOperationNames: DefaultMaxOperationNames,
+
+		requests: r.NewCounter(
+			"pebbles_requests_total", "Number of processed GraphQL requests.",
+			"operation_name", "operation_type", "outcome",
+		),
+		requestDuration: r.NewHistogram(
+			"pebbles_request_duration_seconds", "Duration of GraphQL requests.",
+			DefaultDurationBuckets, "operation_name", "operation_type", "outcome",
+		),
+		downstreamRequests: r.NewCounter(
+			"pebbles_downstream_requests_total", "Number of grouped requests sent by executor to each service.",
+			"service", "outcome",
+		),
+		downstreamDuration: r.NewHistogram(
+			"pebbles_downstream_duration_seconds", "Duration of http requests to each service.",
+			DefaultDurationBuckets, "service",
+		),
+		downstreamBatchSize: r.NewHistogram(
+			"pebbles_downstream_batch_size", "Number of operations in single http request to each service.",
+			DefaultBatchSizeBuckets, "service",
+		),
+		downstreamHTTPResponses: r.NewCounter(
+			"pebbles_downstream_http_responses_total", "Number of http responses from each service by status code, code is empty if request failed.",
+			"service", "code",
+		),
+		planCacheLookups: r.NewCounter(
+			"pebbles_plan_cache_lookups_total", "Number of query plan cache lookups, result is either hit or miss.",
+			"result",
+		),
+		activeSubscriptions: r.NewGauge(
+			"pebbles_active_subscriptions", "Number of running subscriptions.",
+		),
+		activeConnections: r.NewGauge(
+			"pebbles_active_websocket_connections", "Number of open websocket connections.",
+		),
+	}
+}
+
+// WithMaxOperationNames sets the number of distinct operation names recorded as operation_name label,
+// operations with other names are recorded as OperationOther. Zero disables recording of operation names.
+func (m *Metrics) WithMaxOperationNames(n int) *Metrics {
+	m.maxOperationNames = n
+	return m
+}
+
+// operationLabel returns value of operation_name label for the operation name
+func (m *Metrics) operationLabel(operationName string) string {
+	if operationName == "" {
+		return OperationAnonymous
+	}
+
+	m.operationNamesMu.Lock()
+	defer m.operationNamesMu.Unlock()
+
+	if _, ok := m.operationNames[operationName]; ok {
+		return operationName
+	}
+
+	if len(m.operationNames) >= m.maxOperationNames {
+		return OperationOther
+	}
+
+	m.operationNames[operationName] = struct{}{}
+	return operationName
+}
+
+// Registry returns registry of the metrics, so custom metrics could be added
+func (m *Metrics) Registry() *Registry {
+	return m.registry
+}
+
+// Handler returns http.Handler, which serves metrics in Prometheus text exposition format
+func (m *Metrics) Handler() http.Handler {
+	return m.registry.Handler()
+}
+
+// ObserveRequest records processed GraphQL request
+func (m *Metrics) ObserveRequest(operationName, operationType, outcome string, duration time.Duration) {
+	if m == nil {
+		return
+	}
+
+	operationName = m.operationLabel(operationName)
+	m.requests.Inc(operationName, operationType, outcome)
+	m.requestDuration.Observe(duration.Seconds(), operationName, operationType, outcome)
+}
+
+// ObserveDownstreamRequest records grouped request sent by executor to the service
+func (m *Metrics) ObserveDownstreamRequest(service, outcome string) {
+	if m == nil {
+		return
+	}
+
+	m.downstreamRequests.Inc(service, outcome)
+}
+
+// ObserveDownstreamHTTP records http request to the service. statusCode is 0 if request failed without response.
+func (m *Metrics) ObserveDownstreamHTTP(service string, batchSize int, statusCode int, duration time.Duration) {
+	if m == nil {
+		return
+	}
+
+	var code string
+	if statusCode != 0 {
+		code = strconv.Itoa(statusCode)
+	}
+
+	m.downstreamDuration.Observe(duration.Seconds(), service)
+	m.downstreamBatchSize.Observe(float64(batchSize), service)
+	m.downstreamHTTPResponses.Inc(service, code)
+}
+
+// ObservePlanCache records lookup of query plan cache
+func (m *Metrics) ObservePlanCache(hit bool) {
+	if m == nil {
+		return
+	}
+
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+
+	m.planCacheLookups.Inc(result)
+}
+
+func (m *Metrics) SubscriptionStarted() {
+	if m == nil {
+		return
+	}
+
+	m.activeSubscriptions.Inc()
+}
+
+func (m *Metrics) SubscriptionFinished() {
+	if m == nil {
+		return
+	}
+
+	m.activeSubscriptions.Dec()
+}
+
+func (m *Metrics) ConnectionOpened() {
+	if m == nil {
+		return
+	}
+
+	m.activeConnections.Inc()
+}
+
+func (m *Metrics) ConnectionClosed() {
+	if m == nil {
+		return
+	}
+
+	m.activeConnections.Dec()
+}
+
+type metricsKey struct{}
+
+// ContextWithMetrics returns context, which carries provided metrics down to executor, planner and queryers
+func ContextWithMetrics(ctx context.Context, m *Metrics) context.Context {
+	return context.WithValue(ctx, metricsKey{}, m)
+}
+
+// FromContext returns metrics of the context or nil if there're none
+func FromContext(ctx context.Context) *Metrics {
+	m, _ := ctx.Value(metricsKey{}).(*Metrics)
+	return m
+}
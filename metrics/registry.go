@@ -0,0 +1,260 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Registry keeps metrics and writes them in Prometheus text exposition format
+type Registry struct {
+	metrics []*metricVec
+
+	sync.Mutex
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+type metricType string
+
+const (
+	counterType   metricType = "counter"
+	gaugeType     metricType = "gauge"
+	histogramType metricType = "histogram"
+)
+
+// metricVec is a metric with all its series, one for each combination of label values
+type metricVec struct {
+	name    string
+	help    string
+	typ     metricType
+	labels  []string
+	buckets []float64
+	series  map[string]*series
+
+	sync.Mutex
+}
+
+type series struct {
+	labelValues []string
+	value       float64
+	// histogram only, counts[i] is number of observations in buckets[i], last one is +Inf
+	counts []uint64
+	count  uint64
+}
+
+func (r *Registry) register(m *metricVec) *metricVec {
+	r.Lock()
+	defer r.Unlock()
+
+	m.series = make(map[string]*series)
+	r.metrics = append(r.metrics, m)
+
+	return m
+}
+
+// CounterVec is a counter partitioned by labels
+type CounterVec struct {
+	vec *metricVec
+}
+
+func (r *Registry) NewCounter(name, help string, labels ...string) *CounterVec {
+	return &CounterVec{vec: r.register(&metricVec{name: name, help: help, typ: counterType, labels: labels})}
+}
+
+// Inc increments counter for provided label values by 1
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add increments counter for provided label values, negative values are ignored
+func (c *CounterVec) Add(value float64, labelValues ...string) {
+	if value < 0 {
+		return
+	}
+
+	c.vec.with(labelValues, func(s *series) {
+		s.value += value
+	})
+}
+
+// GaugeVec is a gauge partitioned by labels
+type GaugeVec struct {
+	vec *metricVec
+}
+
+func (r *Registry) NewGauge(name, help string, labels ...string) *GaugeVec {
+	return &GaugeVec{vec: r.register(&metricVec{name: name, help: help, typ: gaugeType, labels: labels})}
+}
+
+func (g *GaugeVec) Inc(labelValues ...string) {
+	g.Add(1, labelValues...)
+}
+
+func (g *GaugeVec) Dec(labelValues ...string) {
+	g.Add(-1, labelValues...)
+}
+
+func (g *GaugeVec) Add(value float64, labelValues ...string) {
+	g.vec.with(labelValues, func(s *series) {
+		s.value += value
+	})
+}
+
+func (g *GaugeVec) Set(value float64, labelValues ...string) {
+	g.vec.with(labelValues, func(s *series) {
+		s.value = value
+	})
+}
+
+// HistogramVec is a histogram partitioned by labels
+type HistogramVec struct {
+	vec *metricVec
+}
+
+// NewHistogram registers histogram with provided upper bounds of buckets, +Inf bucket is added automatically
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	sorted := append([]float64{}, buckets...)
+	sort.Float64s(sorted)
+
+	return &HistogramVec{vec: r.register(&metricVec{name: name, help: help, typ: histogramType, labels: labels, buckets: sorted})}
+}
+
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	h.vec.with(labelValues, func(s *series) {
+		if s.counts == nil {
+			s.counts = make([]uint64, len(h.vec.buckets)+1)
+		}
+
+		i := sort.SearchFloat64s(h.vec.buckets, value)
+		s.counts[i]++
+		s.count++
+		s.value += value
+	})
+}
+
+// with calls fn with series for provided label values, missing values are treated as empty strings
+func (m *metricVec) with(labelValues []string, fn func(*series)) {
+	values := make([]string, len(m.labels))
+	copy(values, labelValues)
+	key := strings.Join(values, "\xff")
+
+	m.Lock()
+	defer m.Unlock()
+
+	s, ok := m.series[key]
+	if !ok {
+		s = &series{labelValues: values}
+		m.series[key] = s
+	}
+
+	fn(s)
+}
+
+// WriteTo writes all metrics in Prometheus text exposition format
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.Lock()
+	metrics := append([]*metricVec{}, r.metrics...)
+	r.Unlock()
+
+	var sb strings.Builder
+	for _, m := range metrics {
+		m.write(&sb)
+	}
+
+	n, err := io.WriteString(w, sb.String())
+	return int64(n), err
+}
+
+// Handler returns http.Handler, which serves metrics in Prometheus text exposition format
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		r.WriteTo(w)
+	})
+}
+
+func (m *metricVec) write(sb *strings.Builder) {
+	m.Lock()
+	defer m.Unlock()
+
+	fmt.Fprintf(sb, "# HELP %s %s\n", m.name, escapeHelp(m.help))
+	fmt.Fprintf(sb, "# TYPE %s %s\n", m.name, m.typ)
+
+	keys := make([]string, 0, len(m.series))
+	for key := range m.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		s := m.series[key]
+
+		if m.typ != histogramType {
+			fmt.Fprintf(sb, "%s%s %s\n", m.name, m.formatLabels(s.labelValues, ""), formatFloat(s.value))
+			continue
+		}
+
+		var cumulative uint64
+		for i, count := range s.counts {
+			cumulative += count
+
+			le := math.Inf(1)
+			if i < len(m.buckets) {
+				le = m.buckets[i]
+			}
+			fmt.Fprintf(sb, "%s_bucket%s %d\n", m.name, m.formatLabels(s.labelValues, formatFloat(le)), cumulative)
+		}
+		fmt.Fprintf(sb, "%s_sum%s %s\n", m.name, m.formatLabels(s.labelValues, ""), formatFloat(s.value))
+		fmt.Fprintf(sb, "%s_count%s %d\n", m.name, m.formatLabels(s.labelValues, ""), s.count)
+	}
+}
+
+// formatLabels returns labels in {name="value",...} form, le label is added if it's not empty
+func (m *metricVec) formatLabels(values []string, le string) string {
+	pairs := make([]string, 0, len(values)+1)
+	for i, name := range m.labels {
+		pairs = append(pairs, name+`="`+escapeLabelValue(values[i])+`"`)
+	}
+	if le != "" {
+		pairs = append(pairs, `le="`+le+`"`)
+	}
+
+	if len(pairs) == 0 {
+		return ""
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+var helpReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeLabelValue(s string) string {
+	return labelValueReplacer.Replace(s)
+}
+
+func escapeHelp(s string) string {
+	return helpReplacer.Replace(s)
+}
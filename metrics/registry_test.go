@@ -0,0 +1,98 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistryWriteTo(t *testing.T) {
+	r := NewRegistry()
+
+	c := r.NewCounter("test_total", "Test counter.", "name")
+	c.Inc("b")
+	c.Add(2, "a\"\n\\")
+	c.Add(-1, "a\"\n\\")
+
+	g := r.NewGauge("test_gauge", "Test\ngauge.")
+	g.Inc()
+	g.Inc()
+	g.Dec()
+
+	h := r.NewHistogram("test_seconds", "Test histogram.", []float64{1, 0.5}, "name")
+	h.Observe(0.5, "a")
+	h.Observe(0.7, "a")
+	h.Observe(3, "a")
+
+	var sb strings.Builder
+	_, err := r.WriteTo(&sb)
+	assert.NoError(t, err)
+
+	assert.Equal(t, `# HELP test_total Test counter.
+# TYPE test_total counter
+test_total{name="a\"\n\\"} 2
+test_total{name="b"} 1
+# HELP test_gauge Test\ngauge.
+# TYPE test_gauge gauge
+test_gauge 1
+# HELP test_seconds Test histogram.
+# TYPE test_seconds histogram
+test_seconds_bucket{name="a",le="0.5"} 1
+test_seconds_bucket{name="a",le="1"} 2
+test_seconds_bucket{name="a",le="+Inf"} 3
+test_seconds_sum{name="a"} 4.2
+test_seconds_count{name="a"} 3
+`, sb.String())
+}
+
+func TestRegistryHandler(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("test_total", "Test counter.").Inc()
+
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/plain")
+	assert.Contains(t, w.Body.String(), "test_total 1\n")
+}
+
+func TestMetricsNil(t *testing.T) {
+	var m *Metrics
+
+	assert.NotPanics(t, func() {
+		m.ObserveRequest("", "", OutcomeSuccess, 0)
+		m.ObserveDownstreamRequest("", OutcomeSuccess)
+		m.ObserveDownstreamHTTP("", 1, 200, 0)
+		m.ObservePlanCache(true)
+		m.SubscriptionStarted()
+		m.SubscriptionFinished()
+		m.ConnectionOpened()
+		m.ConnectionClosed()
+	})
+}
+
+func TestMetricsOperationNames(t *testing.T) {
+	m := New().WithMaxOperationNames(2)
+
+	for _, name := range []string{"A", "", "B", "C", "A", "D"} {
+		m.ObserveRequest(name, "query", OutcomeSuccess, 0)
+	}
+
+	var sb strings.Builder
+	_, err := m.Registry().WriteTo(&sb)
+	assert.NoError(t, err)
+
+	for _, line := range []string{
+		`pebbles_requests_total{operation_name="A",operation_type="query",outcome="success"} 2`,
+		`pebbles_requests_total{operation_name="B",operation_type="query",outcome="success"} 1`,
+		`pebbles_requests_total{operation_name="anonymous",operation_type="query",outcome="success"} 1`,
+		`pebbles_requests_total{operation_name="other",operation_type="query",outcome="success"} 2`,
+	} {
+		assert.Contains(t, sb.String(), line+"\n")
+	}
+	assert.NotContains(t, sb.String(), `operation_name="C"`)
+}
@@ -0,0 +1,67 @@
+package pebbles
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/buildbuildio/pebbles/metrics"
+	"github.com/buildbuildio/pebbles/planner"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+func TestGatewayMetrics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"data": {"test": "YES"}}]`))
+	}))
+	defer server.Close()
+
+	schema := gqlparser.MustLoadSchema(&ast.Source{Name: "fixture", Input: `
+		type Query {
+			test: String!
+		}
+	`})
+
+	gw, err := NewGateway(
+		[]string{server.URL},
+		WithRemoteSchemaIntrospector(&MockRemoteSchemaIntrospector{Res: []*ast.Schema{schema}}),
+		WithPlanner(planner.NewCachedPlanner(time.Minute)),
+		WithMetrics(metrics.New()),
+	)
+	require.NoError(t, err)
+
+	mustQueryGateway(t, gw, `{"query": "query Q { test }"}`)
+	mustQueryGateway(t, gw, `{"query": "query Q { test }"}`)
+	mustQueryGateway(t, gw, `{"query": "{ unknown }"}`)
+
+	w := httptest.NewRecorder()
+	gw.MetricsHandler(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	body := w.Body.String()
+	for _, line := range []string{
+		`pebbles_requests_total{operation_name="Q",operation_type="query",outcome="success"} 2`,
+		`pebbles_requests_total{operation_name="anonymous",operation_type="",outcome="error"} 1`,
+		`pebbles_request_duration_seconds_count{operation_name="Q",operation_type="query",outcome="success"} 2`,
+		`pebbles_downstream_requests_total{service="` + server.URL + `",outcome="success"} 2`,
+		`pebbles_downstream_duration_seconds_count{service="` + server.URL + `"} 2`,
+		`pebbles_downstream_batch_size_bucket{service="` + server.URL + `",le="1"} 2`,
+		`pebbles_downstream_http_responses_total{service="` + server.URL + `",code="200"} 2`,
+		`pebbles_plan_cache_lookups_total{result="hit"} 1`,
+		`pebbles_plan_cache_lookups_total{result="miss"} 1`,
+	} {
+		assert.Contains(t, body, line+"\n")
+	}
+}
+
+func TestGatewayMetricsDisabled(t *testing.T) {
+	gw := &Gateway{}
+
+	w := httptest.NewRecorder()
+	gw.MetricsHandler(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,79 @@
+package persisted
+
+import (
+	"container/list"
+	"sync"
+)
+
+// Cache stores queries by their sha256 hash
+type Cache interface {
+	// Get returns query for provided hash and true if it's found
+	Get(hash string) (string, bool)
+	// Set stores query for provided hash
+	Set(hash string, query string)
+}
+
+type lruEntry struct {
+	hash  string
+	query string
+}
+
+// LRUCache is an in-memory Cache, which keeps up to capacity queries, evicting least recently used ones
+type LRUCache struct {
+	capacity int
+	entries  *list.List
+	items    map[string]*list.Element
+
+	sync.Mutex
+}
+
+var _ Cache = &LRUCache{}
+
+// NewLRUCache returns LRUCache, which keeps up to capacity queries
+func NewLRUCache(capacity int) *LRUCache {
+	return &LRUCache{
+		capacity: capacity,
+		entries:  list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+func (c *LRUCache) Get(hash string) (string, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	el, ok := c.items[hash]
+	if !ok {
+		return "", false
+	}
+
+	c.entries.MoveToFront(el)
+	return el.Value.(*lruEntry).query, true
+}
+
+func (c *LRUCache) Set(hash string, query string) {
+	c.Lock()
+	defer c.Unlock()
+
+	if el, ok := c.items[hash]; ok {
+		el.Value.(*lruEntry).query = query
+		c.entries.MoveToFront(el)
+		return
+	}
+
+	c.items[hash] = c.entries.PushFront(&lruEntry{hash: hash, query: query})
+
+	for c.entries.Len() > c.capacity {
+		oldest := c.entries.Back()
+		c.entries.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruEntry).hash)
+	}
+}
+
+// Len returns number of stored queries
+func (c *LRUCache) Len() int {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.entries.Len()
+}
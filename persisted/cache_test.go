@@ -0,0 +1,37 @@
+package persisted
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLRUCache(t *testing.T) {
+	c := NewLRUCache(2)
+
+	c.Set("a", "{ a }")
+	c.Set("b", "{ b }")
+
+	q, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, "{ a }", q)
+
+	// b is least recently used now
+	c.Set("c", "{ c }")
+	assert.Equal(t, 2, c.Len())
+
+	_, ok = c.Get("b")
+	assert.False(t, ok)
+
+	q, ok = c.Get("c")
+	assert.True(t, ok)
+	assert.Equal(t, "{ c }", q)
+
+	// update doesn't increase size
+	c.Set("c", "{ cc }")
+	assert.Equal(t, 2, c.Len())
+
+	q, ok = c.Get("c")
+	assert.True(t, ok)
+	assert.Equal(t, "{ cc }", q)
+}
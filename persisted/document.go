@@ -0,0 +1,152 @@
+package persisted
+
+import "github.com/vektah/gqlparser/v2/ast"
+
+// documentCopier deep copies query document, so planner is free to modify the copy in place.
+// References between nodes of the document point to their copies, definitions of the schema are shared.
+type documentCopier struct {
+	fragments map[*ast.FragmentDefinition]*ast.FragmentDefinition
+	variables map[*ast.VariableDefinition]*ast.VariableDefinition
+}
+
+func copyDocument(doc *ast.QueryDocument) *ast.QueryDocument {
+	c := &documentCopier{
+		fragments: make(map[*ast.FragmentDefinition]*ast.FragmentDefinition, len(doc.Fragments)),
+		variables: make(map[*ast.VariableDefinition]*ast.VariableDefinition),
+	}
+
+	res := &ast.QueryDocument{Position: doc.Position}
+	if doc.Operations != nil {
+		res.Operations = make(ast.OperationList, len(doc.Operations))
+	}
+	if doc.Fragments != nil {
+		res.Fragments = make(ast.FragmentDefinitionList, len(doc.Fragments))
+	}
+
+	// variables and fragments are copied first, so values and spreads are pointed to the copies
+	for i, op := range doc.Operations {
+		cpy := *op
+		cpy.VariableDefinitions = c.variableDefinitions(op.VariableDefinitions)
+		res.Operations[i] = &cpy
+	}
+	for i, fragment := range doc.Fragments {
+		cpy := *fragment
+		c.fragments[fragment] = &cpy
+		res.Fragments[i] = &cpy
+	}
+
+	for i, op := range doc.Operations {
+		res.Operations[i].Directives = c.directives(op.Directives)
+		res.Operations[i].SelectionSet = c.selectionSet(op.SelectionSet)
+	}
+	for i, fragment := range doc.Fragments {
+		res.Fragments[i].VariableDefinition = c.variableDefinitions(fragment.VariableDefinition)
+		res.Fragments[i].Directives = c.directives(fragment.Directives)
+		res.Fragments[i].SelectionSet = c.selectionSet(fragment.SelectionSet)
+	}
+
+	return res
+}
+
+func (c *documentCopier) variableDefinitions(definitions ast.VariableDefinitionList) ast.VariableDefinitionList {
+	if definitions == nil {
+		return nil
+	}
+
+	res := make(ast.VariableDefinitionList, len(definitions))
+	for i, definition := range definitions {
+		cpy := *definition
+		cpy.DefaultValue = c.value(definition.DefaultValue)
+		cpy.Directives = c.directives(definition.Directives)
+		c.variables[definition] = &cpy
+		res[i] = &cpy
+	}
+
+	return res
+}
+
+func (c *documentCopier) selectionSet(selectionSet ast.SelectionSet) ast.SelectionSet {
+	if selectionSet == nil {
+		return nil
+	}
+
+	res := make(ast.SelectionSet, len(selectionSet))
+	for i, selection := range selectionSet {
+		switch selection := selection.(type) {
+		case *ast.Field:
+			cpy := *selection
+			cpy.Arguments = c.arguments(selection.Arguments)
+			cpy.Directives = c.directives(selection.Directives)
+			cpy.SelectionSet = c.selectionSet(selection.SelectionSet)
+			res[i] = &cpy
+		case *ast.InlineFragment:
+			cpy := *selection
+			cpy.Directives = c.directives(selection.Directives)
+			cpy.SelectionSet = c.selectionSet(selection.SelectionSet)
+			res[i] = &cpy
+		case *ast.FragmentSpread:
+			cpy := *selection
+			cpy.Directives = c.directives(selection.Directives)
+			if definition, ok := c.fragments[selection.Definition]; ok {
+				cpy.Definition = definition
+			}
+			res[i] = &cpy
+		default:
+			res[i] = selection
+		}
+	}
+
+	return res
+}
+
+func (c *documentCopier) directives(directives ast.DirectiveList) ast.DirectiveList {
+	if directives == nil {
+		return nil
+	}
+
+	res := make(ast.DirectiveList, len(directives))
+	for i, directive := range directives {
+		cpy := *directive
+		cpy.Arguments = c.arguments(directive.Arguments)
+		res[i] = &cpy
+	}
+
+	return res
+}
+
+func (c *documentCopier) arguments(arguments ast.ArgumentList) ast.ArgumentList {
+	if arguments == nil {
+		return nil
+	}
+
+	res := make(ast.ArgumentList, len(arguments))
+	for i, argument := range arguments {
+		cpy := *argument
+		cpy.Value = c.value(argument.Value)
+		res[i] = &cpy
+	}
+
+	return res
+}
+
+func (c *documentCopier) value(value *ast.Value) *ast.Value {
+	if value == nil {
+		return nil
+	}
+
+	cpy := *value
+	if definition, ok := c.variables[value.VariableDefinition]; ok {
+		cpy.VariableDefinition = definition
+	}
+
+	if value.Children != nil {
+		cpy.Children = make(ast.ChildValueList, len(value.Children))
+		for i, child := range value.Children {
+			childCpy := *child
+			childCpy.Value = c.value(child.Value)
+			cpy.Children[i] = &childCpy
+		}
+	}
+
+	return &cpy
+}
@@ -0,0 +1,49 @@
+package persisted
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/formatter"
+)
+
+func formatDocument(doc *ast.QueryDocument) string {
+	var buf bytes.Buffer
+	formatter.NewFormatter(&buf).FormatQueryDocument(doc)
+	return buf.String()
+}
+
+func TestCopyDocument(t *testing.T) {
+	schema := gqlparser.MustLoadSchema(&ast.Source{Name: "fixture", Input: `
+		type Query {
+			test(input: [String!]): String!
+		}
+	`})
+
+	doc, err := gqlparser.LoadQuery(schema, `
+		query Q($input: [String!] = ["a"]) { ...F ... on Query @include(if: true) { test } }
+		fragment F on Query { t: test(input: $input) }
+	`)
+	require.Nil(t, err)
+
+	cpy := copyDocument(doc)
+	assert.Equal(t, doc, cpy)
+	assert.Equal(t, formatDocument(doc), formatDocument(cpy))
+
+	// references are pointed to the copies
+	spread := cpy.Operations[0].SelectionSet[0].(*ast.FragmentSpread)
+	assert.Same(t, cpy.Fragments[0], spread.Definition)
+	value := cpy.Fragments[0].SelectionSet[0].(*ast.Field).Arguments[0].Value
+	assert.Same(t, cpy.Operations[0].VariableDefinitions[0], value.VariableDefinition)
+
+	// modifying the copy doesn't affect the original
+	original := formatDocument(doc)
+	cpy.Fragments[0].SelectionSet[0].(*ast.Field).Alias = "other"
+	cpy.Operations[0].SelectionSet[1].(*ast.InlineFragment).Directives = nil
+	cpy.Operations[0].VariableDefinitions[0].DefaultValue.Children[0].Value.Raw = "b"
+	assert.Equal(t, original, formatDocument(doc))
+}
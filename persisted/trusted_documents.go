@@ -0,0 +1,136 @@
+package persisted
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/buildbuildio/pebbles/gqlerrors"
+	"github.com/buildbuildio/pebbles/requests"
+
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// TrustedDocument is an operation registered ahead of time
+type TrustedDocument struct {
+	ID       string `json:"id"`
+	Hash     string `json:"hash"`
+	Document string `json:"document"`
+}
+
+// TrustedDocuments is an allowlist of operations. Only registered operations can be executed,
+// clients may refer to them by id, sha256 hash or send the whole document.
+type TrustedDocuments struct {
+	byID   map[string]*TrustedDocument
+	byHash map[string]*TrustedDocument
+
+	// schema for which documents are parsed, parsed documents are dropped when schema changes
+	schema *ast.Schema
+	parsed map[string]*ast.QueryDocument
+
+	sync.RWMutex
+}
+
+// NewTrustedDocuments returns TrustedDocuments with provided documents.
+// If hash of the document is omitted, it's computed, otherwise it's checked to match the document.
+func NewTrustedDocuments(documents []*TrustedDocument) (*TrustedDocuments, error) {
+	td := &TrustedDocuments{
+		byID:   make(map[string]*TrustedDocument),
+		byHash: make(map[string]*TrustedDocument),
+		parsed: make(map[string]*ast.QueryDocument),
+	}
+
+	for _, doc := range documents {
+		if doc.Document == "" {
+			return nil, fmt.Errorf("trusted document %s is empty", doc.ID)
+		}
+
+		hash := hashDocument(doc.Document)
+		if doc.Hash != "" && strings.ToLower(doc.Hash) != hash {
+			return nil, fmt.Errorf("hash of trusted document %s doesn't match its content", doc.ID)
+		}
+
+		cpy := *doc
+		cpy.Hash = hash
+
+		if cpy.ID != "" {
+			if _, ok := td.byID[cpy.ID]; ok {
+				return nil, fmt.Errorf("duplicated trusted document id %s", cpy.ID)
+			}
+			td.byID[cpy.ID] = &cpy
+		}
+		td.byHash[hash] = &cpy
+	}
+
+	return td, nil
+}
+
+// LoadTrustedDocuments reads manifest, which is JSON array of {id, hash, document} entries
+func LoadTrustedDocuments(r io.Reader) (*TrustedDocuments, error) {
+	var documents []*TrustedDocument
+	if err := json.NewDecoder(r).Decode(&documents); err != nil {
+		return nil, fmt.Errorf("unable to parse trusted documents manifest: %w", err)
+	}
+
+	return NewTrustedDocuments(documents)
+}
+
+// Load finds trusted document for the request and returns it parsed and validated against provided schema.
+// Query of the request is replaced with the document text. Document is parsed once for the schema,
+// each request receives its own copy, as planner modifies it in place.
+func (td *TrustedDocuments) Load(schema *ast.Schema, request *requests.Request) (*ast.QueryDocument, error) {
+	doc := td.find(request)
+	if doc == nil {
+		return nil, gqlerrors.NewError(gqlerrors.ValidationFailedError, errors.New("operation is not in the list of trusted documents"))
+	}
+
+	request.Query = doc.Document
+
+	td.RLock()
+	query, ok := td.parsed[doc.Hash]
+	isSameSchema := td.schema == schema
+	td.RUnlock()
+
+	if ok && isSameSchema {
+		return copyDocument(query), nil
+	}
+
+	query, qerr := gqlparser.LoadQuery(schema, doc.Document)
+	if qerr != nil {
+		return nil, qerr
+	}
+
+	td.Lock()
+	defer td.Unlock()
+
+	if td.schema != schema {
+		td.schema = schema
+		td.parsed = make(map[string]*ast.QueryDocument)
+	}
+	td.parsed[doc.Hash] = query
+
+	return copyDocument(query), nil
+}
+
+func (td *TrustedDocuments) find(request *requests.Request) *TrustedDocument {
+	if request.DocumentID != "" {
+		return td.byID[request.DocumentID]
+	}
+
+	if pq := request.GetPersistedQuery(); pq != nil && request.Query == "" {
+		return td.byHash[strings.ToLower(pq.Sha256Hash)]
+	}
+
+	return td.byHash[hashDocument(request.Query)]
+}
+
+func hashDocument(document string) string {
+	sum := sha256.Sum256([]byte(document))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,166 @@
+package persisted
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/buildbuildio/pebbles/gqlerrors"
+	"github.com/buildbuildio/pebbles/merger"
+	"github.com/buildbuildio/pebbles/planner"
+	"github.com/buildbuildio/pebbles/requests"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+var trustedDocumentsSchema = `
+	type Query {
+		test: String!
+		other: String!
+	}
+`
+
+func TestLoadTrustedDocuments(t *testing.T) {
+	td, err := LoadTrustedDocuments(strings.NewReader(`[
+		{"id": "test", "document": "{ test }"},
+		{"id": "other", "hash": "` + hashDocument("{ other }") + `", "document": "{ other }"}
+	]`))
+	require.NoError(t, err)
+
+	assert.Len(t, td.byID, 2)
+	assert.Len(t, td.byHash, 2)
+	assert.Equal(t, hashDocument("{ test }"), td.byID["test"].Hash)
+}
+
+func TestLoadTrustedDocumentsErrors(t *testing.T) {
+	for _, manifest := range []string{
+		`{}`,
+		`[{"id": "test", "document": ""}]`,
+		`[{"id": "test", "hash": "wrong", "document": "{ test }"}]`,
+		`[{"id": "test", "document": "{ test }"}, {"id": "test", "document": "{ other }"}]`,
+	} {
+		_, err := LoadTrustedDocuments(strings.NewReader(manifest))
+		assert.Error(t, err, manifest)
+	}
+}
+
+func TestTrustedDocumentsLoad(t *testing.T) {
+	schema := gqlparser.MustLoadSchema(&ast.Source{Name: "fixture", Input: trustedDocumentsSchema})
+
+	td, err := NewTrustedDocuments([]*TrustedDocument{{ID: "test", Document: "{ test }"}})
+	require.NoError(t, err)
+
+	for name, request := range map[string]*requests.Request{
+		"id":    {DocumentID: "test"},
+		"query": {Query: "{ test }"},
+		"hash": {Extensions: &requests.Extensions{
+			PersistedQuery: &requests.PersistedQuery{Version: 1, Sha256Hash: hashDocument("{ test }")},
+		}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			query, err := td.Load(schema, request)
+			require.NoError(t, err)
+			require.Len(t, query.Operations, 1)
+			assert.Equal(t, "{ test }", request.Query)
+		})
+	}
+
+	// each request receives its own document, as planner modifies it
+	first, err := td.Load(schema, &requests.Request{DocumentID: "test"})
+	require.NoError(t, err)
+	second, err := td.Load(schema, &requests.Request{DocumentID: "test"})
+	require.NoError(t, err)
+	assert.NotSame(t, first, second)
+}
+
+func TestTrustedDocumentsParsedOnce(t *testing.T) {
+	schema := gqlparser.MustLoadSchema(&ast.Source{Name: "fixture", Input: trustedDocumentsSchema})
+
+	td, err := NewTrustedDocuments([]*TrustedDocument{{ID: "test", Document: "{ test }"}})
+	require.NoError(t, err)
+	hash := hashDocument("{ test }")
+
+	first, err := td.Load(schema, &requests.Request{DocumentID: "test"})
+	require.NoError(t, err)
+	parsed := td.parsed[hash]
+	require.NotNil(t, parsed)
+
+	// document isn't parsed again, but each request receives its own copy
+	second, err := td.Load(schema, &requests.Request{DocumentID: "test"})
+	require.NoError(t, err)
+	assert.Same(t, parsed, td.parsed[hash])
+	assert.Equal(t, parsed, second)
+	assert.NotSame(t, parsed, first)
+	assert.NotSame(t, parsed.Operations[0].SelectionSet[0], second.Operations[0].SelectionSet[0])
+
+	// schema is reloaded
+	reloaded := gqlparser.MustLoadSchema(&ast.Source{Name: "fixture", Input: trustedDocumentsSchema})
+	_, err = td.Load(reloaded, &requests.Request{DocumentID: "test"})
+	require.NoError(t, err)
+	assert.NotSame(t, parsed, td.parsed[hash])
+}
+
+func TestTrustedDocumentsPlanTwice(t *testing.T) {
+	schema := gqlparser.MustLoadSchema(&ast.Source{Name: "fixture", Input: `
+		interface Node {
+			id: ID!
+		}
+
+		type Author implements Node {
+			id: ID!
+			name: String!
+			books: [String!]!
+		}
+
+		type Query {
+			getAuthors: [Author!]!
+			node(id: ID!): Node
+		}
+	`})
+	tum := merger.TypeURLMap{
+		"Query":  {Fields: map[string]string{"getAuthors": "0"}},
+		"Author": {Fields: map[string]string{"name": "0", "books": "1"}, IsImplementsNode: true},
+	}
+
+	td, err := NewTrustedDocuments([]*TrustedDocument{{ID: "test", Document: "{ getAuthors { name books } }"}})
+	require.NoError(t, err)
+
+	// id injected by the planner is scrubbed for every request
+	for i := 0; i < 2; i++ {
+		request := &requests.Request{DocumentID: "test"}
+		query, err := td.Load(schema, request)
+		require.NoError(t, err)
+
+		plan, err := (planner.SequentialPlanner)(nil).Plan(&planner.PlanningContext{
+			Operation:  query.Operations[0],
+			Request:    request,
+			Schema:     schema,
+			TypeURLMap: tum,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, planner.ScrubFields{"getAuthors": {"Author": {"id"}}}, plan.ScrubFields)
+	}
+}
+
+func TestTrustedDocumentsLoadNotRegistered(t *testing.T) {
+	schema := gqlparser.MustLoadSchema(&ast.Source{Name: "fixture", Input: trustedDocumentsSchema})
+
+	td, err := NewTrustedDocuments([]*TrustedDocument{{ID: "test", Document: "{ test }"}})
+	require.NoError(t, err)
+
+	for name, request := range map[string]*requests.Request{
+		"id":    {DocumentID: "other"},
+		"query": {Query: "{ other }"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := td.Load(schema, request)
+			require.Error(t, err)
+
+			gqlErr, ok := err.(*gqlerrors.Error)
+			require.True(t, ok)
+			assert.Equal(t, gqlerrors.ValidationFailedError, gqlErr.Extensions["code"])
+		})
+	}
+}
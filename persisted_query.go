@@ -0,0 +1,67 @@
+package pebbles
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"github.com/buildbuildio/pebbles/gqlerrors"
+	"github.com/buildbuildio/pebbles/persisted"
+	"github.com/buildbuildio/pebbles/requests"
+)
+
+// WithPersistedQueryCache enables automatic persisted queries. Clients may send sha256 hash of the query
+// in extensions.persistedQuery instead of the query itself. If hash is unknown, client receives
+// PersistedQueryNotFound error and is expected to retry with both hash and query, so query is registered.
+func WithPersistedQueryCache(cache persisted.Cache) GatewayOption {
+	return func(g *Gateway) {
+		g.persistedQueryCache = cache
+	}
+}
+
+// WithTrustedDocuments enables allowlist mode: only operations from provided trusted documents are executed.
+// Clients may refer to them with documentId, persisted query hash or send the whole document.
+func WithTrustedDocuments(td *persisted.TrustedDocuments) GatewayOption {
+	return func(g *Gateway) {
+		g.trustedDocuments = td
+	}
+}
+
+// resolvePersistedQuery sets query of the request from persisted query cache if only hash is provided,
+// or registers query in the cache if both hash and query are provided
+func (g *Gateway) resolvePersistedQuery(request *requests.Request) *gqlerrors.Error {
+	pq := request.GetPersistedQuery()
+	// in allowlist mode hashes refer to trusted documents, so queries are never registered
+	if pq == nil || g.trustedDocuments != nil {
+		return nil
+	}
+
+	if g.persistedQueryCache == nil {
+		return gqlerrors.NewError(gqlerrors.PersistedQueryNotSupportedError, errors.New("PersistedQueryNotSupported"))
+	}
+
+	if pq.Version != 1 {
+		return gqlerrors.NewError(gqlerrors.PersistedQueryNotSupportedError, errors.New("unsupported persisted query version"))
+	}
+
+	hash := strings.ToLower(pq.Sha256Hash)
+
+	if request.Query == "" {
+		query, ok := g.persistedQueryCache.Get(hash)
+		if !ok {
+			return gqlerrors.NewError(gqlerrors.PersistedQueryNotFoundError, errors.New("PersistedQueryNotFound"))
+		}
+
+		request.Query = query
+		return nil
+	}
+
+	sum := sha256.Sum256([]byte(request.Query))
+	if hex.EncodeToString(sum[:]) != hash {
+		return gqlerrors.NewError(gqlerrors.ValidationFailedError, errors.New("provided sha does not match query"))
+	}
+
+	g.persistedQueryCache.Set(hash, request.Query)
+	return nil
+}
@@ -0,0 +1,112 @@
+package pebbles
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/buildbuildio/pebbles/gqlerrors"
+	"github.com/buildbuildio/pebbles/persisted"
+	"github.com/buildbuildio/pebbles/planner"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+func newPersistedQueryTestGateway(t *testing.T, options ...GatewayOption) *Gateway {
+	schema := gqlparser.MustLoadSchema(&ast.Source{Name: "fixture", Input: `
+		type Query {
+			test: String!
+		}
+	`})
+
+	options = append(
+		options,
+		WithExecutor(&MockExecutor{Res: map[string]interface{}{"test": "YES"}}),
+		WithPlanner(&MockPlanner{Res: &planner.QueryPlan{}}),
+		WithRemoteSchemaIntrospector(&MockRemoteSchemaIntrospector{Res: []*ast.Schema{schema}}),
+	)
+
+	gw, err := NewGateway([]string{""}, options...)
+	require.NoError(t, err)
+
+	return gw
+}
+
+func errorCode(t *testing.T, res map[string]interface{}) string {
+	t.Helper()
+
+	errs, ok := res["errors"].([]interface{})
+	require.True(t, ok)
+	require.Len(t, errs, 1)
+
+	return errs[0].(map[string]interface{})["extensions"].(map[string]interface{})["code"].(string)
+}
+
+func TestGatewayPersistedQuery(t *testing.T) {
+	gw := newPersistedQueryTestGateway(t, WithPersistedQueryCache(persisted.NewLRUCache(10)))
+
+	query := "{ test }"
+	sum := sha256.Sum256([]byte(query))
+	hash := hex.EncodeToString(sum[:])
+
+	hashOnly := fmt.Sprintf(`{"extensions": {"persistedQuery": {"version": 1, "sha256Hash": "%s"}}}`, hash)
+
+	// unknown hash
+	res := mustQueryGateway(t, gw, hashOnly)
+	assert.Equal(t, gqlerrors.PersistedQueryNotFoundError, errorCode(t, res))
+	assert.Nil(t, res["data"])
+
+	// register query
+	res = mustQueryGateway(t, gw, fmt.Sprintf(`{"query": "%s", "extensions": {"persistedQuery": {"version": 1, "sha256Hash": "%s"}}}`, query, hash))
+	assert.Empty(t, res["errors"])
+	assert.Equal(t, map[string]interface{}{"test": "YES"}, res["data"])
+
+	// query is found by hash
+	res = mustQueryGateway(t, gw, hashOnly)
+	assert.Empty(t, res["errors"])
+	assert.Equal(t, map[string]interface{}{"test": "YES"}, res["data"])
+}
+
+func TestGatewayPersistedQueryHashMismatch(t *testing.T) {
+	gw := newPersistedQueryTestGateway(t, WithPersistedQueryCache(persisted.NewLRUCache(10)))
+
+	res := mustQueryGateway(t, gw, `{"query": "{ test }", "extensions": {"persistedQuery": {"version": 1, "sha256Hash": "wrong"}}}`)
+	assert.Equal(t, gqlerrors.ValidationFailedError, errorCode(t, res))
+
+	res = mustQueryGateway(t, gw, `{"extensions": {"persistedQuery": {"version": 1, "sha256Hash": "wrong"}}}`)
+	assert.Equal(t, gqlerrors.PersistedQueryNotFoundError, errorCode(t, res))
+}
+
+func TestGatewayPersistedQueryNotSupported(t *testing.T) {
+	gw := newPersistedQueryTestGateway(t)
+
+	res := mustQueryGateway(t, gw, `{"extensions": {"persistedQuery": {"version": 1, "sha256Hash": "hash"}}}`)
+	assert.Equal(t, gqlerrors.PersistedQueryNotSupportedError, errorCode(t, res))
+}
+
+func TestGatewayTrustedDocuments(t *testing.T) {
+	td, err := persisted.NewTrustedDocuments([]*persisted.TrustedDocument{{ID: "test", Document: "{ test }"}})
+	require.NoError(t, err)
+
+	gw := newPersistedQueryTestGateway(t, WithTrustedDocuments(td), WithPersistedQueryCache(persisted.NewLRUCache(10)))
+
+	res := mustQueryGateway(t, gw, `{"documentId": "test"}`)
+	assert.Empty(t, res["errors"])
+	assert.Equal(t, map[string]interface{}{"test": "YES"}, res["data"])
+
+	res = mustQueryGateway(t, gw, `{"query": "{ test }"}`)
+	assert.Empty(t, res["errors"])
+
+	res = mustQueryGateway(t, gw, `{"query": "{ test __typename }"}`)
+	assert.Equal(t, gqlerrors.ValidationFailedError, errorCode(t, res))
+
+	// persisted queries can't be registered in allowlist mode
+	query := "{ test __typename }"
+	sum := sha256.Sum256([]byte(query))
+	hash := hex.EncodeToString(sum[:])
+	res = mustQueryGateway(t, gw, fmt.Sprintf(`{"query": "%s", "extensions": {"persistedQuery": {"version": 1, "sha256Hash": "%s"}}}`, query, hash))
+	assert.Equal(t, gqlerrors.ValidationFailedError, errorCode(t, res))
+}
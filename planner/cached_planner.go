@@ -6,15 +6,26 @@ import (
 	"time"
 
 	"github.com/buildbuildio/pebbles/format"
+	"github.com/buildbuildio/pebbles/metrics"
+	"github.com/buildbuildio/pebbles/tracing"
+
+	"github.com/vektah/gqlparser/v2/ast"
 )
 
 type hashKey [20]byte
 
+// CacheHitAttribute is set on current tracing span, showing if plan was taken from cache
+const CacheHitAttribute = "graphql.plan.cache_hit"
+
 type CachedPlanner struct {
 	TTL time.Duration
 
 	executor Planner
 
+	// schema for which plans are cached, plans for other schemas are not cached.
+	// nil means any schema
+	schema *ast.Schema
+
 	cache       map[hashKey]*QueryPlan
 	cacheTimers map[hashKey]time.Time
 
@@ -64,17 +75,46 @@ func (cp *CachedPlanner) clean() {
 	}
 }
 
+// Reset drops all cached plans. After reset only plans for provided schema are cached,
+// so requests which still use previous schema are planned without cache.
+func (cp *CachedPlanner) Reset(schema *ast.Schema) {
+	cp.Lock()
+	defer cp.Unlock()
+
+	cp.schema = schema
+	cp.cache = make(map[hashKey]*QueryPlan)
+	cp.cacheTimers = make(map[hashKey]time.Time)
+}
+
+func (cp *CachedPlanner) isCacheable(ctx *PlanningContext) bool {
+	cp.RLock()
+	defer cp.RUnlock()
+
+	return cp.schema == nil || cp.schema == ctx.Schema
+}
+
 func (cp *CachedPlanner) Plan(ctx *PlanningContext) (*QueryPlan, error) {
+	if !cp.isCacheable(ctx) {
+		return cp.executor.Plan(ctx)
+	}
+
 	hk := cp.hash(ctx)
 
+	span := tracing.SpanFromContext(ctx.Request.Context())
+
 	cp.clean()
 	cp.RLock()
 	if res, ok := cp.cache[hk]; ok {
 		cp.RUnlock()
+		span.SetAttributes(tracing.Bool(CacheHitAttribute, true))
+		metrics.FromContext(ctx.Request.Context()).ObservePlanCache(true)
 		return res, nil
 	}
 	cp.RUnlock()
 
+	span.SetAttributes(tracing.Bool(CacheHitAttribute, false))
+	metrics.FromContext(ctx.Request.Context()).ObservePlanCache(false)
+
 	res, err := cp.executor.Plan(ctx)
 	if err != nil {
 		return nil, err
@@ -83,6 +123,11 @@ func (cp *CachedPlanner) Plan(ctx *PlanningContext) (*QueryPlan, error) {
 	cp.Lock()
 	defer cp.Unlock()
 
+	// schema could be reset while planning, plan for previous schema must not be cached
+	if cp.schema != nil && cp.schema != ctx.Schema {
+		return res, nil
+	}
+
 	cp.cache[hk] = res
 	cp.cacheTimers[hk] = time.Now().UTC().Add(cp.TTL)
 
@@ -5,6 +5,8 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
 )
 
 type EmptyPlanner struct {
@@ -92,3 +94,59 @@ func TestCachedPlannerSimplePlanCacheExpires(t *testing.T) {
 
 	assert.NotEqual(t, afterTime, beforeTime)
 }
+
+func TestCachedPlannerReset(t *testing.T) {
+	cp := NewCachedPlanner(time.Hour)
+	query := `{ getMovies { id }}`
+
+	mustRunPlanner(t, cp, simpleSchema, query, simpleTum)
+	assert.Len(t, cp.cache, 1)
+
+	s := gqlparser.MustLoadSchema(&ast.Source{Name: "fixture", Input: simpleSchema})
+	cp.Reset(s)
+	assert.Len(t, cp.cache, 0)
+
+	// plans for other schemas are not cached anymore
+	mustRunPlanner(t, cp, simpleSchema, query, simpleTum)
+	assert.Len(t, cp.cache, 0)
+
+	operation := gqlparser.MustLoadQuery(s, query)
+	_, err := cp.Plan(&PlanningContext{
+		Operation:  operation.Operations[0],
+		Schema:     s,
+		TypeURLMap: simpleTum,
+	})
+	assert.NoError(t, err)
+	assert.Len(t, cp.cache, 1)
+}
+
+// resettingPlanner resets cached planner while planning, as schema reload does
+type resettingPlanner struct {
+	cp     *CachedPlanner
+	schema *ast.Schema
+}
+
+func (rp resettingPlanner) Plan(ctx *PlanningContext) (*QueryPlan, error) {
+	rp.cp.Reset(rp.schema)
+	return &QueryPlan{}, nil
+}
+
+func TestCachedPlannerResetWhilePlanning(t *testing.T) {
+	oldSchema := gqlparser.MustLoadSchema(&ast.Source{Name: "fixture", Input: simpleSchema})
+	newSchema := gqlparser.MustLoadSchema(&ast.Source{Name: "fixture", Input: simpleSchema})
+
+	cp := NewCachedPlanner(time.Hour)
+	cp.Reset(oldSchema)
+	cp.WithPlannerExecutor(resettingPlanner{cp: cp, schema: newSchema})
+
+	operation := gqlparser.MustLoadQuery(oldSchema, `{ getMovies { id }}`)
+	_, err := cp.Plan(&PlanningContext{
+		Operation:  operation.Operations[0],
+		Schema:     oldSchema,
+		TypeURLMap: simpleTum,
+	})
+	assert.NoError(t, err)
+
+	// plan for previous schema isn't cached
+	assert.Len(t, cp.cache, 0)
+}
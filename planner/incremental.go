@@ -0,0 +1,235 @@
+package planner
+
+import (
+	"encoding/json"
+
+	"github.com/buildbuildio/pebbles/common"
+	"github.com/samber/lo"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// Directives of incremental delivery. They're executed by the gateway and never sent to services.
+const (
+	DeferDirectiveName  = "defer"
+	StreamDirectiveName = "stream"
+)
+
+// IncrementalDirectivesSchema contains definitions of @defer and @stream directives.
+// Gateway adds them to merged schema, so queries using them pass validation even if services don't support them.
+const IncrementalDirectivesSchema = `
+directive @defer(if: Boolean! = true, label: String) on FRAGMENT_SPREAD | INLINE_FRAGMENT
+directive @stream(if: Boolean! = true, label: String, initialCount: Int = 0) on FIELD
+`
+
+// StreamField is a list field marked with @stream directive
+type StreamField struct {
+	// Path contains aliases of fields from the root of the operation to the list field, f.e. ["users", "books"]
+	Path      []string
+	Directive *ast.Directive `json:"-"`
+}
+
+// Arguments returns whether streaming is enabled, number of items sent in initial result and label of the list
+func (sf *StreamField) Arguments(variables map[string]interface{}) (bool, int, string) {
+	initialCount, _ := directiveIntArgument(sf.Directive, "initialCount", variables)
+	if initialCount < 0 {
+		initialCount = 0
+	}
+
+	return isDirectiveEnabled(sf.Directive, variables), initialCount, directiveLabel(sf.Directive, variables)
+}
+
+// IsDeferred returns true if step was cut at fragment marked with @defer and deferring isn't disabled by variables
+func (s *QueryPlanStep) IsDeferred(variables map[string]interface{}) bool {
+	return s.Defer != nil && isDirectiveEnabled(s.Defer, variables)
+}
+
+// DeferLabel returns label of fragment, which step was cut at
+func (s *QueryPlanStep) DeferLabel(variables map[string]interface{}) string {
+	if s.Defer == nil {
+		return ""
+	}
+
+	return directiveLabel(s.Defer, variables)
+}
+
+// deferDirective returns @defer directive from the list unless it's disabled with literal if: false.
+// Variables aren't known during planning, as plans are cached, so they're checked during execution.
+func deferDirective(directives ast.DirectiveList) *ast.Directive {
+	d := directives.ForName(DeferDirectiveName)
+	if d == nil {
+		return nil
+	}
+
+	if arg := d.Arguments.ForName("if"); arg != nil && arg.Value != nil && arg.Value.Kind == ast.BooleanValue && arg.Value.Raw == "false" {
+		return nil
+	}
+
+	return d
+}
+
+// removeIncrementalDirectives returns directives without @defer and @stream
+func removeIncrementalDirectives(directives ast.DirectiveList) ast.DirectiveList {
+	res := lo.Filter(directives, func(d *ast.Directive, _ int) bool {
+		return d.Name != DeferDirectiveName && d.Name != StreamDirectiveName
+	})
+	if len(res) == 0 {
+		return nil
+	}
+
+	return res
+}
+
+// extractStreamFields returns list fields marked with @stream along with copy of selection set without the directive.
+// Operation itself isn't modified, so it can be planned again.
+func extractStreamFields(selectionSet ast.SelectionSet) (ast.SelectionSet, []*StreamField) {
+	return collectStreamFields(selectionSet, nil)
+}
+
+func collectStreamFields(selectionSet ast.SelectionSet, path []string) (ast.SelectionSet, []*StreamField) {
+	if selectionSet == nil {
+		return nil, nil
+	}
+
+	res := make(ast.SelectionSet, 0, len(selectionSet))
+	var streamFields []*StreamField
+	for _, selection := range selectionSet {
+		var nested []*StreamField
+		switch selection := selection.(type) {
+		case *ast.Field:
+			field := *selection
+			fieldPath := append(append([]string{}, path...), selection.Alias)
+			if d := selection.Directives.ForName(StreamDirectiveName); d != nil {
+				streamFields = append(streamFields, &StreamField{Path: fieldPath, Directive: d})
+				field.Directives = removeIncrementalDirectives(selection.Directives)
+			}
+			field.SelectionSet, nested = collectStreamFields(selection.SelectionSet, fieldPath)
+			res = append(res, &field)
+		case *ast.InlineFragment:
+			fragment := *selection
+			fragment.SelectionSet, nested = collectStreamFields(selection.SelectionSet, path)
+			res = append(res, &fragment)
+		case *ast.FragmentSpread:
+			// fragment can be spread in many places, so each spread gets its own copy of the definition
+			spread := *selection
+			definition := *selection.Definition
+			definition.SelectionSet, nested = collectStreamFields(selection.Definition.SelectionSet, path)
+			spread.Definition = &definition
+			res = append(res, &spread)
+		default:
+			res = append(res, selection)
+		}
+		streamFields = append(streamFields, nested...)
+	}
+
+	return res, streamFields
+}
+
+// splitDeferredFragments separates fragments marked with @defer from the rest of the selection set
+func splitDeferredFragments(selectionSet ast.SelectionSet) (ast.SelectionSet, []*ast.InlineFragment) {
+	var rest ast.SelectionSet
+	var deferred []*ast.InlineFragment
+	for _, selection := range selectionSet {
+		if fragment, ok := selection.(*ast.InlineFragment); ok && deferDirective(fragment.Directives) != nil {
+			deferred = append(deferred, fragment)
+			continue
+		}
+		rest = append(rest, selection)
+	}
+
+	return rest, deferred
+}
+
+// cutDeferredFragment plans fields of fragment marked with @defer as separate steps, which fetch them with node query
+// after the object itself is fetched. Only id and __typename fields stay in current step, as they're required to find the object.
+func cutDeferredFragment(ctx *PlanningContext, insertionPoint []string, parentType, location string, fragment *ast.InlineFragment) (ast.SelectionSet, []*QueryPlanStep, error) {
+	var keys, deferred ast.SelectionSet
+	for _, field := range common.SelectionSetToFields(fragment.SelectionSet, nil) {
+		if field.Alias == field.Name && (field.Name == common.IDFieldName || field.Name == common.TypenameFieldName) {
+			keys = append(keys, field)
+			continue
+		}
+		deferred = append(deferred, field)
+	}
+
+	var steps []*QueryPlanStep
+	if len(deferred) != 0 {
+		var err error
+		steps, err = createQueryPlanSteps(ctx, insertionPoint, fragment.TypeCondition, location, deferred)
+		if err != nil {
+			return nil, nil, err
+		}
+		markDeferred(steps, fragment.Directives.ForName(DeferDirectiveName))
+	}
+
+	if len(keys) == 0 || fragment.TypeCondition == parentType {
+		return keys, steps, nil
+	}
+
+	keysFragment := *fragment
+	keysFragment.Directives = removeIncrementalDirectives(fragment.Directives)
+	keysFragment.SelectionSet = keys
+	return ast.SelectionSet{&keysFragment}, steps, nil
+}
+
+// markDeferred sets defer directive for steps and all their dependent steps, which don't belong to nested deferred fragment
+func markDeferred(steps []*QueryPlanStep, directive *ast.Directive) {
+	for _, step := range steps {
+		if step.Defer == nil {
+			step.Defer = directive
+		}
+		markDeferred(step.Then, step.Defer)
+	}
+}
+
+func isDirectiveEnabled(d *ast.Directive, variables map[string]interface{}) bool {
+	v, ok := directiveArgument(d, "if", variables)
+	if !ok {
+		return true
+	}
+
+	enabled, ok := v.(bool)
+	return !ok || enabled
+}
+
+func directiveLabel(d *ast.Directive, variables map[string]interface{}) string {
+	v, _ := directiveArgument(d, "label", variables)
+	label, _ := v.(string)
+	return label
+}
+
+func directiveIntArgument(d *ast.Directive, name string, variables map[string]interface{}) (int, bool) {
+	v, ok := directiveArgument(d, name, variables)
+	if !ok {
+		return 0, false
+	}
+
+	switch v := v.(type) {
+	case int64:
+		return int(v), true
+	case int:
+		return v, true
+	case float64:
+		return int(v), true
+	case json.Number:
+		i, err := v.Int64()
+		return int(i), err == nil
+	}
+
+	return 0, false
+}
+
+// directiveArgument returns value of argument given literally or with variable
+func directiveArgument(d *ast.Directive, name string, variables map[string]interface{}) (interface{}, bool) {
+	arg := d.Arguments.ForName(name)
+	if arg == nil || arg.Value == nil {
+		return nil, false
+	}
+
+	if arg.Value.Kind == ast.Variable {
+		v, ok := variables[arg.Value.Raw]
+		return v, ok && v != nil
+	}
+
+	v, err := arg.Value.Value(variables)
+	return v, err == nil && v != nil
+}
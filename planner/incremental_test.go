@@ -0,0 +1,153 @@
+package planner
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+var incrementalSchema = simpleSchema + IncrementalDirectivesSchema
+
+func TestPlanDeferredFragment(t *testing.T) {
+	query := `{ getMovies { title ... @defer(label: "author") { author { name } } } }`
+
+	actual, plan := mustRunPlanner(t, seqPlan, incrementalSchema, query, simpleTum)
+
+	expected := `{
+		"RootSteps": [
+		  {
+			"URL": "0",
+			"ParentType": "Query",
+			"OperationName": null,
+			"SelectionSet": "{ getMovies { id title } }",
+			"InsertionPoint": null,
+			"Then": [
+				{
+					"URL": "0",
+					"ParentType": "Movie",
+					"OperationName": null,
+					"SelectionSet": "query ($id: ID!) { node(id: $id) { ... on Movie { author { id } } } }",
+					"InsertionPoint": ["getMovies"],
+					"IsDeferred": true,
+					"Then": [
+						{
+							"URL": "1",
+							"ParentType": "Author",
+							"OperationName": null,
+							"SelectionSet": "query ($id: ID!) { node(id: $id) { ... on Author { name } } }",
+							"InsertionPoint": ["getMovies", "author"],
+							"IsDeferred": true,
+							"Then": null
+						}
+					]
+				}
+			]
+		  }
+		],
+		"ScrubFields": {"getMovies#Movie": ["id"], "getMovies.author#Author": ["id"]}
+	  }`
+
+	assert.JSONEq(t, expected, actual)
+	assert.Equal(t, "author", plan.RootSteps[0].Then[0].DeferLabel(nil))
+	assert.True(t, plan.RootSteps[0].Then[0].IsDeferred(nil))
+}
+
+func TestPlanDeferredRootFragment(t *testing.T) {
+	query := `query ($deferred: Boolean!) { getMovies { id } ... @defer(if: $deferred) { getAuthors { id name } } }`
+
+	actual, plan := mustRunPlanner(t, seqPlan, incrementalSchema, query, simpleTum)
+
+	expected := `{
+		"RootSteps": [
+		  {
+			"URL": "0",
+			"ParentType": "Query",
+			"OperationName": null,
+			"SelectionSet": "{ getMovies { id } }",
+			"InsertionPoint": null,
+			"Then": null
+		  },
+		  {
+			"URL": "1",
+			"ParentType": "Query",
+			"OperationName": null,
+			"SelectionSet": "{ getAuthors { id name } }",
+			"InsertionPoint": null,
+			"IsDeferred": true,
+			"Then": null
+		  }
+		],
+		"ScrubFields": null
+	  }`
+
+	assert.JSONEq(t, expected, actual)
+	assert.True(t, plan.RootSteps[1].IsDeferred(map[string]interface{}{"deferred": true}))
+	assert.False(t, plan.RootSteps[1].IsDeferred(map[string]interface{}{"deferred": false}))
+}
+
+func TestPlanDisabledDeferredFragment(t *testing.T) {
+	query := `{ getMovies { ... @defer(if: false) { title } } }`
+
+	actual, _ := mustRunPlanner(t, seqPlan, incrementalSchema, query, simpleTum)
+
+	expected := `{
+		"RootSteps": [
+		  {
+			"URL": "0",
+			"ParentType": "Query",
+			"OperationName": null,
+			"SelectionSet": "{ getMovies { id title } }",
+			"InsertionPoint": null,
+			"Then": null
+		  }
+		],
+		"ScrubFields": {"getMovies#Movie": ["id"]}
+	  }`
+
+	assert.JSONEq(t, expected, actual)
+}
+
+func TestPlanStreamField(t *testing.T) {
+	query := `query ($count: Int) { getAuthors @stream(initialCount: $count, label: "authors") { movies @stream { title } } }`
+
+	_, plan := mustRunPlanner(t, seqPlan, incrementalSchema, query, simpleTum)
+
+	require.Len(t, plan.StreamFields, 2)
+	assert.Equal(t, []string{"getAuthors"}, plan.StreamFields[0].Path)
+	assert.Equal(t, []string{"getAuthors", "movies"}, plan.StreamFields[1].Path)
+
+	enabled, initialCount, label := plan.StreamFields[0].Arguments(map[string]interface{}{"count": float64(2)})
+	assert.True(t, enabled)
+	assert.Equal(t, 2, initialCount)
+	assert.Equal(t, "authors", label)
+
+	_, initialCount, _ = plan.StreamFields[1].Arguments(nil)
+	assert.Equal(t, 0, initialCount)
+
+	// directives aren't sent to services
+	assert.NotContains(t, plan.RootSteps[0].QueryString, "@stream")
+}
+
+func TestPlanStreamFieldTwice(t *testing.T) {
+	schema := gqlparser.MustLoadSchema(&ast.Source{Name: "fixture", Input: incrementalSchema})
+	query := gqlparser.MustLoadQuery(schema, `{ getAuthors { ...Movies } } fragment Movies on Author { movies @stream { title } }`)
+
+	// the same operation is planned again, f.e. after schema is reloaded
+	for i := 0; i < 2; i++ {
+		plan, err := seqPlan.Plan(&PlanningContext{
+			Operation:  query.Operations[0],
+			Schema:     schema,
+			TypeURLMap: simpleTum,
+		})
+		require.NoError(t, err)
+
+		require.Len(t, plan.StreamFields, 1)
+		assert.Equal(t, []string{"getAuthors", "movies"}, plan.StreamFields[0].Path)
+	}
+
+	// directive is removed only from planned copy of the operation
+	assert.NotNil(t, query.Fragments.ForName("Movies").SelectionSet[0].(*ast.Field).Directives.ForName(StreamDirectiveName))
+}
@@ -15,10 +15,19 @@ type Planner interface {
 	Plan(*PlanningContext) (*QueryPlan, error)
 }
 
+// ResettablePlanner is a planner which keeps plans made for specific schema.
+// Reset is called by gateway every time schema is reloaded.
+type ResettablePlanner interface {
+	Planner
+	Reset(*ast.Schema)
+}
+
 // QueryPlan is a query execution plan
 type QueryPlan struct {
 	RootSteps   []*QueryPlanStep
 	ScrubFields ScrubFields
+	// StreamFields contains list fields marked with @stream directive
+	StreamFields []*StreamField `json:",omitempty"`
 }
 
 func (qp *QueryPlan) SetComputedValues(ctx *PlanningContext) *QueryPlan {
@@ -37,11 +46,16 @@ type QueryPlanStep struct {
 	SelectionSet   ast.SelectionSet
 	InsertionPoint []string
 	Then           []*QueryPlanStep
+	// Defer is @defer directive of the fragment, which step was cut at. It's set for dependent steps as well
+	Defer *ast.Directive
 
 	// artifacts
 	QueryString     string
 	QueryStringHash [32]byte
 	VariablesList   []string
+	// NodesQueryString is the same query as QueryString, but it fetches multiple objects at once via nodes(ids: $ids).
+	// It's set only for non root steps
+	NodesQueryString string
 
 	// tools
 	formatter *format.BufferedFormatter
@@ -56,6 +70,7 @@ func (s *QueryPlanStep) MarshalJSON() ([]byte, error) {
 		OperationName  *string
 		InsertionPoint []string
 		Then           []*QueryPlanStep
+		IsDeferred     bool `json:",omitempty"`
 	}{
 		URL:            s.URL,
 		ParentType:     s.ParentType,
@@ -63,6 +78,7 @@ func (s *QueryPlanStep) MarshalJSON() ([]byte, error) {
 		OperationName:  s.OperationName,
 		InsertionPoint: s.InsertionPoint,
 		Then:           s.Then,
+		IsDeferred:     s.Defer != nil,
 	})
 }
 
@@ -103,6 +119,13 @@ func (s *QueryPlanStep) setQuery() *QueryPlanStep {
 	queryString := s.formatter.FormatSelectionSet(s.SelectionSet)
 	s.QueryString = queryString
 	s.QueryStringHash = sha256.Sum256([]byte(queryString))
+
+	if len(s.InsertionPoint) > 0 {
+		if nodesSelectionSet, ok := convertNodeQueryToNodesQuery(s.SelectionSet); ok {
+			s.NodesQueryString = s.formatter.FormatSelectionSet(nodesSelectionSet)
+		}
+	}
+
 	return s
 }
 
@@ -44,11 +44,19 @@ func sanitizeSelectionSet(ctx *PlanningContext, selectionSet ast.SelectionSet, i
 				scrubFields.Set(insertionPoint, s.TypeCondition, f)
 			}
 
-			switch s.ObjectDefinition.Kind {
-			case ast.Interface:
+			switch {
+			case deferDirective(s.Directives) != nil && s.ObjectDefinition.Kind == ast.Object:
+				// deferred fragment isn't unfolded, so the planner can cut it
+				inlineFragment := *s
+				if inlineFragment.TypeCondition == "" {
+					inlineFragment.TypeCondition = s.ObjectDefinition.Name
+				}
+				inlineFragment.SelectionSet = childSelectionSet
+				result = append(result, &inlineFragment)
+			case s.ObjectDefinition.Kind == ast.Interface:
 				childSelectionSet = sanitizeInterfaceInlineFragment(ctx, childSelectionSet, s)
 				result = addSelectionSetToSanitizedResult(result, childSelectionSet...)
-			case ast.Union:
+			case s.ObjectDefinition.Kind == ast.Union:
 				childSelectionSet = sanitizeUnionInlineFragment(ctx, childSelectionSet, s)
 				result = addSelectionSetToSanitizedResult(result, childSelectionSet...)
 			default:
@@ -69,13 +69,30 @@ func (sf ScrubFields) Clean(payload map[string]interface{}) {
 
 	for key, fields := range sf {
 		path := sf.unhash(key)
-		sf.clean(payload, path, fields)
+		sf.clean(payload, path, fields, false)
 	}
 
 	return
 }
 
-func (sf ScrubFields) clean(payload map[string]interface{}, path []string, fields map[string][]string) bool {
+// CleanAt cleans payload, which is a part of the result located at provided path, f.e. object of deferred fragment.
+// Path consists of field aliases without list indexes. Objects, which become empty, are kept, as later parts
+// of the result can be merged into them.
+func (sf ScrubFields) CleanAt(payload map[string]interface{}, path []string) {
+	if sf == nil {
+		return
+	}
+
+	for key, fields := range sf {
+		fieldsPath := sf.unhash(key)
+		if len(fieldsPath) < len(path) || !common.IsEqual(fieldsPath[:len(path)], path) {
+			continue
+		}
+		sf.clean(payload, fieldsPath[len(path):], fields, true)
+	}
+}
+
+func (sf ScrubFields) clean(payload map[string]interface{}, path []string, fields map[string][]string, keepEmpty bool) bool {
 	if len(path) == 0 {
 		for typename, fields := range fields {
 			if tn, ok := payload[common.TypenameFieldName]; ok && typename != tn {
@@ -99,11 +116,11 @@ func (sf ScrubFields) clean(payload map[string]interface{}, path []string, field
 
 	switch v := obj.(type) {
 	case map[string]interface{}:
-		removeParent = sf.clean(v, path[1:], fields)
+		removeParent = sf.clean(v, path[1:], fields, keepEmpty)
 	case []interface{}:
 		for _, x := range v {
 			if vv, ok := x.(map[string]interface{}); ok {
-				toCleanParent := sf.clean(vv, path[1:], fields)
+				toCleanParent := sf.clean(vv, path[1:], fields, keepEmpty)
 				removeParent = removeParent && toCleanParent
 			}
 		}
@@ -112,7 +129,7 @@ func (sf ScrubFields) clean(payload map[string]interface{}, path []string, field
 		}
 	case []map[string]interface{}:
 		for _, vv := range v {
-			toCleanParent := sf.clean(vv, path[1:], fields)
+			toCleanParent := sf.clean(vv, path[1:], fields, keepEmpty)
 			removeParent = removeParent && toCleanParent
 		}
 		if len(v) == 0 {
@@ -123,7 +140,7 @@ func (sf ScrubFields) clean(payload map[string]interface{}, path []string, field
 		removeParent = false
 	}
 
-	if removeParent {
+	if removeParent && !keepEmpty {
 		delete(payload, p)
 	}
 
@@ -266,3 +266,29 @@ func TestScrubFieldsNullResponse(t *testing.T) {
 
 	assert.JSONEq(t, expected, string(b))
 }
+
+func TestScrubFieldsCleanAt(t *testing.T) {
+	sf := make(ScrubFields)
+
+	sf.Set([]string{"a"}, "Test", "id")
+	sf.Set([]string{"a", "b"}, "Test", "id")
+	sf.Set([]string{"c"}, "Test", "id")
+
+	// object located at a
+	obj := map[string]interface{}{
+		"id":   "1",
+		"name": "a",
+		"b": []interface{}{
+			map[string]interface{}{"id": "2"},
+		},
+	}
+
+	sf.CleanAt(obj, []string{"a"})
+
+	// emptied objects are kept
+	expected := `{"name": "a", "b": [{}]}`
+
+	b, _ := json.Marshal(obj)
+
+	assert.JSONEq(t, expected, string(b))
+}
@@ -23,24 +23,100 @@ func (sp SequentialPlanner) Plan(ctx *PlanningContext) (*QueryPlan, error) {
 		parentType = common.SubscriptionObjectName
 	}
 
-	selSet, sf := sanitizeSelectionSet(ctx, ctx.Operation.SelectionSet, nil)
+	selSet, streamFields := extractStreamFields(ctx.Operation.SelectionSet)
+
+	selSet, sf := sanitizeSelectionSet(ctx, selSet, nil)
 	if len(sf) == 0 {
 		sf = nil
 	}
 
-	steps, err := createQueryPlanSteps(ctx, nil, parentType, "", selSet)
-	if err != nil {
-		return nil, err
+	var steps []*QueryPlanStep
+	if ctx.Operation.Operation == ast.Mutation {
+		// mutation fields must be executed serially, so each group of fields gets its own root steps
+		groups, err := splitMutationSelectionSet(ctx, selSet)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, group := range groups {
+			groupSteps, err := createQueryPlanSteps(ctx, nil, parentType, "", group)
+			if err != nil {
+				return nil, err
+			}
+			steps = append(steps, groupSteps...)
+		}
+	} else {
+		var deferredFragments []*ast.InlineFragment
+		if ctx.Operation.Operation == ast.Query {
+			selSet, deferredFragments = splitDeferredFragments(selSet)
+		}
+
+		var err error
+		steps, err = createQueryPlanSteps(ctx, nil, parentType, "", selSet)
+		if err != nil {
+			return nil, err
+		}
+
+		// deferred root fields get their own root steps, which are executed after the rest of the plan
+		for _, fragment := range deferredFragments {
+			deferredSteps, err := createQueryPlanSteps(ctx, nil, parentType, "", fragment.SelectionSet)
+			if err != nil {
+				return nil, err
+			}
+			markDeferred(deferredSteps, fragment.Directives.ForName(DeferDirectiveName))
+			steps = append(steps, deferredSteps...)
+		}
 	}
 
 	qp := &QueryPlan{
-		RootSteps:   steps,
-		ScrubFields: sf,
+		RootSteps:    steps,
+		ScrubFields:  sf,
+		StreamFields: streamFields,
 	}
 
 	return qp.SetComputedValues(ctx), nil
 }
 
+// splitMutationSelectionSet splits root mutation selection set into ordered groups.
+// Each group contains consecutive fields, which belong to the same service, so they can share one request.
+// Builtin fields like __typename have no side effects, so they don't break the current group,
+// they're resolved by their own group right after it. Each group is planned as single step, so order of steps is stable.
+func splitMutationSelectionSet(ctx *PlanningContext, selectionSet ast.SelectionSet) ([]ast.SelectionSet, error) {
+	var groups []ast.SelectionSet
+	var group, builtins ast.SelectionSet
+	var groupLocation string
+
+	flush := func() {
+		for _, ss := range []ast.SelectionSet{group, builtins} {
+			if len(ss) != 0 {
+				groups = append(groups, ss)
+			}
+		}
+		group, builtins = nil, nil
+	}
+
+	for _, field := range common.SelectionSetToFields(selectionSet, nil) {
+		if common.IsBuiltinName(field.Name) {
+			builtins = append(builtins, field)
+			continue
+		}
+
+		loc, err := ctx.GetURL(common.MutationObjectName, field.Name, common.InternalServiceName)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(group) == 0 || groupLocation != loc {
+			flush()
+		}
+		groupLocation = loc
+		group = append(group, field)
+	}
+	flush()
+
+	return groups, nil
+}
+
 func createQueryPlanSteps(ctx *PlanningContext, insertionPoint []string, parentType, parentLocation string, selectionSet ast.SelectionSet) ([]*QueryPlanStep, error) {
 	var result []*QueryPlanStep
 
@@ -165,6 +241,20 @@ func extractSelectionSet(ctx *PlanningContext, insertionPoint []string, parentTy
 				}
 			}
 		case *ast.InlineFragment:
+			// deferred fields of objects, which can be fetched with node query, are cut to separate steps
+			if deferDirective(selection.Directives) != nil {
+				if isImplementsNode, _ := ctx.TypeURLMap.GetTypeIsImplementsNode(selection.TypeCondition); isImplementsNode {
+					keys, deferredSteps, err := cutDeferredFragment(ctx, insertionPoint, parentType, location, selection)
+					if err != nil {
+						return nil, nil, err
+					}
+
+					selectionSetResult = append(selectionSetResult, keys...)
+					childrenStepsResult = append(childrenStepsResult, deferredSteps...)
+					continue
+				}
+			}
+
 			selectionSet, childrenSteps, err := extractSelectionSet(
 				ctx,
 				insertionPoint,
@@ -177,6 +267,7 @@ func extractSelectionSet(ctx *PlanningContext, insertionPoint []string, parentTy
 			}
 
 			inlineFragment := *selection
+			inlineFragment.Directives = removeIncrementalDirectives(selection.Directives)
 			inlineFragment.SelectionSet = selectionSet
 			selectionSetResult = append(selectionSetResult, &inlineFragment)
 			childrenStepsResult = append(childrenStepsResult, childrenSteps...)
@@ -494,6 +585,51 @@ func convertSelectionSetToNodeQuery(parentType string, selectionSet ast.Selectio
 	}
 }
 
+// convertNodeQueryToNodesQuery converts node query to the query, which fetches multiple objects at once
+//
+//	{
+//		 	nodes(ids: $ids) {
+//		 		... on parentType {
+//		 			selectionSet
+//		 		}
+//		 	}
+//	}
+func convertNodeQueryToNodesQuery(nodeQuery ast.SelectionSet) (ast.SelectionSet, bool) {
+	if len(nodeQuery) != 1 {
+		return nil, false
+	}
+
+	nodeField, ok := nodeQuery[0].(*ast.Field)
+	if !ok || nodeField.Name != common.NodeFieldName {
+		return nil, false
+	}
+
+	return ast.SelectionSet{
+		&ast.Field{
+			Name: common.NodesFieldName,
+			Arguments: ast.ArgumentList{
+				&ast.Argument{
+					Name: common.IDsFieldName,
+					Value: &ast.Value{
+						Kind: ast.Variable,
+						Raw:  common.IDsFieldName,
+					},
+				},
+			},
+			Definition: &ast.FieldDefinition{
+				Name: common.NodesFieldName,
+				Arguments: ast.ArgumentDefinitionList{
+					&ast.ArgumentDefinition{
+						Name: common.IDsFieldName,
+						Type: ast.NonNullListType(ast.NonNullNamedType("ID", nil), nil),
+					},
+				},
+			},
+			SelectionSet: nodeField.SelectionSet,
+		},
+	}, true
+}
+
 // addFieldToNodeQuery adds provided selection to node query
 //
 //	{
@@ -5,8 +5,13 @@ import (
 	"testing"
 
 	"github.com/buildbuildio/pebbles/common"
+	"github.com/buildbuildio/pebbles/format"
+	"github.com/buildbuildio/pebbles/merger"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
 )
 
 var seqPlan SequentialPlanner
@@ -187,6 +192,81 @@ func TestSimpleMutation(t *testing.T) {
 	assert.JSONEq(t, expected, actual)
 }
 
+func TestMutationSerialSteps(t *testing.T) {
+	schema := gqlparser.MustLoadSchema(&ast.Source{Name: "fixture", Input: `
+		type Order {
+			id: ID!
+		}
+
+		type Query {
+			orders: [Order!]!
+		}
+
+		type Mutation {
+			createOrder: Order!
+			cancelOrder: Order!
+			chargeCard: Boolean!
+		}
+	`})
+
+	tum := merger.TypeURLMap{
+		"Query": {
+			Fields: map[string]string{
+				"orders": "0",
+			},
+		},
+		"Mutation": {
+			Fields: map[string]string{
+				"createOrder": "0",
+				"cancelOrder": "0",
+				"chargeCard":  "1",
+			},
+		},
+		"Order": {
+			Fields: map[string]string{},
+		},
+	}
+
+	formatSelectionSet := format.NewDebugBufferedFormatter().FormatSelectionSet
+
+	query := `mutation { a: createOrder { id } b: cancelOrder { id } __typename c: chargeCard d: createOrder { id } }`
+	operation := gqlparser.MustLoadQuery(schema, query)
+
+	plan, err := seqPlan.Plan(&PlanningContext{
+		Operation:  operation.Operations[0],
+		Schema:     schema,
+		TypeURLMap: tum,
+	})
+	require.NoError(t, err)
+	require.Len(t, plan.RootSteps, 4)
+
+	// order of steps must be the same as order of fields in the document
+	assert.Equal(t, "0", plan.RootSteps[0].URL)
+	assert.Equal(t, "{ a: createOrder { id } b: cancelOrder { id } }", formatSelectionSet(plan.RootSteps[0].SelectionSet))
+	assert.Equal(t, common.InternalServiceName, plan.RootSteps[1].URL)
+	assert.Equal(t, "1", plan.RootSteps[2].URL)
+	assert.Equal(t, "{ c: chargeCard }", formatSelectionSet(plan.RootSteps[2].SelectionSet))
+	assert.Equal(t, "0", plan.RootSteps[3].URL)
+	assert.Equal(t, "{ d: createOrder { id } }", formatSelectionSet(plan.RootSteps[3].SelectionSet))
+}
+
+func TestPlanNodesQueryString(t *testing.T) {
+	query := `query ($language: Language) { getAuthors { id name movies { id title(language: $language) } } }`
+
+	_, plan := mustRunPlanner(t, seqPlan, simpleSchema, query, simpleTum)
+
+	require.Len(t, plan.RootSteps, 1)
+	assert.Empty(t, plan.RootSteps[0].NodesQueryString)
+
+	require.Len(t, plan.RootSteps[0].Then, 1)
+	step := plan.RootSteps[0].Then[0]
+	assert.Equal(
+		t,
+		"query ($ids: [ID!]!, $language: Language) {\n\tnodes(ids: $ids) {\n\t\t... on Author {\n\t\t\tmovies {\n\t\t\t\tid\n\t\t\t\ttitle(language: $language)\n\t\t\t}\n\t\t}\n\t}\n}",
+		step.NodesQueryString,
+	)
+}
+
 func TestSimpleSubscription(t *testing.T) {
 	query := `subscription { authorAdded {id name movies { id title(language: French) }}}`
 
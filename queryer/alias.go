@@ -0,0 +1,292 @@
+package queryer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/buildbuildio/pebbles/requests"
+
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/formatter"
+	"github.com/vektah/gqlparser/v2/parser"
+)
+
+// BatchMode defines how batch of requests is sent to remote service
+type BatchMode int
+
+const (
+	// BatchModeArray sends requests as JSON array in single http request
+	BatchModeArray BatchMode = iota
+	// BatchModeAlias merges requests into single document, prefixing root fields of each request with alias,
+	// f.e. { _0_node: node(id: $id_0) { ... } _1_node: node(id: $id_1) { ... } }.
+	// It's meant for services, which don't support array batching.
+	BatchModeAlias
+)
+
+// aliasedBatch is a single document, which contains merged requests of the same operation type
+type aliasedBatch struct {
+	operation ast.Operation
+	// indexes of merged requests in the inputs
+	indexes   []int
+	document  *ast.QueryDocument
+	variables map[string]interface{}
+}
+
+// fetchAliased merges inputs into documents with aliased root fields and splits responses back
+func (q *MultiOpQueryer) fetchAliased(inputs []*requests.Request) ([]requests.Response, error) {
+	batches, err := mergeRequests(inputs)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]requests.Response, len(inputs))
+
+	// batches are fetched one by one, as mutations must be executed in provided order
+	for _, batch := range batches {
+		var buf bytes.Buffer
+		formatter.NewFormatter(&buf).FormatQueryDocument(batch.document)
+
+		payload, err := json.Marshal(&requests.Request{
+			Query:     buf.String(),
+			Variables: batch.variables,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		response, err := q.sendQueryRequest(payload, len(batch.indexes))
+		if err != nil {
+			return nil, err
+		}
+
+		var resp requests.Response
+		if err := json.Unmarshal(response, &resp); err != nil {
+			return nil, err
+		}
+
+		splitAliasedResponse(batch, &resp, results)
+	}
+
+	return results, nil
+}
+
+// mergeRequests merges requests into documents, one for each consecutive group of requests with the same operation type
+func mergeRequests(inputs []*requests.Request) ([]*aliasedBatch, error) {
+	var batches []*aliasedBatch
+
+	for i, input := range inputs {
+		doc, gqlErr := parser.ParseQuery(&ast.Source{Input: input.Query})
+		if gqlErr != nil {
+			return nil, gqlErr
+		}
+
+		var operation *ast.OperationDefinition
+		if input.OperationName != nil {
+			operation = doc.Operations.ForName(*input.OperationName)
+		} else if len(doc.Operations) == 1 {
+			operation = doc.Operations[0]
+		}
+
+		if operation == nil {
+			return nil, errors.New("unable to determine operation to merge")
+		}
+
+		if len(batches) == 0 || batches[len(batches)-1].operation != operation.Operation {
+			batches = append(batches, &aliasedBatch{
+				operation: operation.Operation,
+				document: &ast.QueryDocument{
+					Operations: ast.OperationList{{Operation: operation.Operation}},
+				},
+				variables: make(map[string]interface{}),
+			})
+		}
+
+		batches[len(batches)-1].add(i, doc, operation, input.Variables)
+	}
+
+	return batches, nil
+}
+
+// add merges operation into the batch, renaming its root fields and variables.
+// Fragments are inlined, so there are no collisions between fragments of different requests.
+func (b *aliasedBatch) add(index int, doc *ast.QueryDocument, operation *ast.OperationDefinition, variables map[string]interface{}) {
+	suffix := "_" + strconv.Itoa(index)
+	merged := b.document.Operations[0]
+
+	for _, vd := range operation.VariableDefinitions {
+		if value, ok := variables[vd.Variable]; ok {
+			b.variables[vd.Variable+suffix] = value
+		}
+		vd.Variable += suffix
+		merged.VariableDefinitions = append(merged.VariableDefinitions, vd)
+	}
+
+	renameVariables(operation.SelectionSet, suffix)
+	for _, fragment := range doc.Fragments {
+		renameVariables(fragment.SelectionSet, suffix)
+	}
+
+	selectionSet := inlineFragments(operation.SelectionSet, doc.Fragments)
+	merged.SelectionSet = append(merged.SelectionSet, aliasRootSelectionSet(selectionSet, index)...)
+
+	b.indexes = append(b.indexes, index)
+}
+
+// aliasPrefix returns prefix for root fields of request with provided index
+func aliasPrefix(index int) string {
+	return fmt.Sprintf("_%d_", index)
+}
+
+// parseAlias returns index of request and original alias of the root field
+func parseAlias(alias string) (int, string, bool) {
+	if !strings.HasPrefix(alias, "_") {
+		return 0, "", false
+	}
+
+	end := strings.IndexByte(alias[1:], '_')
+	if end < 0 {
+		return 0, "", false
+	}
+
+	index, err := strconv.Atoi(alias[1 : end+1])
+	if err != nil {
+		return 0, "", false
+	}
+
+	return index, alias[end+2:], true
+}
+
+// aliasRootSelectionSet prefixes aliases of root fields
+func aliasRootSelectionSet(selectionSet ast.SelectionSet, index int) ast.SelectionSet {
+	var result ast.SelectionSet
+
+	for _, selection := range selectionSet {
+		switch selection := selection.(type) {
+		case *ast.Field:
+			cpy := *selection
+			if cpy.Alias == "" {
+				cpy.Alias = cpy.Name
+			}
+			cpy.Alias = aliasPrefix(index) + cpy.Alias
+			result = append(result, &cpy)
+		case *ast.InlineFragment:
+			cpy := *selection
+			cpy.SelectionSet = aliasRootSelectionSet(selection.SelectionSet, index)
+			result = append(result, &cpy)
+		}
+	}
+
+	return result
+}
+
+// inlineFragments replaces fragment spreads with inline fragments
+func inlineFragments(selectionSet ast.SelectionSet, fragments ast.FragmentDefinitionList) ast.SelectionSet {
+	var result ast.SelectionSet
+
+	for _, selection := range selectionSet {
+		switch selection := selection.(type) {
+		case *ast.Field:
+			cpy := *selection
+			cpy.SelectionSet = inlineFragments(selection.SelectionSet, fragments)
+			result = append(result, &cpy)
+		case *ast.InlineFragment:
+			cpy := *selection
+			cpy.SelectionSet = inlineFragments(selection.SelectionSet, fragments)
+			result = append(result, &cpy)
+		case *ast.FragmentSpread:
+			fragment := fragments.ForName(selection.Name)
+			if fragment == nil {
+				continue
+			}
+			result = append(result, &ast.InlineFragment{
+				TypeCondition: fragment.TypeCondition,
+				Directives:    selection.Directives,
+				SelectionSet:  inlineFragments(fragment.SelectionSet, fragments),
+			})
+		}
+	}
+
+	return result
+}
+
+// renameVariables adds suffix to all used variables
+func renameVariables(selectionSet ast.SelectionSet, suffix string) {
+	for _, selection := range selectionSet {
+		switch selection := selection.(type) {
+		case *ast.Field:
+			renameArguments(selection.Arguments, suffix)
+			renameDirectives(selection.Directives, suffix)
+			renameVariables(selection.SelectionSet, suffix)
+		case *ast.InlineFragment:
+			renameDirectives(selection.Directives, suffix)
+			renameVariables(selection.SelectionSet, suffix)
+		case *ast.FragmentSpread:
+			renameDirectives(selection.Directives, suffix)
+		}
+	}
+}
+
+func renameDirectives(directives ast.DirectiveList, suffix string) {
+	for _, d := range directives {
+		renameArguments(d.Arguments, suffix)
+	}
+}
+
+func renameArguments(arguments ast.ArgumentList, suffix string) {
+	for _, a := range arguments {
+		renameValue(a.Value, suffix)
+	}
+}
+
+func renameValue(value *ast.Value, suffix string) {
+	if value == nil {
+		return
+	}
+
+	if value.Kind == ast.Variable {
+		value.Raw += suffix
+	}
+
+	for _, ch := range value.Children {
+		renameValue(ch.Value, suffix)
+	}
+}
+
+// splitAliasedResponse splits response of merged document into responses of each merged request.
+// Errors, which can't be related to specific request, are added to all of them.
+func splitAliasedResponse(batch *aliasedBatch, resp *requests.Response, results []requests.Response) {
+	if resp.Data != nil {
+		for _, index := range batch.indexes {
+			results[index].Data = make(map[string]interface{})
+		}
+
+		for key, value := range resp.Data {
+			index, alias, ok := parseAlias(key)
+			if !ok || index >= len(results) || results[index].Data == nil {
+				continue
+			}
+			results[index].Data[alias] = value
+		}
+	}
+
+	for _, e := range resp.Errors {
+		if len(e.Path) > 0 {
+			if key, ok := e.Path[0].(string); ok {
+				if index, alias, ok := parseAlias(key); ok && index < len(results) {
+					cpy := *e
+					cpy.Path = append([]interface{}{alias}, e.Path[1:]...)
+					results[index].Errors = append(results[index].Errors, &cpy)
+					continue
+				}
+			}
+		}
+
+		for _, index := range batch.indexes {
+			results[index].Errors = append(results[index].Errors, e)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package queryer
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/buildbuildio/pebbles/requests"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/formatter"
+)
+
+func TestParseAlias(t *testing.T) {
+	for _, tc := range []struct {
+		Alias    string
+		Index    int
+		Original string
+		Ok       bool
+	}{
+		{Alias: "_0_node", Index: 0, Original: "node", Ok: true},
+		{Alias: "_12___typename", Index: 12, Original: "__typename", Ok: true},
+		{Alias: "_1_my_field", Index: 1, Original: "my_field", Ok: true},
+		{Alias: "node", Ok: false},
+		{Alias: "_node", Ok: false},
+		{Alias: "_a_node", Ok: false},
+	} {
+		t.Run(tc.Alias, func(t *testing.T) {
+			index, original, ok := parseAlias(tc.Alias)
+			assert.Equal(t, tc.Ok, ok)
+			if tc.Ok {
+				assert.Equal(t, tc.Index, index)
+				assert.Equal(t, tc.Original, original)
+			}
+		})
+	}
+}
+
+func TestMergeRequests(t *testing.T) {
+	operationName := "Second"
+	batches, err := mergeRequests([]*requests.Request{
+		{
+			Query:     `query ($id: ID!) { node(id: $id) { ... on User { name } } }`,
+			Variables: map[string]interface{}{"id": "1"},
+		},
+		{
+			Query:         `query First { other } query Second($id: ID!, $size: Int) { node(id: $id) { ...UserFragment } } fragment UserFragment on User { avatar(size: $size) }`,
+			Variables:     map[string]interface{}{"id": "2", "size": 10},
+			OperationName: &operationName,
+		},
+		{
+			Query: `mutation { a: save { id } }`,
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, batches, 2)
+
+	assert.Equal(t, []int{0, 1}, batches[0].indexes)
+	assert.Equal(t, map[string]interface{}{"id_0": "1", "id_1": "2", "size_1": 10}, batches[0].variables)
+
+	var buf bytes.Buffer
+	formatter.NewFormatter(&buf).FormatQueryDocument(batches[0].document)
+	assert.Equal(
+		t,
+		`query ($id_0: ID!, $id_1: ID!, $size_1: Int) { _0_node: node(id: $id_0) { ... on User { name } } _1_node: node(id: $id_1) { ... on User { avatar(size: $size_1) } } }`,
+		compact(buf.String()),
+	)
+
+	assert.Equal(t, []int{2}, batches[1].indexes)
+	buf.Reset()
+	formatter.NewFormatter(&buf).FormatQueryDocument(batches[1].document)
+	assert.Equal(t, `mutation { _2_a: save { id } }`, compact(buf.String()))
+}
+
+func TestMultiOpQueryerAliasBatchMode(t *testing.T) {
+	queryer := NewMultiOpQueryer("foo", 10).WithBatchMode(BatchModeAlias)
+
+	queryer.WithHTTPClient(&http.Client{
+		Transport: roundTripFunc(func(req *http.Request) *http.Response {
+			defer req.Body.Close()
+
+			var input map[string]interface{}
+			require.NoError(t, json.NewDecoder(req.Body).Decode(&input))
+
+			// single document is sent instead of array
+			assert.Equal(t, "query ($id_0: ID!, $id_1: ID!) { _0_node: node(id: $id_0) { name } _1_node: node(id: $id_1) { name } }", compact(input["query"].(string)))
+			assert.Equal(t, map[string]interface{}{"id_0": "1", "id_1": "2"}, input["variables"])
+
+			return &http.Response{
+				StatusCode: 200,
+				Body: ioutil.NopCloser(bytes.NewBufferString(`{
+					"data": {"_0_node": {"name": "first"}, "_1_node": null},
+					"errors": [{"message": "not found", "path": ["_1_node"]}]
+				}`)),
+				Header: make(http.Header),
+			}
+		}),
+	})
+
+	query := `query ($id: ID!) { node(id: $id) { name } }`
+	res, err := queryer.Query([]*requests.Request{
+		{Query: query, Variables: map[string]interface{}{"id": "1"}},
+		{Query: query, Variables: map[string]interface{}{"id": "2"}},
+	})
+
+	assert.Equal(t, []map[string]interface{}{
+		{"node": map[string]interface{}{"name": "first"}},
+		{"node": nil},
+	}, res)
+
+	require.Error(t, err)
+	respErrs, ok := err.(ResponseErrors)
+	require.True(t, ok)
+	require.Len(t, respErrs, 2)
+	assert.Empty(t, respErrs[0])
+	require.Len(t, respErrs[1], 1)
+	assert.Equal(t, []interface{}{"node"}, respErrs[1][0].Path)
+}
+
+// compact removes new lines and indentation from formatted query
+func compact(query string) string {
+	return strings.Join(strings.Fields(query), " ")
+}
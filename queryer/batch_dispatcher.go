@@ -0,0 +1,235 @@
+package queryer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/buildbuildio/pebbles/requests"
+)
+
+// BatchDispatcher collects queries sent by different queryers to the same service within window and sends them
+// in single batch. Only queries of queryers with the same batch headers are batched together, so credentials of one client
+// are never used for queries of another. Dispatcher is safe for concurrent use by many queryers.
+type BatchDispatcher struct {
+	window       time.Duration
+	maxBatchSize int
+
+	mu      sync.Mutex
+	batches map[string]*pendingBatch
+}
+
+type pendingBatch struct {
+	calls []*batchCall
+	size  int
+	timer *time.Timer
+
+	isSent bool
+	// cancel aborts sent batch, when all its callers are gone
+	cancel context.CancelFunc
+	active int
+}
+
+type batchCall struct {
+	// queryer of the first remaining call sends the batch, its context provides tracing span and metrics
+	queryer *MultiOpQueryer
+	inputs  []*requests.Request
+	// done is closed once response is received
+	done chan struct{}
+	resp []map[string]interface{}
+	err  error
+}
+
+// NewBatchDispatcher returns dispatcher, which waits for window before sending batch.
+// Batch is sent earlier, when it reaches maxBatchSize operations, zero means no limit.
+func NewBatchDispatcher(window time.Duration, maxBatchSize int) *BatchDispatcher {
+	return &BatchDispatcher{
+		window:       window,
+		maxBatchSize: maxBatchSize,
+		batches:      make(map[string]*pendingBatch),
+	}
+}
+
+// WithBatchDispatcher sets dispatcher, which batches queries with other queryers, by default only inputs of single Query call are batched.
+// headers are the ones queryer forwards from the client request, queries are batched only with queryers forwarding the same headers.
+// Batch is sent with middlewares and context of one of its queryers, so middlewares must not depend on the client request
+// other than by forwarded headers, f.e. they must not read credentials from the context.
+func (q *MultiOpQueryer) WithBatchDispatcher(d *BatchDispatcher, headers http.Header) *MultiOpQueryer {
+	q.batchDispatcher = d
+	q.batchHeaders = headers
+	return q
+}
+
+// batchKey identifies batch by service and headers forwarded by the queryer
+func batchKey(q *MultiOpQueryer) (string, error) {
+	// maps are marshalled with sorted keys, so the same headers give the same key
+	b, err := json.Marshal([]interface{}{q.url, q.batchMode, q.batchHeaders})
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
+
+// query adds inputs to pending batch and waits for their results. If context of the queryer is done,
+// it returns immediately, inputs are removed from the batch unless it's already sent.
+func (d *BatchDispatcher) query(q *MultiOpQueryer, inputs []*requests.Request) ([]map[string]interface{}, error) {
+	key, err := batchKey(q)
+	if err != nil {
+		return nil, err
+	}
+
+	call := &batchCall{queryer: q, inputs: inputs, done: make(chan struct{})}
+
+	d.mu.Lock()
+	b, ok := d.batches[key]
+	if !ok {
+		b = &pendingBatch{}
+		d.batches[key] = b
+		b.timer = time.AfterFunc(d.window, func() {
+			d.flush(key, b)
+		})
+	}
+	b.calls = append(b.calls, call)
+	b.size += len(inputs)
+
+	// batch is sent by its timer, if it has fired already
+	isFull := d.maxBatchSize > 0 && b.size >= d.maxBatchSize && b.timer.Stop()
+	if isFull {
+		delete(d.batches, key)
+	}
+	d.mu.Unlock()
+
+	if isFull {
+		go d.send(b)
+	}
+
+	ctx := q.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	select {
+	case <-call.done:
+		return call.resp, call.err
+	case <-ctx.Done():
+		d.leave(key, b, call)
+		return nil, ctx.Err()
+	}
+}
+
+// flush sends batch after window passes
+func (d *BatchDispatcher) flush(key string, b *pendingBatch) {
+	d.mu.Lock()
+	if d.batches[key] == b {
+		delete(d.batches, key)
+	}
+	d.mu.Unlock()
+
+	d.send(b)
+}
+
+// leave removes call of the caller, which is gone, from the batch
+func (d *BatchDispatcher) leave(key string, b *pendingBatch, call *batchCall) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if b.isSent {
+		b.active--
+		if b.active == 0 && b.cancel != nil {
+			b.cancel()
+		}
+		return
+	}
+
+	for i, c := range b.calls {
+		if c == call {
+			b.calls = append(b.calls[:i], b.calls[i+1:]...)
+			b.size -= len(call.inputs)
+			break
+		}
+	}
+
+	if len(b.calls) == 0 {
+		b.timer.Stop()
+		if d.batches[key] == b {
+			delete(d.batches, key)
+		}
+	}
+}
+
+// send executes inputs of all calls as single batch and distributes results between calls. Batch is sent only once.
+func (d *BatchDispatcher) send(b *pendingBatch) {
+	d.mu.Lock()
+	if b.isSent {
+		d.mu.Unlock()
+		return
+	}
+	b.isSent = true
+	calls := b.calls
+	if len(calls) == 0 {
+		d.mu.Unlock()
+		return
+	}
+	// callers, which have left before the batch is sent, are already removed from it
+	q := calls[0].queryer
+
+	parent := q.ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+	// batch isn't cancelled along with the sending caller, as other callers still wait for it
+	ctx, cancel := context.WithCancel(detachedContext{parent})
+	defer cancel()
+
+	b.cancel = cancel
+	b.active = len(calls)
+	d.mu.Unlock()
+
+	var inputs []*requests.Request
+	for _, call := range calls {
+		inputs = append(inputs, call.inputs...)
+	}
+
+	sender := *q
+	sender.ctx = ctx
+	resps, err := sender.query(inputs)
+
+	var respErrs ResponseErrors
+	// remote service responded with errors for some requests, they're split between callers as data
+	if err != nil && errors.As(err, &respErrs) && len(respErrs) == len(inputs) {
+		err = nil
+	}
+	if err == nil && len(resps) != len(inputs) {
+		err = errors.New("number of responses doesn't match number of requests")
+	}
+
+	var offset int
+	for _, call := range calls {
+		if err != nil {
+			call.err = err
+		} else {
+			call.resp = resps[offset : offset+len(call.inputs)]
+			if respErrs != nil {
+				call.err = respErrs[offset : offset+len(call.inputs)].orNil()
+			}
+		}
+		offset += len(call.inputs)
+
+		close(call.done)
+	}
+}
+
+// detachedContext keeps values of the parent, f.e. tracing span and metrics, but isn't cancelled with it
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+
+func (detachedContext) Done() <-chan struct{} { return nil }
+
+func (detachedContext) Err() error { return nil }
@@ -0,0 +1,248 @@
+package queryer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/buildbuildio/pebbles/requests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// batchRecorder is a service, which echoes query and authorization header of each operation and records received batches
+type batchRecorder struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	batches [][]string
+}
+
+func newBatchRecorder(t *testing.T) *batchRecorder {
+	br := &batchRecorder{}
+	br.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var inputs []*requests.Request
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&inputs))
+
+		var queries []string
+		resps := make([]map[string]interface{}, len(inputs))
+		for i, input := range inputs {
+			queries = append(queries, input.Query)
+			resps[i] = map[string]interface{}{"data": map[string]interface{}{
+				"query": input.Query,
+				"token": r.Header.Get("Authorization"),
+			}}
+		}
+
+		br.mu.Lock()
+		br.batches = append(br.batches, queries)
+		br.mu.Unlock()
+
+		json.NewEncoder(w).Encode(resps)
+	}))
+	return br
+}
+
+func newDispatchedQueryer(url, token string, d *BatchDispatcher) *MultiOpQueryer {
+	headers := http.Header{"Authorization": []string{token}}
+	return NewMultiOpQueryer(url, 100).WithBatchDispatcher(d, headers).WithMiddlewares([]RequestMiddleware{
+		HeadersMiddleware(headers),
+	})
+}
+
+// queryConcurrently sends single query with each queryer at once
+func queryConcurrently(queryers []*MultiOpQueryer, queries []string) ([][]map[string]interface{}, []error) {
+	res := make([][]map[string]interface{}, len(queryers))
+	errs := make([]error, len(queryers))
+
+	var wg sync.WaitGroup
+	for i, q := range queryers {
+		wg.Add(1)
+		go func(i int, q *MultiOpQueryer) {
+			defer wg.Done()
+			res[i], errs[i] = q.Query([]*requests.Request{{Query: queries[i]}})
+		}(i, q)
+	}
+	wg.Wait()
+
+	return res, errs
+}
+
+func TestBatchDispatcher(t *testing.T) {
+	s := newBatchRecorder(t)
+	defer s.Close()
+
+	d := NewBatchDispatcher(50*time.Millisecond, 0)
+	queryers := []*MultiOpQueryer{
+		newDispatchedQueryer(s.URL, "a", d),
+		newDispatchedQueryer(s.URL, "a", d),
+		newDispatchedQueryer(s.URL, "b", d),
+	}
+
+	res, errs := queryConcurrently(queryers, []string{"{ a1 }", "{ a2 }", "{ b }"})
+	for i := range queryers {
+		require.NoError(t, errs[i])
+	}
+
+	// each caller receives its own result
+	assert.Equal(t, []map[string]interface{}{{"query": "{ a1 }", "token": "a"}}, res[0])
+	assert.Equal(t, []map[string]interface{}{{"query": "{ a2 }", "token": "a"}}, res[1])
+	assert.Equal(t, []map[string]interface{}{{"query": "{ b }", "token": "b"}}, res[2])
+
+	// queries with different headers aren't batched together
+	assert.Len(t, s.batches, 2)
+	assert.ElementsMatch(t, []string{"{ a1 }", "{ a2 }", "{ b }"}, append(append([]string{}, s.batches[0]...), s.batches[1]...))
+}
+
+func TestBatchDispatcherMaxBatchSize(t *testing.T) {
+	s := newBatchRecorder(t)
+	defer s.Close()
+
+	// batch is sent once it's full, without waiting for the window
+	d := NewBatchDispatcher(time.Hour, 2)
+	queryers := []*MultiOpQueryer{newDispatchedQueryer(s.URL, "a", d), newDispatchedQueryer(s.URL, "a", d)}
+
+	_, errs := queryConcurrently(queryers, []string{"{ a1 }", "{ a2 }"})
+	require.NoError(t, errs[0])
+	require.NoError(t, errs[1])
+
+	require.Len(t, s.batches, 1)
+	assert.ElementsMatch(t, []string{"{ a1 }", "{ a2 }"}, s.batches[0])
+}
+
+func TestBatchDispatcherCancel(t *testing.T) {
+	s := newBatchRecorder(t)
+	defer s.Close()
+
+	d := NewBatchDispatcher(50*time.Millisecond, 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled := newDispatchedQueryer(s.URL, "a", d).WithContext(ctx)
+
+	errCh := make(chan error)
+	go func() {
+		_, err := cancelled.Query([]*requests.Request{{Query: "{ cancelled }"}})
+		errCh <- err
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	assert.ErrorIs(t, <-errCh, context.Canceled)
+
+	res, err := newDispatchedQueryer(s.URL, "a", d).Query([]*requests.Request{{Query: "{ a }"}})
+	require.NoError(t, err)
+	assert.Equal(t, []map[string]interface{}{{"query": "{ a }", "token": "a"}}, res)
+
+	// query of the cancelled caller isn't sent
+	assert.Equal(t, [][]string{{"{ a }"}}, s.batches)
+}
+
+func TestBatchDispatcherFirstCallerLeaves(t *testing.T) {
+	s := newBatchRecorder(t)
+	defer s.Close()
+
+	d := NewBatchDispatcher(50*time.Millisecond, 0)
+
+	// middleware of the first caller sets another token, it mustn't be used once the caller is gone
+	ctx, cancel := context.WithCancel(context.Background())
+	first := NewMultiOpQueryer(s.URL, 100).WithBatchDispatcher(
+		d, http.Header{"Authorization": []string{"a"}},
+	).WithMiddlewares([]RequestMiddleware{
+		HeadersMiddleware(http.Header{"Authorization": []string{"first"}}),
+	}).WithContext(ctx)
+
+	errCh := make(chan error)
+	go func() {
+		_, err := first.Query([]*requests.Request{{Query: "{ first }"}})
+		errCh <- err
+	}()
+
+	type result struct {
+		res []map[string]interface{}
+		err error
+	}
+	resCh := make(chan result)
+	time.Sleep(10 * time.Millisecond)
+	go func() {
+		res, err := newDispatchedQueryer(s.URL, "a", d).Query([]*requests.Request{{Query: "{ a }"}})
+		resCh <- result{res, err}
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	assert.ErrorIs(t, <-errCh, context.Canceled)
+
+	res := <-resCh
+	require.NoError(t, res.err)
+	assert.Equal(t, []map[string]interface{}{{"query": "{ a }", "token": "a"}}, res.res)
+	assert.Equal(t, [][]string{{"{ a }"}}, s.batches)
+}
+
+func TestBatchDispatcherResponseErrors(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"data": {"a": 1}}, {"data": null, "errors": [{"message": "failed"}]}]`))
+	}))
+	defer s.Close()
+
+	d := NewBatchDispatcher(50*time.Millisecond, 2)
+	queryers := []*MultiOpQueryer{newDispatchedQueryer(s.URL, "a", d), newDispatchedQueryer(s.URL, "a", d)}
+
+	res, errs := queryConcurrently(queryers, []string{"{ a }", "{ b }"})
+
+	// errors belong only to the operation, which caused them
+	var succeeded, failed int
+	for i := range queryers {
+		if errs[i] == nil {
+			succeeded++
+			assert.Equal(t, []map[string]interface{}{{"a": float64(1)}}, res[i])
+			continue
+		}
+
+		failed++
+		assert.EqualError(t, errs[i], "failed")
+		assert.IsType(t, ResponseErrors{}, errs[i])
+	}
+	assert.Equal(t, 1, succeeded)
+	assert.Equal(t, 1, failed)
+}
+
+func TestBatchDispatcherSendsBatchOnce(t *testing.T) {
+	var operations int32
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) *http.Response {
+		var inputs []*requests.Request
+		require.NoError(t, json.NewDecoder(req.Body).Decode(&inputs))
+		atomic.AddInt32(&operations, int32(len(inputs)))
+
+		resps := make([]map[string]interface{}, len(inputs))
+		for i := range resps {
+			resps[i] = map[string]interface{}{"data": map[string]interface{}{"test": "YES"}}
+		}
+		body, _ := json.Marshal(resps)
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBuffer(body)), Header: make(http.Header)}
+	})}
+
+	// timer fires while batch is being filled up, so both of them try to send it
+	d := NewBatchDispatcher(time.Nanosecond, 1)
+
+	const queries = 2000
+	var wg sync.WaitGroup
+	for i := 0; i < queries; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q := NewMultiOpQueryer("foo", 100).WithHTTPClient(client).WithBatchDispatcher(d, nil)
+			_, err := q.Query([]*requests.Request{{Query: "{ test }"}})
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(queries), atomic.LoadInt32(&operations))
+}
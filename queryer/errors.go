@@ -0,0 +1,46 @@
+package queryer
+
+import (
+	"strings"
+
+	"github.com/buildbuildio/pebbles/gqlerrors"
+)
+
+// ResponseErrors contains errors returned by remote service for each request of the batch.
+// It's aligned with inputs passed to Query, requests without errors have nil value.
+// When Query returns ResponseErrors, it still returns data for each request, which could be partial or nil.
+type ResponseErrors []gqlerrors.ErrorList
+
+// Error returns a string representation of each error
+func (re ResponseErrors) Error() string {
+	var acc []string
+
+	for _, errs := range re {
+		if len(errs) == 0 {
+			continue
+		}
+		acc = append(acc, errs.Error())
+	}
+
+	return strings.Join(acc, ". ")
+}
+
+// IsEmpty returns true if there are no errors for all requests
+func (re ResponseErrors) IsEmpty() bool {
+	for _, errs := range re {
+		if len(errs) != 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// orNil returns nil error if there are no errors, so it could be safely returned as error
+func (re ResponseErrors) orNil() error {
+	if re.IsEmpty() {
+		return nil
+	}
+
+	return re
+}
@@ -2,17 +2,21 @@ package queryer
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"io/ioutil"
 	"net/http"
 	"strconv"
+	"time"
 
+	"github.com/buildbuildio/pebbles/metrics"
 	"github.com/buildbuildio/pebbles/requests"
+	"github.com/buildbuildio/pebbles/tracing"
 )
 
 // SendQuery is responsible for sending the provided payload to the desingated URL
-func (q *MultiOpQueryer) sendQueryRequest(payload []byte) ([]byte, error) {
+func (q *MultiOpQueryer) sendQueryRequest(payload []byte, batchSize int) ([]byte, error) {
 	// construct the initial request we will send to the client
 	req, err := http.NewRequest("POST", q.url, bytes.NewBuffer(payload))
 	if err != nil {
@@ -21,7 +25,7 @@ func (q *MultiOpQueryer) sendQueryRequest(payload []byte) ([]byte, error) {
 	// add the current context to the request
 	req.Header.Set("Content-Type", "application/json")
 
-	return q.sendRequest(req)
+	return q.sendRequest(req, batchSize)
 }
 
 // SendMultipart is responsible for sending multipart request to the desingated URL
@@ -34,14 +38,34 @@ func (q *MultiOpQueryer) sendMultipartRequest(payload []byte, contentType string
 	// add the current context to the request
 	req.Header.Set("Content-Type", contentType)
 
-	return q.sendRequest(req)
+	return q.sendRequest(req, 1)
 }
 
-func (q *MultiOpQueryer) sendRequest(request *http.Request) ([]byte, error) {
-	// add ctx to request
-	if q.ctx != nil {
-		request = request.WithContext(q.ctx)
+// sendRequest sends request, which contains batchSize operations, tracing and measuring it as single downstream call
+func (q *MultiOpQueryer) sendRequest(request *http.Request, batchSize int) (_ []byte, finalErr error) {
+	ctx := q.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if q.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, q.timeout)
+		defer cancel()
 	}
+
+	ctx, span := tracing.Start(ctx, "graphql.downstream")
+	span.SetAttributes(
+		tracing.String("http.url", q.url),
+		tracing.Int("graphql.batch_size", batchSize),
+	)
+	defer func() {
+		span.RecordError(finalErr)
+		span.End()
+	}()
+
+	// add ctx to request
+	request = request.WithContext(ctx)
 	// we could have any number of middlewares that we have to go through so
 	for _, mdware := range q.mdwares {
 		err := mdware(request)
@@ -49,17 +73,24 @@ func (q *MultiOpQueryer) sendRequest(request *http.Request) ([]byte, error) {
 			return nil, err
 		}
 	}
+	// span of the request is injected last, so traceparent forwarded from the client doesn't replace it
+	tracing.Inject(ctx, request.Header)
 
 	// fire the response to the queryer's url
 	if q.client == nil {
 		q.client = &http.Client{}
 	}
 
+	start := time.Now()
 	resp, err := q.client.Do(request)
 	if err != nil {
+		metrics.FromContext(ctx).ObserveDownstreamHTTP(q.url, batchSize, 0, time.Since(start))
 		return nil, err
 	}
 
+	metrics.FromContext(ctx).ObserveDownstreamHTTP(q.url, batchSize, resp.StatusCode, time.Since(start))
+	span.SetAttributes(tracing.Int("http.status_code", resp.StatusCode))
+
 	// read the full body
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
@@ -77,6 +108,10 @@ func (q *MultiOpQueryer) sendRequest(request *http.Request) ([]byte, error) {
 }
 
 func (q *MultiOpQueryer) fetch(inputs []*requests.Request) ([]requests.Response, error) {
+	if q.batchMode == BatchModeAlias {
+		return q.fetchAliased(inputs)
+	}
+
 	var payload []byte
 
 	bRs, err := json.Marshal(inputs)
@@ -89,7 +124,7 @@ func (q *MultiOpQueryer) fetch(inputs []*requests.Request) ([]requests.Response,
 	results := make(requests.Responses, len(inputs))
 
 	// execute http request
-	response, err := q.sendQueryRequest(payload)
+	response, err := q.sendQueryRequest(payload, len(inputs))
 
 	if err != nil {
 		return nil, err
@@ -0,0 +1,116 @@
+package queryer
+
+import (
+	"net/http"
+	"strings"
+)
+
+// notForwardedHeaders are never forwarded, as they're related to the connection or set by the queryer itself
+var notForwardedHeaders = map[string]struct{}{
+	"Connection":          {},
+	"Content-Length":      {},
+	"Content-Type":        {},
+	"Host":                {},
+	"Keep-Alive":          {},
+	"Proxy-Authenticate":  {},
+	"Proxy-Authorization": {},
+	"Te":                  {},
+	"Trailer":             {},
+	"Transfer-Encoding":   {},
+	"Upgrade":             {},
+}
+
+// HeadersMiddleware returns middleware, which sets provided headers to each request
+func HeadersMiddleware(headers http.Header) RequestMiddleware {
+	return func(r *http.Request) error {
+		for name, values := range headers {
+			r.Header.Del(name)
+			for _, value := range values {
+				r.Header.Add(name, value)
+			}
+		}
+		return nil
+	}
+}
+
+// HeaderForwardingPolicy defines which headers of the client request are passed to remote service
+type HeaderForwardingPolicy struct {
+	// Allow contains names of headers, which are forwarded as is, f.e. "Authorization"
+	Allow []string
+	// AllowPrefixes contains prefixes of header names, which are forwarded as is, f.e. "X-Forwarded-"
+	AllowPrefixes []string
+	// Rename maps name of client header to the name it's forwarded with, f.e. "Authorization": "X-Client-Authorization"
+	Rename map[string]string
+	// Set contains static headers, which are set to each request, they take precedence over forwarded ones
+	Set http.Header
+}
+
+// Middleware returns middleware, which sets headers of the source request according to the policy.
+// source may be nil, then only static headers are set.
+func (p *HeaderForwardingPolicy) Middleware(source *http.Request) RequestMiddleware {
+	return HeadersMiddleware(p.Headers(source))
+}
+
+// Headers returns headers of the source request, which should be passed to remote service
+func (p *HeaderForwardingPolicy) Headers(source *http.Request) http.Header {
+	headers := make(http.Header)
+
+	if source != nil {
+		for name, values := range source.Header {
+			name = http.CanonicalHeaderKey(name)
+			if !isForwardable(name) {
+				continue
+			}
+
+			if target, ok := p.renamed(name); ok {
+				headers[http.CanonicalHeaderKey(target)] = append([]string{}, values...)
+				continue
+			}
+
+			if p.isAllowed(name) {
+				headers[name] = append([]string{}, values...)
+			}
+		}
+	}
+
+	for name, values := range p.Set {
+		headers[http.CanonicalHeaderKey(name)] = append([]string{}, values...)
+	}
+
+	return headers
+}
+
+func (p *HeaderForwardingPolicy) renamed(name string) (string, bool) {
+	for from, to := range p.Rename {
+		if http.CanonicalHeaderKey(from) == name {
+			return to, true
+		}
+	}
+
+	return "", false
+}
+
+func (p *HeaderForwardingPolicy) isAllowed(name string) bool {
+	for _, allowed := range p.Allow {
+		if http.CanonicalHeaderKey(allowed) == name {
+			return true
+		}
+	}
+
+	for _, prefix := range p.AllowPrefixes {
+		if strings.HasPrefix(strings.ToLower(name), strings.ToLower(prefix)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isForwardable(name string) bool {
+	if _, ok := notForwardedHeaders[name]; ok {
+		return false
+	}
+
+	// websocket handshake headers are set by the dialer
+	return !strings.HasPrefix(name, "Sec-Websocket-")
+}
@@ -0,0 +1,54 @@
+package queryer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeaderForwardingPolicy(t *testing.T) {
+	source := httptest.NewRequest(http.MethodPost, "/", nil)
+	source.Header.Set("Authorization", "Bearer token")
+	source.Header.Set("X-Forwarded-For", "1.1.1.1")
+	source.Header.Add("X-Forwarded-Proto", "https")
+	source.Header.Set("X-Request-Id", "1")
+	source.Header.Set("Cookie", "secret")
+	source.Header.Set("Content-Type", "text/plain")
+	source.Header.Set("Sec-Websocket-Key", "key")
+	source.Header.Set("X-Version", "1")
+
+	policy := &HeaderForwardingPolicy{
+		Allow:         []string{"authorization", "content-type", "x-version"},
+		AllowPrefixes: []string{"x-forwarded-", "sec-"},
+		Rename:        map[string]string{"x-request-id": "x-client-request-id"},
+		Set:           http.Header{"X-Version": []string{"2"}},
+	}
+
+	assert.Equal(t, http.Header{
+		"Authorization":       []string{"Bearer token"},
+		"X-Forwarded-For":     []string{"1.1.1.1"},
+		"X-Forwarded-Proto":   []string{"https"},
+		"X-Client-Request-Id": []string{"1"},
+		"X-Version":           []string{"2"},
+	}, policy.Headers(source))
+
+	assert.Equal(t, http.Header{"X-Version": []string{"2"}}, policy.Headers(nil))
+}
+
+func TestHeaderForwardingPolicyMiddleware(t *testing.T) {
+	source := httptest.NewRequest(http.MethodPost, "/", nil)
+	source.Header.Set("Authorization", "Bearer token")
+
+	policy := &HeaderForwardingPolicy{Allow: []string{"Authorization"}}
+
+	request := httptest.NewRequest(http.MethodPost, "/", nil)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Authorization", "Basic other")
+
+	require.NoError(t, policy.Middleware(source)(request))
+	assert.Equal(t, "Bearer token", request.Header.Get("Authorization"))
+	assert.Equal(t, "application/json", request.Header.Get("Content-Type"))
+}
@@ -2,7 +2,9 @@ package queryer
 
 import (
 	"context"
+	"errors"
 	"net/http"
+	"time"
 
 	"github.com/buildbuildio/pebbles/common"
 	"github.com/buildbuildio/pebbles/requests"
@@ -12,13 +14,15 @@ import (
 type chunkResponse struct {
 	Index    int
 	Response []map[string]interface{}
+	Errors   ResponseErrors
 }
 
 // RequestMiddleware are functions can be passed to Queryer to affect its internal behavior
 type RequestMiddleware func(*http.Request) error
 
 // MultiOpQueryer is a queryer that will batch subsequent query on some interval into a single network request
-// to a single target
+// to a single target. Inputs of single Query call are always batched, queries of different queryers are batched
+// when they share BatchDispatcher.
 type MultiOpQueryer struct {
 	ctx     context.Context
 	url     string
@@ -26,9 +30,20 @@ type MultiOpQueryer struct {
 	mdwares []RequestMiddleware
 
 	maxBatchSize int
+	batchMode    BatchMode
+	timeout      time.Duration
+
+	subscriptionProtocol  SubscriptionProtocol
+	connectionInitPayload map[string]interface{}
+	subscriptionPool      *SubscriptionPool
+	subscriptionReconnect *ReconnectPolicy
+	dialTimeout           time.Duration
+
+	batchDispatcher *BatchDispatcher
+	batchHeaders    http.Header
 }
 
-var _ Queryer = &MultiOpQueryer{}
+var _ ContextQueryer = &MultiOpQueryer{}
 
 // NewMultiOpQueryer returns a MultiOpQueryer with the provided parameters
 func NewMultiOpQueryer(url string, maxBatchSize int) *MultiOpQueryer {
@@ -61,11 +76,47 @@ func (q *MultiOpQueryer) WithHTTPClient(client *http.Client) *MultiOpQueryer {
 	return q
 }
 
+// WithBatchMode sets the way batch of requests is sent to remote service, by default requests are sent as JSON array
+func (q *MultiOpQueryer) WithBatchMode(mode BatchMode) *MultiOpQueryer {
+	q.batchMode = mode
+	return q
+}
+
+// WithTimeout sets timeout of each http request sent to remote service, zero means no timeout
+func (q *MultiOpQueryer) WithTimeout(timeout time.Duration) *MultiOpQueryer {
+	q.timeout = timeout
+	return q
+}
+
+// WithDialTimeout sets timeout of opening subscription websocket, including handshake, 1 second by default
+func (q *MultiOpQueryer) WithDialTimeout(timeout time.Duration) *MultiOpQueryer {
+	q.dialTimeout = timeout
+	return q
+}
+
 func (q *MultiOpQueryer) URL() string {
 	return q.url
 }
 
+// Query executes provided inputs splitting them into batches of max batch size.
+// If remote service responded with errors for some of the inputs, ResponseErrors is returned along with the data.
 func (q *MultiOpQueryer) Query(inputs []*requests.Request) ([]map[string]interface{}, error) {
+	if q.batchDispatcher != nil {
+		return q.batchDispatcher.query(q, inputs)
+	}
+
+	return q.query(inputs)
+}
+
+// QueryContext executes provided inputs same as Query, but passes ctx to http.Request instead of the one
+// set by WithContext. The queryer itself isn't modified, so it's safe to call concurrently.
+func (q *MultiOpQueryer) QueryContext(ctx context.Context, inputs []*requests.Request) ([]map[string]interface{}, error) {
+	bound := *q
+	bound.ctx = ctx
+	return bound.Query(inputs)
+}
+
+func (q *MultiOpQueryer) query(inputs []*requests.Request) ([]map[string]interface{}, error) {
 	// fit in max batch size
 	lInputs := len(inputs)
 	if lInputs <= q.maxBatchSize {
@@ -75,6 +126,8 @@ func (q *MultiOpQueryer) Query(inputs []*requests.Request) ([]map[string]interfa
 	// divide into smaller batches
 	chunks := lInputs/q.maxBatchSize + 1
 
+	respErrs := make(ResponseErrors, lInputs)
+
 	res, err := common.AsyncMapReduce(
 		lo.Range(chunks),
 		make([]map[string]interface{}, len(inputs)),
@@ -87,17 +140,21 @@ func (q *MultiOpQueryer) Query(inputs []*requests.Request) ([]map[string]interfa
 			}
 
 			res, err := q.queryBatch(inputsSlice)
-			if err != nil {
+			var chunkErrs ResponseErrors
+			if err != nil && !errors.As(err, &chunkErrs) {
 				return nil, err
 			}
 
 			return &chunkResponse{
 				Index:    i,
 				Response: res,
+				Errors:   chunkErrs,
 			}, nil
 		},
 		func(acc []map[string]interface{}, value *chunkResponse) []map[string]interface{} {
 			i := value.Index
+			copy(respErrs[i*q.maxBatchSize:], value.Errors)
+
 			tail := value.Response
 			if (i+1)*q.maxBatchSize < lInputs {
 				tail = append(tail, acc[(i+1)*q.maxBatchSize:]...)
@@ -112,12 +169,17 @@ func (q *MultiOpQueryer) Query(inputs []*requests.Request) ([]map[string]interfa
 		return nil, err
 	}
 
+	if !respErrs.IsEmpty() {
+		return res, respErrs
+	}
+
 	return res, nil
 }
 
 // queryBatch executes provided inputs in single response
 func (q *MultiOpQueryer) queryBatch(inputs []*requests.Request) ([]map[string]interface{}, error) {
 	results := make([]map[string]interface{}, len(inputs))
+	respErrs := make(ResponseErrors, len(inputs))
 	var toFetchIndexes []int
 	var inputsToFetch []*requests.Request
 
@@ -134,16 +196,13 @@ func (q *MultiOpQueryer) queryBatch(inputs []*requests.Request) ([]map[string]in
 			continue
 		}
 
-		if len(resp.Errors) != 0 {
-			return nil, resp.Errors
-		}
-
+		respErrs[i] = resp.Errors
 		results[i] = resp.Data
 	}
 
 	// all inputs were files
 	if len(inputsToFetch) == 0 {
-		return results, nil
+		return results, respErrs.orNil()
 	}
 
 	resps, err := q.fetch(inputsToFetch)
@@ -151,13 +210,15 @@ func (q *MultiOpQueryer) queryBatch(inputs []*requests.Request) ([]map[string]in
 		return nil, err
 	}
 
+	if len(resps) != len(inputsToFetch) {
+		return nil, errors.New("number of responses doesn't match number of requests")
+	}
+
 	// format the result as needed
 	for i, resp := range resps {
-		if len(resp.Errors) != 0 {
-			return nil, resp.Errors
-		}
+		respErrs[toFetchIndexes[i]] = resp.Errors
 		results[toFetchIndexes[i]] = resp.Data
 	}
 
-	return results, nil
+	return results, respErrs.orNil()
 }
@@ -179,6 +179,33 @@ func TestMultiOpQueryerQuery(t *testing.T) {
 	assert.EqualValues(t, expectedResult, results)
 }
 
+func TestMultiOpQueryerQueryContext(t *testing.T) {
+	var values []interface{}
+	queryer := NewMultiOpQueryer("foo", 3).WithContext(
+		context.WithValue(context.Background(), "key", "queryer"),
+	).WithHTTPClient(&http.Client{
+		Transport: roundTripFunc(func(req *http.Request) *http.Response {
+			values = append(values, req.Context().Value("key"))
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(`[{"data": {"called": 0}}]`)),
+				Header:     make(http.Header),
+			}
+		}),
+	})
+
+	inputs := []*requests.Request{{Query: "{ called }"}}
+
+	_, err := queryer.QueryContext(context.WithValue(context.Background(), "key", "caller"), inputs)
+	require.NoError(t, err)
+
+	// context of the queryer itself stays the same
+	_, err = queryer.Query(inputs)
+	require.NoError(t, err)
+
+	assert.Equal(t, []interface{}{"caller", "queryer"}, values)
+}
+
 func TestMultiOpQueryerQueryFile(t *testing.T) {
 	queryer := NewMultiOpQueryer("foo", 3)
 
@@ -266,3 +293,40 @@ func TestMultiOpQueryerQueryFileError(t *testing.T) {
 	)
 	assert.EqualError(t, err, "myError")
 }
+
+func TestMultiOpQueryerPartialErrors(t *testing.T) {
+	queryer := NewMultiOpQueryer("foo", 2)
+
+	queryer.WithHTTPClient(&http.Client{
+		Transport: roundTripFunc(func(req *http.Request) *http.Response {
+			defer req.Body.Close()
+
+			return &http.Response{
+				StatusCode: 200,
+				Body: ioutil.NopCloser(bytes.NewBufferString(`[
+					{"data": {"called": true}},
+					{"data": {"called": null}, "errors": [{"message": "myError", "path": ["called"]}]}
+				]`)),
+				Header: make(http.Header),
+			}
+		}),
+	})
+
+	res, err := queryer.Query(
+		[]*requests.Request{{Query: "{ called }"}, {Query: "{ called }"}},
+	)
+	require.Error(t, err)
+	assert.EqualError(t, err, "myError")
+
+	respErrs, ok := err.(ResponseErrors)
+	require.True(t, ok)
+	require.Len(t, respErrs, 2)
+	assert.Empty(t, respErrs[0])
+	require.Len(t, respErrs[1], 1)
+	assert.Equal(t, []interface{}{"called"}, respErrs[1][0].Path)
+
+	assert.Equal(t, []map[string]interface{}{
+		{"called": true},
+		{"called": nil},
+	}, res)
+}
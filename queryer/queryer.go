@@ -1,9 +1,29 @@
 package queryer
 
-import "github.com/buildbuildio/pebbles/requests"
+import (
+	"context"
+
+	"github.com/buildbuildio/pebbles/requests"
+)
 
 type Queryer interface {
 	Query([]*requests.Request) ([]map[string]interface{}, error)
 	Subscribe(*requests.Request, <-chan struct{}, chan *requests.Response) error
 	URL() string
 }
+
+// ContextQueryer is a Queryer, which is able to bind single query to the context of the caller,
+// f.e. to nest spans of downstream requests under the span of the caller
+type ContextQueryer interface {
+	Queryer
+	QueryContext(context.Context, []*requests.Request) ([]map[string]interface{}, error)
+}
+
+// QueryContext executes inputs with q bound to ctx if q supports it, otherwise ctx is ignored
+func QueryContext(ctx context.Context, q Queryer, inputs []*requests.Request) ([]map[string]interface{}, error) {
+	if cq, ok := q.(ContextQueryer); ok {
+		return cq.QueryContext(ctx, inputs)
+	}
+
+	return q.Query(inputs)
+}
@@ -0,0 +1,50 @@
+package queryer
+
+import "time"
+
+// Statuses of upstream connection sent to subscribers, when ReconnectPolicy.Extension is set
+const (
+	// SubscriptionInterrupted is sent when connection to the service is dropped
+	SubscriptionInterrupted = "interrupted"
+	// SubscriptionResumed is sent when connection is restored and subscriptions are restarted
+	SubscriptionResumed = "resumed"
+)
+
+const (
+	defaultInitialBackoff = 100 * time.Millisecond
+	defaultMaxBackoff     = 30 * time.Second
+)
+
+// ReconnectPolicy defines how dropped websocket to the service is restored. Connection is dialed again
+// with the same headers and connection_init payload, then running subscriptions are started again.
+type ReconnectPolicy struct {
+	// MaxAttempts is maximum number of attempts in a row, zero means no limit
+	MaxAttempts int
+	// InitialBackoff is delay before the first attempt, it's doubled after each one, 100ms by default
+	InitialBackoff time.Duration
+	// MaxBackoff limits delay between attempts, 30s by default
+	MaxBackoff time.Duration
+	// Extension is a key of response extensions, which reports connection status to subscribers,
+	// f.e. {"extensions": {"reconnect": "interrupted"}}. Nothing is reported if it's empty.
+	Extension string
+}
+
+// WithSubscriptionReconnect sets policy of restoring dropped subscription websockets, by default subscriptions are finished
+func (q *MultiOpQueryer) WithSubscriptionReconnect(policy *ReconnectPolicy) *MultiOpQueryer {
+	q.subscriptionReconnect = policy
+	return q
+}
+
+func (p *ReconnectPolicy) initialBackoff() time.Duration {
+	if p.InitialBackoff <= 0 {
+		return defaultInitialBackoff
+	}
+	return p.InitialBackoff
+}
+
+func (p *ReconnectPolicy) maxBackoff() time.Duration {
+	if p.MaxBackoff <= 0 {
+		return defaultMaxBackoff
+	}
+	return p.MaxBackoff
+}
@@ -0,0 +1,119 @@
+package queryer
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/buildbuildio/pebbles/requests"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newDroppingServer returns server, which drops the first connection after sending single result,
+// when accept is false, reconnects are rejected
+func newDroppingServer(t *testing.T, accept bool) (*httptest.Server, *int32) {
+	var connections int32
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&connections, 1)
+		if n > 1 && !accept {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		conn, _, _, err := ws.HTTPUpgrader{}.Upgrade(r, w)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		for {
+			msg, err := wsutil.ReadClientText(conn)
+			if err != nil {
+				return
+			}
+
+			var subMsg requests.ClientSubMsg
+			require.NoError(t, json.Unmarshal(msg, &subMsg))
+
+			if subMsg.Type != requests.SubStart {
+				continue
+			}
+
+			assert.Equal(t, "subscription { test }", subMsg.Payload.Query)
+			b, _ := json.Marshal(requests.ServerSubMsg{
+				ID:      subMsg.ID,
+				Type:    requests.SubData,
+				Payload: &requests.Response{Data: map[string]interface{}{"connection": float64(n)}},
+			})
+			wsutil.WriteServerText(conn, b)
+
+			if n == 1 {
+				return
+			}
+		}
+	}))
+
+	return s, &connections
+}
+
+func receiveResponse(t *testing.T, resCh chan *requests.Response) *requests.Response {
+	select {
+	case res := <-resCh:
+		return res
+	case <-time.After(time.Second):
+		require.FailNow(t, "timeout")
+		return nil
+	}
+}
+
+func TestSubscribeReconnect(t *testing.T) {
+	s, connections := newDroppingServer(t, true)
+	defer s.Close()
+
+	queryer := NewMultiOpQueryer(s.URL, 1).WithSubscriptionReconnect(&ReconnectPolicy{
+		InitialBackoff: 10 * time.Millisecond,
+		Extension:      "reconnect",
+	})
+
+	closeCh := make(chan struct{}, 1)
+	resCh := make(chan *requests.Response)
+	require.NoError(t, queryer.Subscribe(&requests.Request{Query: "subscription { test }"}, closeCh, resCh))
+	defer func() {
+		closeCh <- struct{}{}
+	}()
+
+	assert.Equal(t, map[string]interface{}{"connection": float64(1)}, receiveResponse(t, resCh).Data)
+	assert.Equal(t, map[string]interface{}{"reconnect": SubscriptionInterrupted}, receiveResponse(t, resCh).Extensions)
+	assert.Equal(t, map[string]interface{}{"reconnect": SubscriptionResumed}, receiveResponse(t, resCh).Extensions)
+	assert.Equal(t, map[string]interface{}{"connection": float64(2)}, receiveResponse(t, resCh).Data)
+	assert.EqualValues(t, 2, atomic.LoadInt32(connections))
+}
+
+func TestSubscribeReconnectMaxAttempts(t *testing.T) {
+	s, connections := newDroppingServer(t, false)
+	defer s.Close()
+
+	queryer := NewMultiOpQueryer(s.URL, 1).WithSubscriptionReconnect(&ReconnectPolicy{
+		MaxAttempts:    2,
+		InitialBackoff: 10 * time.Millisecond,
+	})
+
+	closeCh := make(chan struct{}, 1)
+	resCh := make(chan *requests.Response)
+	require.NoError(t, queryer.Subscribe(&requests.Request{Query: "subscription { test }"}, closeCh, resCh))
+	defer func() {
+		closeCh <- struct{}{}
+	}()
+
+	assert.NotNil(t, receiveResponse(t, resCh))
+	// subscription is finished after all attempts failed, nothing is reported without extension
+	assert.Nil(t, receiveResponse(t, resCh))
+	assert.EqualValues(t, 3, atomic.LoadInt32(connections))
+}
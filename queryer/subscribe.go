@@ -1,16 +1,48 @@
 package queryer
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
-	"net/url"
-	"time"
+	"strings"
 
 	"github.com/buildbuildio/pebbles/requests"
-	"github.com/gobwas/ws"
-	"github.com/gobwas/ws/wsutil"
 )
 
+// SubscriptionProtocol defines how subscriptions are sent to remote service
+type SubscriptionProtocol string
+
+const (
+	// SubscriptionProtocolAuto offers both websocket subprotocols and uses the one chosen by the service,
+	// legacy graphql-ws is used if service doesn't choose any
+	SubscriptionProtocolAuto SubscriptionProtocol = ""
+	// SubscriptionProtocolGraphQLWS uses legacy subscriptions-transport-ws protocol
+	SubscriptionProtocolGraphQLWS SubscriptionProtocol = requests.GraphQLWSProtocol
+	// SubscriptionProtocolGraphQLTransportWS uses graphql-ws protocol
+	SubscriptionProtocolGraphQLTransportWS SubscriptionProtocol = requests.GraphQLTransportWSProtocol
+	// SubscriptionProtocolSSE sends subscription as POST request and reads results from text/event-stream response
+	SubscriptionProtocolSSE SubscriptionProtocol = "sse"
+)
+
+// WithSubscriptionProtocol sets protocol used for subscriptions, by default it's negotiated during websocket handshake
+func (q *MultiOpQueryer) WithSubscriptionProtocol(protocol SubscriptionProtocol) *MultiOpQueryer {
+	q.subscriptionProtocol = protocol
+	return q
+}
+
+// WithConnectionInitPayload sets payload of connection_init message sent to the service over websocket
+func (q *MultiOpQueryer) WithConnectionInitPayload(payload map[string]interface{}) *MultiOpQueryer {
+	q.connectionInitPayload = payload
+	return q
+}
+
+// Subscribe subscribes to remote service. Each result is sent to resCh, nil is sent after subscription is finished.
+// Subscription is stopped when closeCh is signalled.
 func (q *MultiOpQueryer) Subscribe(req *requests.Request, closeCh <-chan struct{}, resCh chan *requests.Response) error {
 	r := &http.Request{
 		Header: make(http.Header),
@@ -24,111 +56,168 @@ func (q *MultiOpQueryer) Subscribe(req *requests.Request, closeCh <-chan struct{
 		}
 	}
 
-	dialer := ws.Dialer{
-		Timeout:   time.Second,
-		Protocols: []string{"graphql-ws"},
-		Header:    ws.HandshakeHeaderHTTP(r.Header),
+	if q.subscriptionProtocol == SubscriptionProtocolSSE {
+		return q.subscribeSSE(r, req, closeCh, resCh)
+	}
+
+	return q.subscribeWS(r, req, closeCh, resCh)
+}
+
+// subscribeWS runs subscription over websocket, which is either dedicated or shared via pool
+func (q *MultiOpQueryer) subscribeWS(r *http.Request, req *requests.Request, closeCh <-chan struct{}, resCh chan *requests.Response) error {
+	var uc *upstreamConn
+	var release func()
+
+	if q.subscriptionPool != nil {
+		key, err := poolKey(q.url, q.subscriptionProtocol, r.Header, q.connectionInitPayload)
+		if err != nil {
+			return err
+		}
+
+		uc, release, err = q.subscriptionPool.acquire(key, func() (*upstreamConn, error) {
+			return q.dialUpstream(r)
+		})
+		if err != nil {
+			return err
+		}
+	} else {
+		var err error
+		uc, err = q.dialUpstream(r)
+		if err != nil {
+			return err
+		}
+		go uc.read()
+
+		release = uc.close
+	}
+
+	sub, err := uc.subscribe(req, resCh)
+	if err != nil {
+		release()
+		return err
 	}
 
-	parsedURL, err := url.Parse(q.url)
+	go func() {
+		<-closeCh
+		uc.unsubscribe(sub)
+		release()
+	}()
+
+	return nil
+}
+
+// subscribeSSE sends subscription as POST request and reads results from event stream.
+// Both "next" events and events without type are treated as results, "complete" event finishes subscription.
+func (q *MultiOpQueryer) subscribeSSE(r *http.Request, req *requests.Request, closeCh <-chan struct{}, resCh chan *requests.Response) error {
+	payload, err := json.Marshal(req)
 	if err != nil {
 		return err
 	}
 
-	parsedURL.Scheme = "ws"
+	ctx, cancel := context.WithCancel(r.Context())
 
-	conn, _, _, err := dialer.Dial(q.ctx, parsedURL.String())
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, q.url, bytes.NewBuffer(payload))
 	if err != nil {
+		cancel()
 		return err
 	}
+	for name, values := range r.Header {
+		request.Header[name] = values
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Accept", "text/event-stream")
 
-	errCh := make(chan error)
-	defer close(errCh)
+	resp, err := q.client.Do(request)
+	if err != nil {
+		cancel()
+		return err
+	}
 
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		cancel()
+		return fmt.Errorf("response was not successful with status code: %d: %s", resp.StatusCode, string(body))
+	}
+
+	// left is closed once subscriber isn't interested in results anymore, nothing is sent to resCh after that
+	left := make(chan struct{})
 	go func() {
-		defer func() {
-			recover()
-		}()
 		<-closeCh
-		conn.Close()
+		close(left)
+		cancel()
 	}()
 
 	go func() {
 		defer func() {
-			defer func() {
-				recover()
-			}()
-			conn.Close()
+			resp.Body.Close()
+			cancel()
 			// indicate that it's done
-			resCh <- nil
+			select {
+			case resCh <- nil:
+			case <-left:
+			}
 		}()
 
-		bInitMsg, err := json.Marshal(requests.ClientSubMsg{
-			Type: requests.SubConnectionInit,
+		readEvents(resp.Body, func(event, data string) bool {
+			switch event {
+			case "", "next":
+				var res requests.Response
+				if err := json.Unmarshal([]byte(data), &res); err != nil {
+					return false
+				}
+				select {
+				case resCh <- &res:
+					return true
+				case <-left:
+					return false
+				}
+			case "complete":
+				return false
+			}
+			return true
 		})
-		if err != nil {
-			errCh <- err
-			return
-		}
-
-		// send init msg
-		if err := wsutil.WriteClientText(conn, bInitMsg); err != nil {
-			errCh <- err
-			return
-		}
+	}()
 
-		bRequestMsg, err := json.Marshal(requests.ClientSubMsg{
-			Type:    requests.SubStart,
-			ID:      "1",
-			Payload: req,
-		})
-		if err != nil {
-			errCh <- err
-			return
-		}
-		// send query msg
-		if err := wsutil.WriteClientText(conn, bRequestMsg); err != nil {
-			errCh <- err
-			return
-		}
+	return nil
+}
 
-		// init proccess is done
-		errCh <- nil
+// readEvents reads text/event-stream and calls fn for each event with data until it returns false
+func readEvents(r io.Reader, fn func(event, data string) bool) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
 
-		for {
-			msg, err := wsutil.ReadServerText(conn)
-			if err != nil {
-				return
-			}
+	var event string
+	var data []string
+	for scanner.Scan() {
+		line := scanner.Text()
 
-			var serverResp requests.ServerSubMsg
-			if err := json.Unmarshal(msg, &serverResp); err != nil {
-				// try to unmarshal as error msg
-				var serverErrorResp requests.ServerSubErorrMsg
-				if innerErr := json.Unmarshal(msg, &serverErrorResp); innerErr != nil {
+		if line == "" {
+			// empty line dispatches event
+			if len(data) != 0 || event != "" {
+				if !fn(event, strings.Join(data, "\n")) {
 					return
 				}
-				resCh <- &requests.Response{
-					Errors: serverErrorResp.Payload,
-				}
-				continue
 			}
+			event, data = "", nil
+			continue
+		}
 
-			switch serverResp.Type {
-			case requests.SubComplete,
-				requests.SubConnectionError,
-				requests.SubConnectionTerminate,
-				requests.SubError:
-				return
-			case requests.SubData:
-				resCh <- serverResp.Payload
-			}
+		// comments are used as keep alive
+		if strings.HasPrefix(line, ":") {
+			continue
 		}
-	}()
 
-	if err := <-errCh; err != nil {
-		return err
-	}
+		field, value := line, ""
+		if i := strings.IndexByte(line, ':'); i >= 0 {
+			field, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+		}
 
-	return nil
+		switch field {
+		case "event":
+			event = value
+		case "data":
+			data = append(data, value)
+		}
+	}
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"sync/atomic"
@@ -237,3 +238,232 @@ func TestSubscribeErrorNoSupportForWs(t *testing.T) {
 	}, closeCh, resCh)
 	require.Error(t, err)
 }
+
+func TestSubscribeTransportWS(t *testing.T) {
+	received := make(chan string, 10)
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upgrader := ws.HTTPUpgrader{
+			Protocol: func(subprotocol string) bool {
+				return subprotocol == requests.GraphQLTransportWSProtocol
+			},
+		}
+
+		conn, _, _, err := upgrader.Upgrade(r, w)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		write := func(msg interface{}) {
+			b, _ := json.Marshal(msg)
+			wsutil.WriteServerText(conn, b)
+		}
+
+		for {
+			msg, err := wsutil.ReadClientText(conn)
+			if err != nil {
+				return
+			}
+
+			var subMsg requests.ClientSubMsg
+			if err := json.Unmarshal(msg, &subMsg); err != nil {
+				return
+			}
+			received <- subMsg.Type
+
+			switch subMsg.Type {
+			case requests.SubConnectionInit:
+				// ping must be answered before ack
+				write(requests.ServerSubMsg{Type: requests.SubPing})
+			case requests.SubPong:
+				write(requests.ServerSubMsg{Type: requests.SubConnectionAck})
+			case requests.SubSubscribe:
+				assert.Equal(t, "1", subMsg.ID)
+				write(requests.ServerSubMsg{
+					ID:      subMsg.ID,
+					Type:    requests.SubNext,
+					Payload: &requests.Response{Data: map[string]interface{}{"hello": "world"}},
+				})
+				write(requests.ServerSubMsg{ID: subMsg.ID, Type: requests.SubComplete})
+			}
+		}
+	}))
+	defer s.Close()
+
+	for _, protocol := range []SubscriptionProtocol{SubscriptionProtocolAuto, SubscriptionProtocolGraphQLTransportWS} {
+		queryer := NewMultiOpQueryer(s.URL, 3).WithSubscriptionProtocol(protocol)
+
+		closeCh := make(chan struct{}, 1)
+		resCh := make(chan *requests.Response)
+
+		err := queryer.Subscribe(&requests.Request{Query: "test"}, closeCh, resCh)
+		require.NoError(t, err)
+
+		select {
+		case res := <-resCh:
+			assert.EqualValues(t, requests.Response{
+				Data: map[string]interface{}{"hello": "world"},
+			}, *res)
+		case <-time.After(time.Second):
+			assert.FailNow(t, "timeout")
+		}
+
+		select {
+		case res := <-resCh:
+			assert.Nil(t, res)
+		case <-time.After(time.Second):
+			assert.FailNow(t, "timeout")
+		}
+		closeCh <- struct{}{}
+
+		assert.Equal(t, requests.SubConnectionInit, <-received)
+		assert.Equal(t, requests.SubPong, <-received)
+		assert.Equal(t, requests.SubSubscribe, <-received)
+	}
+}
+
+func TestSubscribeSSE(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "text/event-stream", r.Header.Get("Accept"))
+		assert.Equal(t, "test", r.Header.Get("test"))
+
+		var req requests.Request
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, "subscription { hello }", req.Query)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte(": keep alive\n\n"))
+		w.Write([]byte("event: next\ndata: {\"data\":\ndata: {\"hello\": \"world\"}}\n\n"))
+		w.Write([]byte("data: {\"errors\": [{\"message\": \"test err\"}]}\n\n"))
+		w.Write([]byte("event: complete\ndata:\n\n"))
+		w.Write([]byte("event: next\ndata: {\"data\": {\"hello\": \"ignored\"}}\n\n"))
+	}))
+	defer s.Close()
+
+	queryer := NewMultiOpQueryer(s.URL, 3).
+		WithSubscriptionProtocol(SubscriptionProtocolSSE).
+		WithMiddlewares([]RequestMiddleware{func(r *http.Request) error {
+			r.Header.Set("test", "test")
+			return nil
+		}})
+
+	closeCh := make(chan struct{}, 1)
+	resCh := make(chan *requests.Response)
+
+	err := queryer.Subscribe(&requests.Request{Query: "subscription { hello }"}, closeCh, resCh)
+	require.NoError(t, err)
+
+	var results []*requests.Response
+	for res := range resCh {
+		if res == nil {
+			break
+		}
+		results = append(results, res)
+	}
+	closeCh <- struct{}{}
+
+	require.Len(t, results, 2)
+	assert.Equal(t, map[string]interface{}{"hello": "world"}, results[0].Data)
+	assert.Equal(t, "test err", results[1].Errors[0].Message)
+}
+
+func TestSubscribeSSEErrorStatus(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer s.Close()
+
+	queryer := NewMultiOpQueryer(s.URL, 3).WithSubscriptionProtocol(SubscriptionProtocolSSE)
+
+	err := queryer.Subscribe(&requests.Request{Query: "test"}, make(chan struct{}), make(chan *requests.Response))
+	assert.Error(t, err)
+}
+
+func TestSubscribeTLS(t *testing.T) {
+	s := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, _, _, err := ws.HTTPUpgrader{}.Upgrade(r, w)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		for {
+			msg, err := wsutil.ReadClientText(conn)
+			if err != nil {
+				return
+			}
+
+			var subMsg requests.ClientSubMsg
+			require.NoError(t, json.Unmarshal(msg, &subMsg))
+
+			if subMsg.Type == requests.SubStart {
+				b, _ := json.Marshal(requests.ServerSubMsg{
+					ID:      subMsg.ID,
+					Type:    requests.SubData,
+					Payload: &requests.Response{Data: map[string]interface{}{"hello": "world"}},
+				})
+				wsutil.WriteServerText(conn, b)
+			}
+		}
+	}))
+	defer s.Close()
+
+	// certificate of the server isn't trusted by default client
+	err := NewMultiOpQueryer(s.URL, 1).Subscribe(&requests.Request{Query: "test"}, make(chan struct{}), make(chan *requests.Response))
+	assert.Error(t, err)
+
+	queryer := NewMultiOpQueryer(s.URL, 1).WithHTTPClient(s.Client())
+
+	closeCh := make(chan struct{}, 1)
+	resCh := make(chan *requests.Response)
+	require.NoError(t, queryer.Subscribe(&requests.Request{Query: "test"}, closeCh, resCh))
+	defer func() {
+		closeCh <- struct{}{}
+	}()
+
+	select {
+	case res := <-resCh:
+		assert.Equal(t, map[string]interface{}{"hello": "world"}, res.Data)
+	case <-time.After(time.Second):
+		assert.FailNow(t, "timeout")
+	}
+}
+
+func TestSubscribeDialTimeout(t *testing.T) {
+	// server accepts connections, but never completes handshake
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	queryer := NewMultiOpQueryer("http://"+l.Addr().String(), 1).WithDialTimeout(20 * time.Millisecond)
+
+	start := time.Now()
+	err = queryer.Subscribe(&requests.Request{Query: "test"}, make(chan struct{}), make(chan *requests.Response))
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+}
+
+func TestWebsocketURL(t *testing.T) {
+	for rawURL, expected := range map[string]string{
+		"http://example.com/graphql":  "ws://example.com/graphql",
+		"https://example.com/graphql": "wss://example.com/graphql",
+		"ws://example.com":            "ws://example.com",
+		"wss://example.com":           "wss://example.com",
+	} {
+		actual, err := websocketURL(rawURL)
+		require.NoError(t, err)
+		assert.Equal(t, expected, actual)
+	}
+}
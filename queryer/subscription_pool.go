@@ -0,0 +1,171 @@
+package queryer
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// SubscriptionPool shares websocket connections between subscriptions to the same service
+// with the same credentials, i.e. headers and connection_init payload. Connection is closed
+// after it's not used for idle timeout. Pool is safe for concurrent use by many queryers.
+type SubscriptionPool struct {
+	idleTimeout time.Duration
+
+	mu    sync.Mutex
+	conns map[string]*pooledConn
+}
+
+type pooledConn struct {
+	// ready is closed once connection is dialed
+	ready chan struct{}
+	conn  *upstreamConn
+	err   error
+
+	refs      int
+	idleTimer *time.Timer
+}
+
+// isClosed returns true if connection is dialed and closed already
+func (pc *pooledConn) isClosed() bool {
+	select {
+	case <-pc.ready:
+		return pc.conn != nil && pc.conn.closed()
+	default:
+		return false
+	}
+}
+
+// NewSubscriptionPool returns pool, which closes connections without subscriptions after idleTimeout
+func NewSubscriptionPool(idleTimeout time.Duration) *SubscriptionPool {
+	return &SubscriptionPool{
+		idleTimeout: idleTimeout,
+		conns:       make(map[string]*pooledConn),
+	}
+}
+
+// WithSubscriptionPool sets pool of websocket connections used for subscriptions, by default each subscription has its own connection
+func (q *MultiOpQueryer) WithSubscriptionPool(pool *SubscriptionPool) *MultiOpQueryer {
+	q.subscriptionPool = pool
+	return q
+}
+
+// Close closes all connections of the pool, running subscriptions are finished
+func (p *SubscriptionPool) Close() {
+	p.mu.Lock()
+	conns := p.conns
+	p.conns = make(map[string]*pooledConn)
+	p.mu.Unlock()
+
+	for _, pc := range conns {
+		<-pc.ready
+		if pc.conn != nil {
+			pc.conn.close()
+		}
+	}
+}
+
+// Len returns number of open connections
+func (p *SubscriptionPool) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return len(p.conns)
+}
+
+// poolKey identifies connection by service and credentials used to open it
+func poolKey(url string, protocol SubscriptionProtocol, header http.Header, initPayload map[string]interface{}) (string, error) {
+	// maps are marshalled with sorted keys, so the same credentials give the same key
+	b, err := json.Marshal([]interface{}{url, protocol, header, initPayload})
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
+
+// acquire returns connection for the key, dialing it if there is none. Returned function must be called,
+// when connection isn't used by the caller anymore.
+func (p *SubscriptionPool) acquire(key string, dial func() (*upstreamConn, error)) (*upstreamConn, func(), error) {
+	p.mu.Lock()
+	pc, ok := p.conns[key]
+	// connection, which is closed, stays in the pool until its reading is finished, new one is dialed instead
+	if ok && pc.isClosed() {
+		delete(p.conns, key)
+		ok = false
+	}
+	if ok {
+		pc.refs++
+		if pc.idleTimer != nil {
+			pc.idleTimer.Stop()
+			pc.idleTimer = nil
+		}
+		p.mu.Unlock()
+
+		<-pc.ready
+	} else {
+		pc = &pooledConn{ready: make(chan struct{}), refs: 1}
+		p.conns[key] = pc
+		p.mu.Unlock()
+
+		pc.conn, pc.err = dial()
+		if pc.err == nil {
+			pc.conn.onClose = func() {
+				p.remove(key, pc)
+			}
+			go pc.conn.read()
+		} else {
+			p.remove(key, pc)
+		}
+		close(pc.ready)
+	}
+
+	release := func() {
+		p.release(key, pc)
+	}
+
+	if pc.err != nil {
+		release()
+		return nil, nil, pc.err
+	}
+
+	return pc.conn, release, nil
+}
+
+func (p *SubscriptionPool) remove(key string, pc *pooledConn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.conns[key] == pc {
+		delete(p.conns, key)
+	}
+}
+
+func (p *SubscriptionPool) release(key string, pc *pooledConn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pc.refs--
+	if pc.refs != 0 || p.conns[key] != pc {
+		return
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(p.idleTimeout, func() {
+		p.mu.Lock()
+		// connection was acquired again in the meantime
+		if pc.idleTimer != timer {
+			p.mu.Unlock()
+			return
+		}
+		pc.idleTimer = nil
+		if p.conns[key] == pc {
+			delete(p.conns, key)
+		}
+		p.mu.Unlock()
+
+		pc.conn.close()
+	})
+	pc.idleTimer = timer
+}
@@ -0,0 +1,258 @@
+package queryer
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/buildbuildio/pebbles/requests"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscriptionPool(t *testing.T) {
+	var connections, stopped int32
+	closed := make(chan struct{}, 10)
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, _, _, err := ws.HTTPUpgrader{
+			Protocol: func(subprotocol string) bool {
+				return subprotocol == requests.GraphQLTransportWSProtocol
+			},
+		}.Upgrade(r, w)
+		if err != nil {
+			return
+		}
+		defer func() {
+			conn.Close()
+			closed <- struct{}{}
+		}()
+		atomic.AddInt32(&connections, 1)
+
+		for {
+			msg, err := wsutil.ReadClientText(conn)
+			if err != nil {
+				return
+			}
+
+			var subMsg requests.ClientSubMsg
+			require.NoError(t, json.Unmarshal(msg, &subMsg))
+
+			var resp interface{}
+			switch subMsg.Type {
+			case requests.SubConnectionInit:
+				resp = requests.ServerSubMsg{Type: requests.SubConnectionAck}
+			case requests.SubSubscribe:
+				// echo operation id and credentials, so routing can be checked
+				resp = requests.ServerSubMsg{
+					ID:   subMsg.ID,
+					Type: requests.SubNext,
+					Payload: &requests.Response{Data: map[string]interface{}{
+						"id":    subMsg.ID,
+						"token": r.Header.Get("Authorization"),
+					}},
+				}
+			case requests.SubComplete:
+				atomic.AddInt32(&stopped, 1)
+				continue
+			}
+
+			b, _ := json.Marshal(resp)
+			wsutil.WriteServerText(conn, b)
+		}
+	}))
+	defer s.Close()
+
+	pool := NewSubscriptionPool(50 * time.Millisecond)
+	defer pool.Close()
+
+	subscribe := func(token string) (chan struct{}, chan *requests.Response) {
+		q := NewMultiOpQueryer(s.URL, 1).WithSubscriptionPool(pool).WithMiddlewares([]RequestMiddleware{
+			func(r *http.Request) error {
+				r.Header.Set("Authorization", token)
+				return nil
+			},
+		})
+
+		closeCh := make(chan struct{}, 1)
+		resCh := make(chan *requests.Response)
+		require.NoError(t, q.Subscribe(&requests.Request{Query: "subscription { test }"}, closeCh, resCh))
+		return closeCh, resCh
+	}
+
+	receive := func(resCh chan *requests.Response) map[string]interface{} {
+		select {
+		case res := <-resCh:
+			require.NotNil(t, res)
+			return res.Data
+		case <-time.After(time.Second):
+			require.FailNow(t, "timeout")
+			return nil
+		}
+	}
+
+	var closeChs []chan struct{}
+	for i, token := range []string{"a", "a", "a", "b"} {
+		closeCh, resCh := subscribe(token)
+		closeChs = append(closeChs, closeCh)
+
+		data := receive(resCh)
+		assert.Equal(t, token, data["token"])
+		if token == "a" {
+			assert.Equal(t, strconv.Itoa(i+1), data["id"])
+		}
+	}
+
+	// one connection per credentials set
+	assert.EqualValues(t, 2, atomic.LoadInt32(&connections))
+	assert.Equal(t, 2, pool.Len())
+
+	// connection is kept while it has subscriptions
+	closeChs[0] <- struct{}{}
+	closeChs[3] <- struct{}{}
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		require.FailNow(t, "idle connection isn't closed")
+	}
+	assert.Equal(t, 1, pool.Len())
+
+	closeChs[1] <- struct{}{}
+	closeChs[2] <- struct{}{}
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		require.FailNow(t, "idle connection isn't closed")
+	}
+	assert.Equal(t, 0, pool.Len())
+	assert.EqualValues(t, 4, atomic.LoadInt32(&stopped))
+}
+
+func TestSubscriptionPoolSlowSubscriber(t *testing.T) {
+	pong := make(chan struct{}, 1)
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, _, _, err := ws.HTTPUpgrader{
+			Protocol: func(subprotocol string) bool {
+				return subprotocol == requests.GraphQLTransportWSProtocol
+			},
+		}.Upgrade(r, w)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		write := func(msg interface{}) {
+			b, _ := json.Marshal(msg)
+			wsutil.WriteServerText(conn, b)
+		}
+
+		for {
+			msg, err := wsutil.ReadClientText(conn)
+			if err != nil {
+				return
+			}
+
+			var subMsg requests.ClientSubMsg
+			require.NoError(t, json.Unmarshal(msg, &subMsg))
+
+			switch subMsg.Type {
+			case requests.SubConnectionInit:
+				write(requests.ServerSubMsg{Type: requests.SubConnectionAck})
+			case requests.SubSubscribe:
+				// the first operation gets more results than its subscriber reads
+				count := 1
+				if subMsg.ID == "1" {
+					count = 10
+				}
+				for i := 0; i < count; i++ {
+					write(requests.ServerSubMsg{
+						ID:      subMsg.ID,
+						Type:    requests.SubNext,
+						Payload: &requests.Response{Data: map[string]interface{}{"id": subMsg.ID}},
+					})
+				}
+				if subMsg.ID == "1" {
+					write(requests.ServerSubMsg{Type: requests.SubPing})
+				}
+			case requests.SubPong:
+				pong <- struct{}{}
+			}
+		}
+	}))
+	defer s.Close()
+
+	pool := NewSubscriptionPool(time.Second)
+	defer pool.Close()
+
+	q := NewMultiOpQueryer(s.URL, 1).WithSubscriptionPool(pool)
+
+	slowCloseCh := make(chan struct{}, 1)
+	slowResCh := make(chan *requests.Response)
+	require.NoError(t, q.Subscribe(&requests.Request{Query: "subscription { test }"}, slowCloseCh, slowResCh))
+	defer func() { slowCloseCh <- struct{}{} }()
+
+	// ping sent after results of the slow subscriber is answered, though they aren't read
+	select {
+	case <-pong:
+	case <-time.After(time.Second):
+		require.FailNow(t, "ping isn't answered")
+	}
+
+	closeCh := make(chan struct{}, 1)
+	resCh := make(chan *requests.Response)
+	require.NoError(t, q.Subscribe(&requests.Request{Query: "subscription { test }"}, closeCh, resCh))
+	defer func() { closeCh <- struct{}{} }()
+
+	select {
+	case res := <-resCh:
+		require.NotNil(t, res)
+		assert.Equal(t, map[string]interface{}{"id": "2"}, res.Data)
+	case <-time.After(time.Second):
+		require.FailNow(t, "subscriber is blocked by the slow one")
+	}
+
+	assert.Equal(t, 1, pool.Len())
+}
+
+func TestSubscriptionPoolClosedConnection(t *testing.T) {
+	pool := NewSubscriptionPool(time.Minute)
+	defer pool.Close()
+
+	var dials int
+	dial := func() (*upstreamConn, error) {
+		dials++
+		conn, _ := net.Pipe()
+		return &upstreamConn{
+			conn:    conn,
+			subs:    make(map[string]*upstreamSub),
+			closeCh: make(chan struct{}),
+		}, nil
+	}
+
+	uc, release, err := pool.acquire("key", dial)
+	require.NoError(t, err)
+	defer release()
+
+	// connection is closed, but it isn't removed from the pool yet
+	uc.mu.Lock()
+	uc.isClosed = true
+	uc.mu.Unlock()
+	defer uc.conn.Close()
+
+	other, otherRelease, err := pool.acquire("key", dial)
+	require.NoError(t, err)
+	defer otherRelease()
+
+	assert.NotSame(t, uc, other)
+	assert.False(t, other.closed())
+	assert.Equal(t, 2, dials)
+	assert.Equal(t, 1, pool.Len())
+}
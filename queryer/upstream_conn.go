@@ -0,0 +1,570 @@
+package queryer
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/buildbuildio/pebbles/gqlerrors"
+	"github.com/buildbuildio/pebbles/requests"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+)
+
+const defaultDialTimeout = time.Second
+
+var errUpstreamConnClosed = errors.New("upstream connection is closed")
+
+// upstreamConn is a websocket connection to the service, which runs many subscriptions,
+// messages of the service are routed to them by operation id
+type upstreamConn struct {
+	// onClose is called once connection is closed, it's set before reading is started
+	onClose func()
+	// dial opens new connection, it's used to reconnect
+	dial      func() (net.Conn, bool, error)
+	reconnect *ReconnectPolicy
+
+	// conn and isTransportWS are replaced on reconnect
+	writeMu       sync.Mutex
+	conn          net.Conn
+	isTransportWS bool
+
+	mu       sync.Mutex
+	subs     map[string]*upstreamSub
+	lastID   int
+	isClosed bool
+	closeCh  chan struct{}
+}
+
+type upstreamSub struct {
+	id    string
+	req   *requests.Request
+	resCh chan *requests.Response
+	// done is closed when subscriber isn't interested in results anymore
+	done chan struct{}
+
+	// results are queued and sent to resCh by the own goroutine of the subscriber,
+	// so slow subscriber doesn't block reading of the connection shared with others
+	mu       sync.Mutex
+	queue    []*requests.Response
+	queued   chan struct{}
+	isClosed bool
+}
+
+func newUpstreamSub(id string, req *requests.Request, resCh chan *requests.Response) *upstreamSub {
+	sub := &upstreamSub{
+		id:     id,
+		req:    req,
+		resCh:  resCh,
+		done:   make(chan struct{}),
+		queued: make(chan struct{}, 1),
+	}
+	go sub.forward()
+
+	return sub
+}
+
+// deliver queues resp for the subscriber unless it has left or subscription is finished, it never blocks.
+// nil finishes subscription, nothing is delivered after it.
+func (s *upstreamSub) deliver(resp *requests.Response) {
+	s.mu.Lock()
+	if s.isClosed {
+		s.mu.Unlock()
+		return
+	}
+	s.queue = append(s.queue, resp)
+	s.isClosed = resp == nil
+	s.mu.Unlock()
+
+	select {
+	case s.queued <- struct{}{}:
+	default:
+	}
+}
+
+// leave drops queued results and stops forwarding, it must be called once
+func (s *upstreamSub) leave() {
+	s.mu.Lock()
+	s.isClosed = true
+	s.queue = nil
+	s.mu.Unlock()
+
+	close(s.done)
+}
+
+// forward sends queued results to resCh until subscription is finished or subscriber leaves
+func (s *upstreamSub) forward() {
+	for {
+		select {
+		case <-s.queued:
+		case <-s.done:
+			return
+		}
+
+		s.mu.Lock()
+		queue := s.queue
+		s.queue = nil
+		s.mu.Unlock()
+
+		for _, resp := range queue {
+			select {
+			case s.resCh <- resp:
+			case <-s.done:
+				return
+			}
+
+			if resp == nil {
+				return
+			}
+		}
+	}
+}
+
+// upstreamMsg is a message of the service, its payload depends on the type
+type upstreamMsg struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// dialUpstream opens websocket to the service and initializes connection with chosen protocol.
+// Reading isn't started, it's up to the caller to run read in separate goroutine.
+func (q *MultiOpQueryer) dialUpstream(r *http.Request) (*upstreamConn, error) {
+	conn, isTransportWS, err := q.dialUpstreamConn(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// connection may outlive subscriber, which has opened it, so its context isn't used for reconnects
+	redialRequest := r.WithContext(context.Background())
+
+	return &upstreamConn{
+		conn:          conn,
+		isTransportWS: isTransportWS,
+		dial: func() (net.Conn, bool, error) {
+			return q.dialUpstreamConn(redialRequest)
+		},
+		reconnect: q.subscriptionReconnect,
+		subs:      make(map[string]*upstreamSub),
+		closeCh:   make(chan struct{}),
+	}, nil
+}
+
+// dialUpstreamConn opens websocket and sends connection_init, it returns true if graphql-transport-ws protocol is used
+func (q *MultiOpQueryer) dialUpstreamConn(r *http.Request) (net.Conn, bool, error) {
+	protocols := []string{string(q.subscriptionProtocol)}
+	if q.subscriptionProtocol == SubscriptionProtocolAuto {
+		protocols = []string{requests.GraphQLTransportWSProtocol, requests.GraphQLWSProtocol}
+	}
+
+	dialTimeout := q.dialTimeout
+	if dialTimeout <= 0 {
+		dialTimeout = defaultDialTimeout
+	}
+
+	dialer := ws.Dialer{
+		Timeout:   dialTimeout,
+		Protocols: protocols,
+		Header:    ws.HandshakeHeaderHTTP(r.Header),
+		TLSConfig: q.tlsConfig(),
+	}
+
+	wsURL, err := websocketURL(q.url)
+	if err != nil {
+		return nil, false, err
+	}
+
+	conn, _, hs, err := dialer.Dial(r.Context(), wsURL)
+	if err != nil {
+		return nil, false, err
+	}
+
+	isTransportWS := hs.Protocol == requests.GraphQLTransportWSProtocol
+	if err := initUpstreamConn(conn, isTransportWS, q.connectionInitPayload); err != nil {
+		conn.Close()
+		return nil, false, err
+	}
+
+	return conn, isTransportWS, nil
+}
+
+// websocketURL returns websocket url of the service, secure one is used for https
+func websocketURL(rawURL string) (string, error) {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	switch parsedURL.Scheme {
+	case "https", "wss":
+		parsedURL.Scheme = "wss"
+	default:
+		parsedURL.Scheme = "ws"
+	}
+
+	return parsedURL.String(), nil
+}
+
+// tlsConfig returns TLS configuration of queryer http client, so custom CAs and client certificates are used for websockets too
+func (q *MultiOpQueryer) tlsConfig() *tls.Config {
+	transport := http.DefaultTransport
+	if q.client != nil && q.client.Transport != nil {
+		transport = q.client.Transport
+	}
+
+	if t, ok := transport.(*http.Transport); ok {
+		return t.TLSClientConfig
+	}
+
+	return nil
+}
+
+// initUpstreamConn sends connection_init message. Unlike legacy protocol, graphql-transport-ws requires
+// to wait for acknowledgement before subscribing and pings must be answered.
+func initUpstreamConn(conn net.Conn, isTransportWS bool, payload map[string]interface{}) error {
+	if err := writeUpstreamMsg(conn, requests.ClientInitMsg{
+		Type:    requests.SubConnectionInit,
+		Payload: payload,
+	}); err != nil {
+		return err
+	}
+
+	if !isTransportWS {
+		return nil
+	}
+
+	for {
+		msg, err := wsutil.ReadServerText(conn)
+		if err != nil {
+			return err
+		}
+
+		var serverMsg upstreamMsg
+		if err := json.Unmarshal(msg, &serverMsg); err != nil {
+			return err
+		}
+
+		switch serverMsg.Type {
+		case requests.SubConnectionAck:
+			return nil
+		case requests.SubPing:
+			if err := writeUpstreamMsg(conn, requests.ClientSubMsg{Type: requests.SubPong}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func writeUpstreamMsg(conn net.Conn, msg interface{}) error {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	return wsutil.WriteClientText(conn, b)
+}
+
+func (uc *upstreamConn) write(msg interface{}) error {
+	uc.writeMu.Lock()
+	defer uc.writeMu.Unlock()
+
+	return writeUpstreamMsg(uc.conn, msg)
+}
+
+// startMsg returns message, which starts operation with provided id
+func (uc *upstreamConn) startMsg(id string, req *requests.Request) requests.ClientSubMsg {
+	uc.writeMu.Lock()
+	isTransportWS := uc.isTransportWS
+	uc.writeMu.Unlock()
+
+	typ := requests.SubStart
+	if isTransportWS {
+		typ = requests.SubSubscribe
+	}
+
+	return requests.ClientSubMsg{
+		Type:    typ,
+		ID:      id,
+		Payload: req,
+	}
+}
+
+// subscribe starts operation, its results are sent to resCh, nil is sent when operation is finished.
+// Subscriber must call unsubscribe once it isn't interested in results anymore.
+func (uc *upstreamConn) subscribe(req *requests.Request, resCh chan *requests.Response) (*upstreamSub, error) {
+	uc.mu.Lock()
+	if uc.isClosed {
+		uc.mu.Unlock()
+		return nil, errUpstreamConnClosed
+	}
+	uc.lastID++
+	sub := newUpstreamSub(strconv.Itoa(uc.lastID), req, resCh)
+	uc.subs[sub.id] = sub
+	uc.mu.Unlock()
+
+	if err := uc.write(uc.startMsg(sub.id, req)); err != nil {
+		uc.mu.Lock()
+		delete(uc.subs, sub.id)
+		uc.mu.Unlock()
+		sub.leave()
+		return nil, err
+	}
+
+	return sub, nil
+}
+
+// unsubscribe stops operation, if it's still running, no more results are sent to the subscriber
+func (uc *upstreamConn) unsubscribe(sub *upstreamSub) {
+	uc.mu.Lock()
+	isRunning := uc.subs[sub.id] == sub
+	if isRunning {
+		delete(uc.subs, sub.id)
+	}
+	isClosed := uc.isClosed
+	uc.mu.Unlock()
+
+	sub.leave()
+
+	if !isRunning || isClosed {
+		return
+	}
+
+	uc.writeMu.Lock()
+	defer uc.writeMu.Unlock()
+
+	typ := requests.SubStop
+	if uc.isTransportWS {
+		typ = requests.SubComplete
+	}
+	writeUpstreamMsg(uc.conn, requests.ClientSubMsg{Type: typ, ID: sub.id})
+}
+
+// lookup returns running operation by id, must be called with mu held.
+// Some services omit id in messages, they're routed to the operation if it's the only one.
+func (uc *upstreamConn) lookup(id string) (string, *upstreamSub, bool) {
+	if id == "" && len(uc.subs) == 1 {
+		for id, sub := range uc.subs {
+			return id, sub, true
+		}
+	}
+
+	sub, ok := uc.subs[id]
+	return id, sub, ok
+}
+
+// finish removes operation, which was finished by the service, and notifies the subscriber
+func (uc *upstreamConn) finish(id string, resp *requests.Response) {
+	uc.mu.Lock()
+	id, sub, ok := uc.lookup(id)
+	delete(uc.subs, id)
+	uc.mu.Unlock()
+
+	if !ok {
+		return
+	}
+
+	if resp != nil {
+		sub.deliver(resp)
+	}
+	sub.deliver(nil)
+}
+
+// close closes connection, all running operations are finished
+func (uc *upstreamConn) close() {
+	uc.mu.Lock()
+	if uc.isClosed {
+		uc.mu.Unlock()
+		return
+	}
+	uc.isClosed = true
+	close(uc.closeCh)
+	subs := uc.subs
+	uc.subs = make(map[string]*upstreamSub)
+	uc.mu.Unlock()
+
+	uc.writeMu.Lock()
+	uc.conn.Close()
+	uc.writeMu.Unlock()
+
+	if uc.onClose != nil {
+		uc.onClose()
+	}
+
+	for _, sub := range subs {
+		sub.deliver(nil)
+	}
+}
+
+// closed returns true if connection is closed, no operations can be started on it
+func (uc *upstreamConn) closed() bool {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+
+	return uc.isClosed
+}
+
+// read routes messages of the service to subscribers until connection is closed,
+// dropped connection is restored, if reconnect policy is set
+func (uc *upstreamConn) read() {
+	defer uc.close()
+
+	for uc.readMessages() && uc.restore() {
+	}
+}
+
+// readMessages reads messages of current connection, it returns true if connection was dropped
+func (uc *upstreamConn) readMessages() bool {
+	uc.writeMu.Lock()
+	conn := uc.conn
+	uc.writeMu.Unlock()
+
+	for {
+		msg, err := wsutil.ReadServerText(conn)
+		if err != nil {
+			return true
+		}
+
+		var serverMsg upstreamMsg
+		if err := json.Unmarshal(msg, &serverMsg); err != nil {
+			return false
+		}
+
+		switch serverMsg.Type {
+		case requests.SubData, requests.SubNext:
+			var resp requests.Response
+			if err := json.Unmarshal(serverMsg.Payload, &resp); err != nil {
+				uc.finish(serverMsg.ID, &requests.Response{Errors: gqlerrors.FormatError(err)})
+				continue
+			}
+
+			uc.mu.Lock()
+			_, sub, ok := uc.lookup(serverMsg.ID)
+			uc.mu.Unlock()
+			if ok {
+				sub.deliver(&resp)
+			}
+
+		case requests.SubError:
+			uc.finish(serverMsg.ID, &requests.Response{Errors: parseUpstreamErrors(serverMsg.Payload)})
+
+		case requests.SubComplete:
+			uc.finish(serverMsg.ID, nil)
+
+		case requests.SubPing:
+			if err := uc.write(requests.ClientSubMsg{Type: requests.SubPong}); err != nil {
+				return true
+			}
+
+		case requests.SubConnectionError, requests.SubConnectionTerminate:
+			return false
+		}
+	}
+}
+
+// restore reconnects according to the policy and restarts running operations.
+// It returns false if connection can't be restored or it was closed in the meantime.
+func (uc *upstreamConn) restore() bool {
+	if uc.reconnect == nil {
+		return false
+	}
+
+	uc.writeMu.Lock()
+	uc.conn.Close()
+	uc.writeMu.Unlock()
+
+	uc.notify(SubscriptionInterrupted)
+
+	backoff := uc.reconnect.initialBackoff()
+	for attempt := 1; uc.reconnect.MaxAttempts <= 0 || attempt <= uc.reconnect.MaxAttempts; attempt++ {
+		select {
+		case <-time.After(backoff):
+		case <-uc.closeCh:
+			return false
+		}
+
+		backoff *= 2
+		if maxBackoff := uc.reconnect.maxBackoff(); backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+
+		conn, isTransportWS, err := uc.dial()
+		if err != nil {
+			continue
+		}
+
+		uc.mu.Lock()
+		if uc.isClosed {
+			uc.mu.Unlock()
+			conn.Close()
+			return false
+		}
+		uc.writeMu.Lock()
+		uc.conn, uc.isTransportWS = conn, isTransportWS
+		uc.writeMu.Unlock()
+		subs := make(map[string]*requests.Request, len(uc.subs))
+		for id, sub := range uc.subs {
+			subs[id] = sub.req
+		}
+		uc.mu.Unlock()
+
+		restarted := true
+		for id, req := range subs {
+			if err := uc.write(uc.startMsg(id, req)); err != nil {
+				restarted = false
+				break
+			}
+		}
+		if !restarted {
+			conn.Close()
+			continue
+		}
+
+		uc.notify(SubscriptionResumed)
+		return true
+	}
+
+	return false
+}
+
+// notify sends status of the connection to subscribers as extension of empty response
+func (uc *upstreamConn) notify(status string) {
+	if uc.reconnect.Extension == "" {
+		return
+	}
+
+	uc.mu.Lock()
+	subs := make([]*upstreamSub, 0, len(uc.subs))
+	for _, sub := range uc.subs {
+		subs = append(subs, sub)
+	}
+	uc.mu.Unlock()
+
+	for _, sub := range subs {
+		sub.deliver(&requests.Response{
+			Extensions: map[string]interface{}{uc.reconnect.Extension: status},
+		})
+	}
+}
+
+// parseUpstreamErrors parses payload of error message, which is a list of errors,
+// though legacy services may send single error
+func parseUpstreamErrors(payload json.RawMessage) gqlerrors.ErrorList {
+	var errs gqlerrors.ErrorList
+	if err := json.Unmarshal(payload, &errs); err == nil {
+		return errs
+	}
+
+	var singleErr gqlerrors.Error
+	if err := json.Unmarshal(payload, &singleErr); err == nil {
+		return gqlerrors.ErrorList{&singleErr}
+	}
+
+	return gqlerrors.FormatError(errors.New("subscription failed"))
+}
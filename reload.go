@@ -0,0 +1,173 @@
+package pebbles
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/buildbuildio/pebbles/merger"
+	"github.com/buildbuildio/pebbles/planner"
+
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/formatter"
+)
+
+// schemaSnapshot holds merged schema with its TypeURLMap.
+// Snapshot is never modified after creation, on reload it's replaced as a whole,
+// so running requests and subscriptions keep working with the snapshot they started with.
+type schemaSnapshot struct {
+	schema     *ast.Schema
+	typeURLMap merger.TypeURLMap
+	// nodesBatchingURLs contains urls of services supporting nodes query, set only if nodes batching is enabled
+	nodesBatchingURLs map[string]struct{}
+	// fingerprint identifies content of the snapshot, so unchanged schema isn't swapped on reload
+	fingerprint [sha256.Size]byte
+}
+
+// WithSchemaReloadInterval enables polling of remote schemas. On each tick
+// introspection and merging are executed again and on success the schema is replaced.
+func WithSchemaReloadInterval(interval time.Duration) GatewayOption {
+	return func(g *Gateway) {
+		g.reloadInterval = interval
+	}
+}
+
+func (g *Gateway) getSnapshot() *schemaSnapshot {
+	return g.snapshot.Load().(*schemaSnapshot)
+}
+
+// Reload introspects remote schemas, merges them and atomically replaces current schema and TypeURLMap.
+// If any step fails, the error is returned and last good schema stays in place.
+func (g *Gateway) Reload() error {
+	// don't allow concurrent reloads, so the last one always wins
+	g.reloadMutex.Lock()
+	defer g.reloadMutex.Unlock()
+
+	// run introspection query against passed urls
+	schemas, err := g.remoteSchemaIntrospector.IntrospectRemoteSchemas(g.urls...)
+	if err != nil {
+		return fmt.Errorf("unable to introspect remote schemas: %w", err)
+	}
+
+	if len(schemas) != len(g.urls) {
+		return fmt.Errorf("unable to introspect remote schemas: expected %d schemas, got %d", len(g.urls), len(schemas))
+	}
+
+	mergeInputs := make([]*merger.MergeInput, len(schemas))
+	for i := 0; i < len(schemas); i++ {
+		mergeInputs[i] = &merger.MergeInput{
+			Schema: schemas[i],
+			URL:    g.urls[i],
+		}
+	}
+
+	// merge schemas into one
+	mr, err := g.merger.Merge(mergeInputs)
+	if err != nil {
+		return fmt.Errorf("unable to merge schemas: %w", err)
+	}
+
+	addIncrementalDirectives(mr.Schema)
+
+	var nodesBatchingURLs map[string]struct{}
+	if g.isNodesBatchingEnabled {
+		nodesBatchingURLs = make(map[string]struct{})
+		for i, schema := range schemas {
+			if merger.HasNodesField(schema) {
+				nodesBatchingURLs[g.urls[i]] = struct{}{}
+			}
+		}
+	}
+
+	fingerprint, err := snapshotFingerprint(mr.Schema, mr.TypeURLMap, nodesBatchingURLs)
+	if err != nil {
+		return fmt.Errorf("unable to compute schema fingerprint: %w", err)
+	}
+
+	// schema hasn't changed, so current snapshot and cached plans stay in place
+	if current, ok := g.snapshot.Load().(*schemaSnapshot); ok && current.fingerprint == fingerprint {
+		return nil
+	}
+
+	g.snapshot.Store(&schemaSnapshot{
+		schema:            mr.Schema,
+		typeURLMap:        mr.TypeURLMap,
+		nodesBatchingURLs: nodesBatchingURLs,
+		fingerprint:       fingerprint,
+	})
+
+	// plans made for previous schema are not valid anymore
+	if rp, ok := g.planner.(planner.ResettablePlanner); ok {
+		rp.Reset(mr.Schema)
+	}
+
+	return nil
+}
+
+// snapshotFingerprint hashes printed schema along with urls its fields are resolved by
+func snapshotFingerprint(schema *ast.Schema, typeURLMap merger.TypeURLMap, nodesBatchingURLs map[string]struct{}) ([sha256.Size]byte, error) {
+	var buf bytes.Buffer
+	formatter.NewFormatter(&buf).FormatSchema(schema)
+
+	// maps are marshalled with sorted keys, so the same content gives the same fingerprint
+	b, err := json.Marshal([]interface{}{buf.String(), typeURLMap, nodesBatchingURLs})
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+
+	return sha256.Sum256(b), nil
+}
+
+// Stop stops schema polling if it was enabled and closes shared subscription connections
+func (g *Gateway) Stop() {
+	g.reloadMutex.Lock()
+	defer g.reloadMutex.Unlock()
+
+	if g.subscriptionPool != nil {
+		g.subscriptionPool.Close()
+	}
+
+	if g.reloadStopCh == nil {
+		return
+	}
+
+	close(g.reloadStopCh)
+	g.reloadStopCh = nil
+}
+
+// ReloadHandler triggers schema reload on POST request.
+// It responds with 204 on success, otherwise with 500 and error description
+func (g *Gateway) ReloadHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		emitError(w, http.StatusMethodNotAllowed, errors.New("only POST requests are supported"))
+		return
+	}
+
+	if err := g.Reload(); err != nil {
+		emitError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (g *Gateway) pollSchemas(stopCh <-chan struct{}) {
+	timeTicker := time.NewTicker(g.reloadInterval)
+	defer timeTicker.Stop()
+
+	for {
+		select {
+		case <-timeTicker.C:
+			if err := g.Reload(); err != nil {
+				log.Println("Schema reload failed:", err)
+			}
+		case <-stopCh:
+			return
+		}
+	}
+}
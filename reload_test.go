@@ -0,0 +1,191 @@
+package pebbles
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/buildbuildio/pebbles/planner"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+func mustQueryGateway(t *testing.T, gw *Gateway, payload string) map[string]interface{} {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	buf.WriteString(payload)
+
+	r, err := http.NewRequest("POST", "localhost", buf)
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(gw.Handler)(rr, r)
+
+	var res map[string]interface{}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &res))
+
+	return res
+}
+
+func TestGatewayReload(t *testing.T) {
+	mp := &MockPlanner{
+		Res: &planner.QueryPlan{},
+	}
+	me := &MockExecutor{
+		Res: map[string]interface{}{
+			"test": "YES",
+		},
+	}
+
+	oldSchema := gqlparser.MustLoadSchema(&ast.Source{Name: "fixture", Input: `
+		type Query {
+			test: String!
+		}
+	`})
+	newSchema := gqlparser.MustLoadSchema(&ast.Source{Name: "fixture", Input: `
+		type Query {
+			test: String!
+			other: String!
+		}
+	`})
+
+	mi := &MockRemoteSchemaIntrospector{Res: []*ast.Schema{oldSchema}}
+	gw, err := NewGateway([]string{""}, WithExecutor(me), WithPlanner(mp), WithRemoteSchemaIntrospector(mi))
+	require.NoError(t, err)
+
+	res := mustQueryGateway(t, gw, `{"query": "{ other }"}`)
+	assert.NotEmpty(t, res["errors"])
+
+	oldSnapshot := gw.getSnapshot()
+
+	mi.Res = []*ast.Schema{newSchema}
+	require.NoError(t, gw.Reload())
+
+	res = mustQueryGateway(t, gw, `{"query": "{ other }"}`)
+	assert.Empty(t, res["errors"])
+
+	// previous snapshot stays untouched
+	assert.Nil(t, oldSnapshot.schema.Query.Fields.ForName("other"))
+	assert.NotNil(t, gw.getSnapshot().schema.Query.Fields.ForName("other"))
+}
+
+func TestGatewayReloadKeepsLastGoodSchema(t *testing.T) {
+	s := gqlparser.MustLoadSchema(&ast.Source{Name: "fixture", Input: `
+		type Query {
+			test: String!
+		}
+	`})
+
+	mi := &MockRemoteSchemaIntrospector{Res: []*ast.Schema{s}}
+	gw, err := NewGateway([]string{""}, WithRemoteSchemaIntrospector(mi))
+	require.NoError(t, err)
+
+	snapshot := gw.getSnapshot()
+
+	mi.Res = nil
+	assert.Error(t, gw.Reload())
+
+	assert.Same(t, snapshot, gw.getSnapshot())
+}
+
+func TestGatewayReloadSameSchema(t *testing.T) {
+	s := gqlparser.MustLoadSchema(&ast.Source{Name: "fixture", Input: `
+		type Query {
+			test: String!
+		}
+	`})
+
+	mi := &MockRemoteSchemaIntrospector{Res: []*ast.Schema{s}}
+	cp := planner.NewCachedPlanner(time.Hour)
+	gw, err := NewGateway([]string{""}, WithRemoteSchemaIntrospector(mi), WithPlanner(cp))
+	require.NoError(t, err)
+
+	snapshot := gw.getSnapshot()
+
+	// equal schema introspected again doesn't replace snapshot, so cached plans stay valid
+	mi.Res = []*ast.Schema{gqlparser.MustLoadSchema(&ast.Source{Name: "fixture", Input: `
+		type Query {
+			test: String!
+		}
+	`})}
+	require.NoError(t, gw.Reload())
+	assert.Same(t, snapshot, gw.getSnapshot())
+
+	mi.Res = []*ast.Schema{gqlparser.MustLoadSchema(&ast.Source{Name: "fixture", Input: `
+		type Query {
+			test: String!
+			other: String!
+		}
+	`})}
+	require.NoError(t, gw.Reload())
+	assert.NotSame(t, snapshot, gw.getSnapshot())
+}
+
+func TestGatewayReloadHandler(t *testing.T) {
+	s := gqlparser.MustLoadSchema(&ast.Source{Name: "fixture", Input: `
+		type Query {
+			test: String!
+		}
+	`})
+
+	mi := &MockRemoteSchemaIntrospector{Res: []*ast.Schema{s}}
+	gw, err := NewGateway([]string{""}, WithRemoteSchemaIntrospector(mi))
+	require.NoError(t, err)
+
+	for _, c := range []struct {
+		Method string
+		Res    []*ast.Schema
+		Code   int
+	}{
+		{Method: http.MethodGet, Res: []*ast.Schema{s}, Code: http.StatusMethodNotAllowed},
+		{Method: http.MethodPost, Res: []*ast.Schema{s}, Code: http.StatusNoContent},
+		{Method: http.MethodPost, Res: nil, Code: http.StatusInternalServerError},
+	} {
+		mi.Res = c.Res
+
+		r, err := http.NewRequest(c.Method, "localhost", nil)
+		require.NoError(t, err)
+
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(gw.ReloadHandler)(rr, r)
+
+		assert.Equal(t, c.Code, rr.Code)
+	}
+}
+
+func TestGatewayReloadInterval(t *testing.T) {
+	oldSchema := gqlparser.MustLoadSchema(&ast.Source{Name: "fixture", Input: `
+		type Query {
+			test: String!
+		}
+	`})
+	newSchema := gqlparser.MustLoadSchema(&ast.Source{Name: "fixture", Input: `
+		type Query {
+			other: String!
+		}
+	`})
+
+	mi := &MockRemoteSchemaIntrospector{Res: []*ast.Schema{oldSchema}}
+	gw, err := NewGateway(
+		[]string{""},
+		WithRemoteSchemaIntrospector(mi),
+		WithSchemaReloadInterval(time.Millisecond*10),
+	)
+	require.NoError(t, err)
+	defer gw.Stop()
+
+	gw.reloadMutex.Lock()
+	mi.Res = []*ast.Schema{newSchema}
+	gw.reloadMutex.Unlock()
+
+	assert.Eventually(t, func() bool {
+		return gw.getSnapshot().schema.Query.Fields.ForName("other") != nil
+	}, time.Second, time.Millisecond*10)
+}
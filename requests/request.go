@@ -1,6 +1,7 @@
 package requests
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -17,6 +18,47 @@ type Request struct {
 	Query         string                 `json:"query"`
 	Variables     map[string]interface{} `json:"variables"`
 	OperationName *string                `json:"operationName"`
+	Extensions    *Extensions            `json:"extensions,omitempty"`
+	// DocumentID refers to the trusted document, which should be executed
+	DocumentID string `json:"documentId,omitempty"`
+}
+
+// Extensions contains additional request data
+type Extensions struct {
+	PersistedQuery *PersistedQuery `json:"persistedQuery,omitempty"`
+}
+
+// PersistedQuery identifies query by its hash, so query itself could be omitted in request
+type PersistedQuery struct {
+	Version    int    `json:"version"`
+	Sha256Hash string `json:"sha256Hash"`
+}
+
+// GetPersistedQuery returns persisted query data if it's provided
+func (r *Request) GetPersistedQuery() *PersistedQuery {
+	if r.Extensions == nil {
+		return nil
+	}
+
+	return r.Extensions.PersistedQuery
+}
+
+// Context returns context of the original http request or background context if there's none
+func (r *Request) Context() context.Context {
+	if r == nil || r.Original == nil {
+		return context.Background()
+	}
+
+	return r.Original.Context()
+}
+
+// validate checks that request contains query, its persisted query hash or document id
+func (r *Request) validate() error {
+	if r.Query == "" && r.GetPersistedQuery() == nil && r.DocumentID == "" {
+		return errors.New("missing query from request")
+	}
+
+	return nil
 }
 
 type File interface {
@@ -114,8 +156,8 @@ func parseRequest(body []byte) (*ParseRequestResponse, error) {
 		}
 
 		for _, r := range multipleRequests {
-			if r.Query == "" {
-				return nil, errors.New("missing query from request")
+			if err := r.validate(); err != nil {
+				return nil, err
 			}
 		}
 
@@ -131,8 +173,8 @@ func parseRequest(body []byte) (*ParseRequestResponse, error) {
 		return nil, fmt.Errorf("unable to parse given request in single mode: %s", body)
 	}
 
-	if singleRequest.Query == "" {
-		return nil, errors.New("missing query from request")
+	if err := singleRequest.validate(); err != nil {
+		return nil, err
 	}
 
 	return &ParseRequestResponse{
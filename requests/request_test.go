@@ -66,6 +66,22 @@ func TestParseInvalidRequest(t *testing.T) {
 	}
 }
 
+func TestParsePersistedQueryRequest(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	buf.WriteString(`{"extensions": {"persistedQuery": {"version": 1, "sha256Hash": "hash"}}}`)
+
+	r := httptest.NewRequest("POST", "/", buf)
+
+	actual, err := Parse(r)
+	require.NoError(t, err)
+
+	require.NotNil(t, actual.Requests[0].GetPersistedQuery())
+	assert.Equal(t, "hash", actual.Requests[0].GetPersistedQuery().Sha256Hash)
+	assert.Equal(t, 1, actual.Requests[0].GetPersistedQuery().Version)
+	assert.Empty(t, actual.Requests[0].Query)
+}
+
 func TestParseFileSingleRequest(t *testing.T) {
 	buf := &bytes.Buffer{}
 
@@ -5,6 +5,7 @@ import "github.com/buildbuildio/pebbles/gqlerrors"
 type Responses []Response
 
 type Response struct {
-	Errors gqlerrors.ErrorList    `json:"errors"`
-	Data   map[string]interface{} `json:"data"`
+	Errors     gqlerrors.ErrorList    `json:"errors"`
+	Data       map[string]interface{} `json:"data"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
 }
@@ -2,6 +2,15 @@ package requests
 
 import "github.com/buildbuildio/pebbles/gqlerrors"
 
+// Websocket subprotocols
+const (
+	// GraphQLWSProtocol is a legacy subscriptions-transport-ws protocol
+	GraphQLWSProtocol = "graphql-ws"
+	// GraphQLTransportWSProtocol is a graphql-ws protocol, see https://github.com/enisdenjo/graphql-ws/blob/master/PROTOCOL.md
+	GraphQLTransportWSProtocol = "graphql-transport-ws"
+)
+
+// Message types of both protocols
 const (
 	SubConnectionInit      = "connection_init"
 	SubConnectionAck       = "connection_ack"
@@ -15,6 +24,25 @@ const (
 	SubStop                = "stop"
 )
 
+// Message types of graphql-transport-ws protocol, it also uses connection_init, connection_ack, error and complete
+const (
+	SubSubscribe = "subscribe"
+	SubNext      = "next"
+	SubPing      = "ping"
+	SubPong      = "pong"
+)
+
+// Close codes of graphql-transport-ws protocol
+const (
+	CloseInternalServerError     = 4500
+	CloseBadRequest              = 4400
+	CloseUnauthorized            = 4401
+	CloseForbidden               = 4403
+	CloseConnectionInitTimeout   = 4408
+	CloseSubscriberAlreadyExists = 4409
+	CloseTooManyInitRequests     = 4429
+)
+
 // ClientSubMsg defines possible client messages
 type ClientSubMsg struct {
 	ID      string   `json:"id,omitempty"`
@@ -22,6 +50,12 @@ type ClientSubMsg struct {
 	Payload *Request `json:"payload,omitempty"`
 }
 
+// ClientInitMsg defines connection_init message, its payload is an arbitrary object, f.e. with auth token
+type ClientInitMsg struct {
+	Type    string                 `json:"type"`
+	Payload map[string]interface{} `json:"payload,omitempty"`
+}
+
 // ServerSubMsg defines possible server messages
 type ServerSubMsg struct {
 	ID      string    `json:"id,omitempty"`
@@ -0,0 +1,142 @@
+package pebbles
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/buildbuildio/pebbles/queryer"
+)
+
+// DefaultMaxBatchSize is maximum number of operations sent to the service in single request, if it's not configured
+const DefaultMaxBatchSize = 3000
+
+// ServiceConfig configures requests to single service. Zero values mean defaults.
+type ServiceConfig struct {
+	// MaxBatchSize is maximum number of operations sent in single request, DefaultMaxBatchSize by default
+	MaxBatchSize int
+	// BatchMode defines how batch of operations is sent, JSON array by default
+	BatchMode queryer.BatchMode
+	// Timeout of each http request to the service
+	Timeout time.Duration
+	// DialTimeout of subscription websocket, 1 second by default
+	DialTimeout time.Duration
+	// SubscriptionProtocol used to subscribe to the service, negotiated during websocket handshake by default
+	SubscriptionProtocol queryer.SubscriptionProtocol
+	// SubscriptionReconnect restores dropped subscription websockets, by default client subscriptions are finished
+	SubscriptionReconnect *queryer.ReconnectPolicy
+	// HeaderForwarding overrides gateway header forwarding policy for the service
+	HeaderForwarding *queryer.HeaderForwardingPolicy
+	// Headers are set to each request to the service after forwarded ones, f.e. API key
+	Headers http.Header
+	// Middlewares are applied to each request after Headers are set.
+	// Queries to the service with middlewares aren't batched with queries of other client requests.
+	Middlewares []queryer.RequestMiddleware
+	// Client is used to send requests, http.DefaultClient by default. TLS config of its transport is used for subscriptions too.
+	Client *http.Client
+}
+
+// WithServiceConfigs sets configuration of services by their urls. It's used both for introspection and query execution.
+// Custom QueryerFactory or RemoteSchemaIntrospector ignore it.
+func WithServiceConfigs(configs map[string]ServiceConfig) GatewayOption {
+	return func(g *Gateway) {
+		if g.serviceConfigs == nil {
+			g.serviceConfigs = make(map[string]ServiceConfig, len(configs))
+		}
+
+		for url, config := range configs {
+			g.serviceConfigs[url] = config
+		}
+	}
+}
+
+// WithHeaderForwarding sets policy, which defines headers of client request passed to services.
+// It's applied to queries, file uploads and subscriptions. By default no headers are forwarded.
+func WithHeaderForwarding(policy queryer.HeaderForwardingPolicy) GatewayOption {
+	return func(g *Gateway) {
+		g.headerForwarding = &policy
+	}
+}
+
+// WithSubscriptionMultiplexing makes subscriptions to the same service with the same credentials share single websocket.
+// Connection is closed after it has no subscriptions for idleTimeout. Gateway.Stop closes all connections.
+func WithSubscriptionMultiplexing(idleTimeout time.Duration) GatewayOption {
+	return func(g *Gateway) {
+		g.subscriptionPool = queryer.NewSubscriptionPool(idleTimeout)
+	}
+}
+
+// WithQueryBatching makes concurrent requests to the same service with the same headers share single http request.
+// Queries are collected for window or until there are maxBatchSize of them, zero means no limit.
+// Batch is still split according to ServiceConfig.MaxBatchSize. Custom QueryerFactory ignores it.
+// Services with ServiceConfig.Middlewares aren't batched, as middlewares may depend on the client request,
+// f.e. read credentials from its context, while the batch is sent with middlewares of one of the requests.
+func WithQueryBatching(window time.Duration, maxBatchSize int) GatewayOption {
+	return func(g *Gateway) {
+		g.batchDispatcher = queryer.NewBatchDispatcher(window, maxBatchSize)
+	}
+}
+
+// newServiceQueryer returns queryer for the service with provided url, configured with its ServiceConfig.
+// Headers of the original request are forwarded according to the policy, original may be nil.
+func (g *Gateway) newServiceQueryer(ctx context.Context, url string, original *http.Request) *queryer.MultiOpQueryer {
+	config := g.serviceConfigs[url]
+
+	headerForwarding := g.headerForwarding
+	if config.HeaderForwarding != nil {
+		headerForwarding = config.HeaderForwarding
+	}
+
+	maxBatchSize := config.MaxBatchSize
+	if maxBatchSize <= 0 {
+		maxBatchSize = DefaultMaxBatchSize
+	}
+
+	client := config.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	var forwarded http.Header
+	var mdwares []queryer.RequestMiddleware
+	if headerForwarding != nil {
+		forwarded = headerForwarding.Headers(original)
+		mdwares = append(mdwares, queryer.HeadersMiddleware(forwarded))
+	}
+	if len(config.Headers) != 0 {
+		mdwares = append(mdwares, queryer.HeadersMiddleware(config.Headers))
+	}
+	mdwares = append(mdwares, config.Middlewares...)
+
+	// only forwarded headers are compared, when queries are batched, so middlewares must see their own request
+	batchDispatcher := g.batchDispatcher
+	if len(config.Middlewares) != 0 {
+		batchDispatcher = nil
+	}
+
+	return queryer.NewMultiOpQueryer(
+		url, maxBatchSize,
+	).WithHTTPClient(
+		client,
+	).WithContext(
+		ctx,
+	).WithTimeout(
+		config.Timeout,
+	).WithDialTimeout(
+		config.DialTimeout,
+	).WithBatchMode(
+		config.BatchMode,
+	).WithSubscriptionProtocol(
+		config.SubscriptionProtocol,
+	).WithConnectionInitPayload(
+		connectionInitPayloadFromContext(ctx),
+	).WithSubscriptionPool(
+		g.subscriptionPool,
+	).WithSubscriptionReconnect(
+		config.SubscriptionReconnect,
+	).WithBatchDispatcher(
+		batchDispatcher, forwarded,
+	).WithMiddlewares(
+		mdwares,
+	)
+}
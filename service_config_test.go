@@ -0,0 +1,245 @@
+package pebbles
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/buildbuildio/pebbles/queryer"
+	"github.com/buildbuildio/pebbles/requests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+func TestGatewayServiceConfig(t *testing.T) {
+	var mu sync.Mutex
+	var batchSizes []int
+	var apiKeys, custom []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var inputs []*requests.Request
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&inputs))
+
+		mu.Lock()
+		batchSizes = append(batchSizes, len(inputs))
+		apiKeys = append(apiKeys, r.Header.Get("X-Api-Key"))
+		custom = append(custom, r.Header.Get("X-Custom"))
+		mu.Unlock()
+
+		resps := make([]map[string]interface{}, len(inputs))
+		for i := range resps {
+			resps[i] = map[string]interface{}{"data": map[string]interface{}{"test": "YES"}}
+		}
+		json.NewEncoder(w).Encode(resps)
+	}))
+	defer server.Close()
+
+	gw := &Gateway{}
+	WithServiceConfigs(map[string]ServiceConfig{
+		server.URL: {
+			MaxBatchSize: 1,
+			Headers:      http.Header{"X-Api-Key": []string{"secret"}},
+			Middlewares: []queryer.RequestMiddleware{func(r *http.Request) error {
+				r.Header.Set("X-Custom", r.Header.Get("X-Api-Key")+"!")
+				return nil
+			}},
+		},
+	})(gw)
+
+	q := gw.newServiceQueryer(context.Background(), server.URL, nil)
+	res, err := q.Query([]*requests.Request{{Query: "{ test }"}, {Query: "{ test }"}})
+	require.NoError(t, err)
+	assert.Len(t, res, 2)
+
+	assert.Equal(t, []int{1, 1}, batchSizes)
+	assert.Equal(t, []string{"secret", "secret"}, apiKeys)
+	assert.Equal(t, []string{"secret!", "secret!"}, custom)
+}
+
+func TestGatewayServiceConfigTimeout(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer server.Close()
+	defer close(done)
+
+	gw := &Gateway{}
+	WithServiceConfigs(map[string]ServiceConfig{
+		server.URL: {Timeout: 10 * time.Millisecond},
+	})(gw)
+
+	_, err := gw.newServiceQueryer(context.Background(), server.URL, nil).Query([]*requests.Request{{Query: "{ test }"}})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestGatewayServiceConfigIntrospection(t *testing.T) {
+	var apiKey string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiKey = r.Header.Get("X-Api-Key")
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	_, err := NewGateway([]string{server.URL}, WithServiceConfigs(map[string]ServiceConfig{
+		server.URL: {Headers: http.Header{"X-Api-Key": []string{"secret"}}},
+	}))
+	assert.Error(t, err)
+	assert.Equal(t, "secret", apiKey)
+}
+
+func TestGatewayHeaderForwarding(t *testing.T) {
+	var mu sync.Mutex
+	headers := make(map[string]http.Header)
+	newServer := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			headers[name] = r.Header.Clone()
+			mu.Unlock()
+
+			w.Write([]byte(`[{"data": {"` + name + `": "YES"}}]`))
+		}))
+	}
+
+	a, b := newServer("a"), newServer("b")
+	defer a.Close()
+	defer b.Close()
+
+	schemaA := gqlparser.MustLoadSchema(&ast.Source{Name: "a", Input: `type Query { a: String! }`})
+	schemaB := gqlparser.MustLoadSchema(&ast.Source{Name: "b", Input: `type Query { b: String! }`})
+
+	gw, err := NewGateway(
+		[]string{a.URL, b.URL},
+		WithRemoteSchemaIntrospector(&MockRemoteSchemaIntrospector{Res: []*ast.Schema{schemaA, schemaB}}),
+		WithHeaderForwarding(queryer.HeaderForwardingPolicy{Allow: []string{"Authorization"}}),
+		WithServiceConfigs(map[string]ServiceConfig{
+			b.URL: {HeaderForwarding: &queryer.HeaderForwardingPolicy{
+				Rename: map[string]string{"Authorization": "X-Client-Authorization"},
+			}},
+		}),
+	)
+	require.NoError(t, err)
+
+	request := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"query": "{ a b }"}`))
+	request.Header.Set("Authorization", "Bearer token")
+	request.Header.Set("Cookie", "secret")
+	w := httptest.NewRecorder()
+	gw.Handler(w, request)
+
+	var res map[string]interface{}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&res))
+	assert.Equal(t, map[string]interface{}{"a": "YES", "b": "YES"}, res["data"])
+
+	assert.Equal(t, "Bearer token", headers["a"].Get("Authorization"))
+	assert.Empty(t, headers["a"].Get("Cookie"))
+	assert.Equal(t, "application/json", headers["a"].Get("Content-Type"))
+
+	assert.Empty(t, headers["b"].Get("Authorization"))
+	assert.Equal(t, "Bearer token", headers["b"].Get("X-Client-Authorization"))
+}
+
+func TestGatewayQueryBatching(t *testing.T) {
+	var mu sync.Mutex
+	var batchSizes []int
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var inputs []*requests.Request
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&inputs))
+
+		mu.Lock()
+		batchSizes = append(batchSizes, len(inputs))
+		mu.Unlock()
+
+		resps := make([]map[string]interface{}, len(inputs))
+		for i := range resps {
+			resps[i] = map[string]interface{}{"data": map[string]interface{}{"token": r.Header.Get("Authorization")}}
+		}
+		json.NewEncoder(w).Encode(resps)
+	}))
+	defer server.Close()
+
+	gw := &Gateway{}
+	WithHeaderForwarding(queryer.HeaderForwardingPolicy{Allow: []string{"Authorization"}})(gw)
+	WithQueryBatching(50*time.Millisecond, 0)(gw)
+
+	// queryers of different client requests share batch, when their forwarded headers are the same
+	var wg sync.WaitGroup
+	tokens := make([]string, 3)
+	for i, token := range []string{"a", "a", "b"} {
+		original := httptest.NewRequest(http.MethodPost, "/", nil)
+		original.Header.Set("Authorization", token)
+
+		wg.Add(1)
+		go func(i int, q queryer.Queryer) {
+			defer wg.Done()
+			res, err := q.Query([]*requests.Request{{Query: "{ token }"}})
+			require.NoError(t, err)
+			tokens[i] = res[0]["token"].(string)
+		}(i, gw.newServiceQueryer(context.Background(), server.URL, original))
+	}
+	wg.Wait()
+
+	assert.Equal(t, []string{"a", "a", "b"}, tokens)
+	assert.ElementsMatch(t, []int{2, 1}, batchSizes)
+}
+
+func TestServiceQueryerBatchingWithMiddlewares(t *testing.T) {
+	var mu sync.Mutex
+	var batchSizes []int
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var inputs []*requests.Request
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&inputs))
+
+		mu.Lock()
+		batchSizes = append(batchSizes, len(inputs))
+		mu.Unlock()
+
+		resps := make([]map[string]interface{}, len(inputs))
+		for i := range resps {
+			resps[i] = map[string]interface{}{"data": map[string]interface{}{"token": r.Header.Get("Authorization")}}
+		}
+		json.NewEncoder(w).Encode(resps)
+	}))
+	defer server.Close()
+
+	type tokenKey struct{}
+
+	gw := &Gateway{}
+	WithQueryBatching(50*time.Millisecond, 0)(gw)
+	WithServiceConfigs(map[string]ServiceConfig{
+		server.URL: {
+			// credentials are taken from the context of the client request
+			Middlewares: []queryer.RequestMiddleware{func(r *http.Request) error {
+				r.Header.Set("Authorization", r.Context().Value(tokenKey{}).(string))
+				return nil
+			}},
+		},
+	})(gw)
+
+	// queries aren't batched, so middlewares of each client request are applied to its own queries
+	var wg sync.WaitGroup
+	tokens := make([]string, 2)
+	for i, token := range []string{"a", "b"} {
+		ctx := context.WithValue(context.Background(), tokenKey{}, token)
+
+		wg.Add(1)
+		go func(i int, q queryer.Queryer) {
+			defer wg.Done()
+			res, err := q.Query([]*requests.Request{{Query: "{ token }"}})
+			require.NoError(t, err)
+			tokens[i] = res[0]["token"].(string)
+		}(i, gw.newServiceQueryer(ctx, server.URL, nil))
+	}
+	wg.Wait()
+
+	assert.Equal(t, []string{"a", "b"}, tokens)
+	assert.Equal(t, []int{1, 1}, batchSizes)
+}
@@ -3,19 +3,24 @@ package pebbles
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"net"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/buildbuildio/pebbles/gqlerrors"
 	"github.com/buildbuildio/pebbles/planner"
 	"github.com/buildbuildio/pebbles/requests"
 	"github.com/gobwas/ws"
 	"github.com/gobwas/ws/wsutil"
-	"github.com/vektah/gqlparser/v2"
 	"github.com/vektah/gqlparser/v2/ast"
 )
 
+const defaultConnectionInitTimeout = 10 * time.Second
+
 type subscriptionDict map[string]*subscriptionEntry
 
 func (sd subscriptionDict) Clean(key string) {
@@ -31,18 +36,104 @@ func (sd subscriptionDict) CleanAll() {
 	}
 }
 
-func sendHeartbeat(ctx context.Context, conn net.Conn) error {
-	timeTicker := time.NewTicker(time.Second * 4)
-	defer timeTicker.Stop()
+// subscriptionWriter sends subscription events to the client, it's implemented by each transport
+type subscriptionWriter interface {
+	sendData(id string, resp *requests.Response) error
+	sendComplete(id string) error
+	sendHeartbeat() error
+}
+
+// subscriptionConn writes messages of negotiated protocol to the client.
+// Writes are serialized, as they're done from handler, heartbeat and each subscription.
+type subscriptionConn struct {
+	conn     net.Conn
+	protocol string
 
-	bMsg, err := json.Marshal(requests.ServerSubMsg{Type: requests.SubConnectionKeepAlive})
+	sync.Mutex
+}
+
+func (sc *subscriptionConn) isTransportWS() bool {
+	return sc.protocol == requests.GraphQLTransportWSProtocol
+}
+
+func (sc *subscriptionConn) write(msg interface{}) error {
+	bMsg, err := json.Marshal(msg)
 	if err != nil {
 		return err
 	}
+
+	sc.Lock()
+	defer sc.Unlock()
+
+	return wsutil.WriteServerText(sc.conn, bMsg)
+}
+
+func (sc *subscriptionConn) sendType(typ string) error {
+	return sc.write(requests.ServerSubMsg{Type: typ})
+}
+
+func (sc *subscriptionConn) sendData(id string, resp *requests.Response) error {
+	typ := requests.SubData
+	if sc.isTransportWS() {
+		typ = requests.SubNext
+	}
+
+	return sc.write(requests.ServerSubMsg{
+		ID:      id,
+		Type:    typ,
+		Payload: resp,
+	})
+}
+
+func (sc *subscriptionConn) sendError(id string, errs gqlerrors.ErrorList) error {
+	return sc.write(requests.ServerSubErorrMsg{
+		ID:      id,
+		Type:    requests.SubError,
+		Payload: errs,
+	})
+}
+
+func (sc *subscriptionConn) sendConnectionError(err error) error {
+	return sc.write(requests.ServerSubErorrMsg{
+		Type:    requests.SubConnectionError,
+		Payload: gqlerrors.FormatError(err),
+	})
+}
+
+func (sc *subscriptionConn) sendComplete(id string) error {
+	return sc.write(requests.ServerSubMsg{
+		ID:   id,
+		Type: requests.SubComplete,
+	})
+}
+
+// close gracefully closes connection with provided status
+func (sc *subscriptionConn) close(code ws.StatusCode, reason string) {
+	sc.Lock()
+	defer sc.Unlock()
+
+	body := ws.NewCloseFrameBody(code, reason)
+	frame := ws.NewCloseFrame(body)
+	if err := ws.WriteHeader(sc.conn, frame.Header); err == nil {
+		sc.conn.Write(body)
+	}
+
+	sc.conn.Close()
+}
+
+func (sc *subscriptionConn) sendHeartbeat() error {
+	return sc.sendType(requests.SubConnectionKeepAlive)
+}
+
+// sendHeartbeat periodically sends heartbeat to the client to keep connection open
+func sendHeartbeat(ctx context.Context, sw subscriptionWriter) error {
+	timeTicker := time.NewTicker(time.Second * 4)
+	defer timeTicker.Stop()
+
 	for {
 		select {
 		case <-timeTicker.C:
-			if err := wsutil.WriteServerText(conn, bMsg); err != nil {
+			if err := sw.sendHeartbeat(); err != nil {
 				return err
 			}
 		case <-ctx.Done():
@@ -51,44 +142,58 @@ func sendHeartbeat(ctx context.Context, conn net.Conn) error {
 	}
 }
 
+// WithConnectionInitTimeout sets time in which graphql-transport-ws client must send connection_init, 10 seconds by default
+func WithConnectionInitTimeout(timeout time.Duration) GatewayOption {
+	return func(g *Gateway) {
+		g.connectionInitTimeout = timeout
+	}
+}
+
 func (g *Gateway) subscriptionHandler(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	upgrader := ws.HTTPUpgrader{
 		Timeout: time.Second * 60,
 		Protocol: func(subprotocol string) bool {
-			return subprotocol == "graphql-ws"
+			return subprotocol == requests.GraphQLWSProtocol || subprotocol == requests.GraphQLTransportWSProtocol
 		},
 	}
 
-	conn, _, _, err := upgrader.Upgrade(r, w)
+	conn, _, hs, err := upgrader.Upgrade(r, w)
 	if err != nil {
 		return
 	}
 
+	g.metrics.ConnectionOpened()
+	defer g.metrics.ConnectionClosed()
+
+	// clients, which didn't choose protocol, are treated as legacy ones
+	sc := &subscriptionConn{conn: conn, protocol: hs.Protocol}
+	if sc.protocol == "" {
+		sc.protocol = requests.GraphQLWSProtocol
+	}
+
 	subDict := make(subscriptionDict)
 
+	closeCode, closeReason := ws.StatusNormalClosure, ""
 	defer func() {
 		defer func() {
 			recover()
 		}()
-		// gracefully close connection
-		body := ws.NewCloseFrameBody(ws.StatusNormalClosure, "")
-		frame := ws.NewCloseFrame(body)
-		if err := ws.WriteHeader(conn, frame.Header); err != nil {
-			return
-		}
-		if _, err := conn.Write(body); err != nil {
-			return
-		}
 
-		// close conn
-		conn.Close()
+		// gracefully close connection
+		sc.close(closeCode, closeReason)
 
 		// close all running handlers
 		subDict.CleanAll()
 	}()
 
+	if sc.isTransportWS() {
+		g.handleTransportWS(ctx, sc, r, subDict, &closeCode, &closeReason)
+		return
+	}
+
+	isInitialized := false
 	for {
 		msg, err := wsutil.ReadClientText(conn)
 		if err != nil {
@@ -103,56 +208,36 @@ func (g *Gateway) subscriptionHandler(w http.ResponseWriter, r *http.Request) {
 		switch subMsg.Type {
 		// When the GraphQL WS connection is initiated, send an ACK back
 		case requests.SubConnectionInit:
-			resp := requests.ServerSubMsg{
-				Type: requests.SubConnectionAck,
-			}
-			bresp, err := json.Marshal(resp)
+			initRequest, err := g.initConnection(r, msg)
 			if err != nil {
+				sc.sendConnectionError(err)
 				return
 			}
-			if err := wsutil.WriteServerText(conn, bresp); err != nil {
+			r, isInitialized = initRequest, true
+
+			if err := sc.sendType(requests.SubConnectionAck); err != nil {
 				return
 			}
 			// start sending heartbeat
-			go sendHeartbeat(ctx, conn)
+			go sendHeartbeat(ctx, sc)
 
-		// Let event handlers deal with starting operations
+		// Let event handlers deal with starting operations,
+		// failed operation is reported to the client, other ones keep running
 		case requests.SubStart:
-			request := subMsg.Payload
-			request.Original = r
-
-			query, qerr := gqlparser.LoadQuery(g.schema, request.Query)
-			if qerr != nil {
-				return
-			}
-
-			var operation *ast.OperationDefinition
-			if request.OperationName != nil {
-				operation = query.Operations.ForName(*request.OperationName)
-			} else if len(query.Operations) == 1 {
-				operation = query.Operations[0]
-			}
-
-			if operation == nil {
-				return
+			// connection_init payload must be validated before any operation is started
+			if g.connectionInitHandler != nil && !isInitialized {
+				if err := sc.sendError(subMsg.ID, gqlerrors.FormatError(errors.New("connection is not initialised"))); err != nil {
+					return
+				}
+				continue
 			}
 
-			planningContext := &planner.PlanningContext{
-				Request:    request,
-				Operation:  operation,
-				Schema:     g.schema,
-				TypeURLMap: g.typeURLMap,
-			}
-
-			subEntry, err := g.newSubscriptionEntry(subMsg.ID, planningContext)
-			if err != nil {
-				return
+			if err := g.startSubscription(sc, r, subMsg, subDict); err != nil {
+				if err := sc.sendError(subMsg.ID, gqlerrors.FormatError(err)); err != nil {
+					return
+				}
 			}
 
-			subDict[subMsg.ID] = subEntry
-
-			go subEntry.Listen(conn)
-
 		// Stop running operations
 		case requests.SubStop:
 			subDict.Clean(subMsg.ID)
@@ -172,3 +257,171 @@ func (g *Gateway) subscriptionHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 }
+
+// handleTransportWS handles messages of graphql-transport-ws protocol until connection is closed.
+// Protocol violations close the connection with corresponding close code.
+func (g *Gateway) handleTransportWS(ctx context.Context, sc *subscriptionConn, r *http.Request, subDict subscriptionDict, closeCode *ws.StatusCode, closeReason *string) {
+	setClose := func(code ws.StatusCode, reason string) {
+		*closeCode, *closeReason = code, reason
+	}
+
+	// 0 - waiting for connection_init, 1 - acknowledged
+	var isAcknowledged int32
+
+	timeout := g.connectionInitTimeout
+	if timeout <= 0 {
+		timeout = defaultConnectionInitTimeout
+	}
+	initTimer := time.AfterFunc(timeout, func() {
+		if atomic.LoadInt32(&isAcknowledged) == 0 {
+			sc.close(requests.CloseConnectionInitTimeout, "Connection initialisation timeout")
+		}
+	})
+	defer initTimer.Stop()
+
+	for {
+		msg, err := wsutil.ReadClientText(sc.conn)
+		if err != nil {
+			return
+		}
+
+		var subMsg requests.ClientSubMsg
+		if err := json.Unmarshal(msg, &subMsg); err != nil {
+			setClose(requests.CloseBadRequest, "Invalid message received")
+			return
+		}
+
+		switch subMsg.Type {
+		case requests.SubConnectionInit:
+			if !atomic.CompareAndSwapInt32(&isAcknowledged, 0, 1) {
+				setClose(requests.CloseTooManyInitRequests, "Too many initialisation requests")
+				return
+			}
+			initTimer.Stop()
+
+			initRequest, err := g.initConnection(r, msg)
+			if err != nil {
+				setClose(requests.CloseForbidden, "Forbidden")
+				return
+			}
+			r = initRequest
+
+			if err := sc.sendType(requests.SubConnectionAck); err != nil {
+				return
+			}
+
+		case requests.SubPing:
+			if err := sc.sendType(requests.SubPong); err != nil {
+				return
+			}
+
+		case requests.SubPong:
+
+		case requests.SubSubscribe:
+			if atomic.LoadInt32(&isAcknowledged) == 0 {
+				setClose(requests.CloseUnauthorized, "Unauthorized")
+				return
+			}
+
+			if subMsg.ID == "" || subMsg.Payload == nil {
+				setClose(requests.CloseBadRequest, "Invalid message received")
+				return
+			}
+
+			if subEntry, ok := subDict[subMsg.ID]; ok && !subEntry.IsClosed() {
+				setClose(requests.CloseSubscriberAlreadyExists, "Subscriber for "+subMsg.ID+" already exists")
+				return
+			}
+
+			if err := g.startSubscription(sc, r, subMsg, subDict); err != nil {
+				if err := sc.sendError(subMsg.ID, gqlerrors.FormatError(err)); err != nil {
+					return
+				}
+			}
+
+		case requests.SubComplete:
+			subDict.Clean(subMsg.ID)
+
+		default:
+			setClose(requests.CloseBadRequest, "Invalid message received")
+			return
+		}
+	}
+}
+
+// startSubscription plans subscription and starts listening to its events
+func (g *Gateway) startSubscription(sc *subscriptionConn, r *http.Request, subMsg requests.ClientSubMsg, subDict subscriptionDict) error {
+	request := subMsg.Payload
+	if request == nil {
+		return errors.New("subscription payload is missing")
+	}
+	request.Original = r
+
+	snapshot, query, err := g.loadSubscriptionQuery(request)
+	if err != nil {
+		return err
+	}
+
+	subEntry, err := g.newSubscription(subMsg.ID, request, snapshot, query)
+	if err != nil {
+		return err
+	}
+
+	subDict[subMsg.ID] = subEntry
+
+	g.metrics.SubscriptionStarted()
+	go func() {
+		defer g.metrics.SubscriptionFinished()
+		subEntry.Listen(sc)
+	}()
+
+	return nil
+}
+
+// loadSubscriptionQuery resolves persisted query of the request and parses it with schema of current snapshot.
+// The same snapshot must be used to plan the operation.
+func (g *Gateway) loadSubscriptionQuery(request *requests.Request) (*schemaSnapshot, *ast.QueryDocument, error) {
+	if err := g.resolvePersistedQuery(request); err != nil {
+		return nil, nil, err
+	}
+
+	snapshot := g.getSnapshot()
+
+	query, err := g.loadQuery(snapshot.schema, request)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return snapshot, query, nil
+}
+
+// newSubscription plans subscription operation of the query loaded by loadSubscriptionQuery and subscribes to the service.
+// It's shared by all transports, so they're handled the same way.
+func (g *Gateway) newSubscription(id string, request *requests.Request, snapshot *schemaSnapshot, query *ast.QueryDocument) (*subscriptionEntry, error) {
+	planningContext := &planner.PlanningContext{
+		Request:    request,
+		Schema:     snapshot.schema,
+		TypeURLMap: snapshot.typeURLMap,
+	}
+
+	if err := g.hooks.onParse(planningContext, query); err != nil {
+		return nil, err
+	}
+
+	operation, operationErr := selectOperation(query, request)
+	if operationErr != nil {
+		return nil, operationErr
+	}
+
+	planningContext.Operation = operation
+
+	if err := g.hooks.onOperation(planningContext); err != nil {
+		return nil, err
+	}
+
+	if errs := g.checkLimits(operation, request); len(errs) != 0 {
+		return nil, errs
+	}
+
+	return g.newSubscriptionEntry(id, planningContext, snapshot.nodesBatchingURLs)
+}
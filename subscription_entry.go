@@ -1,16 +1,13 @@
 package pebbles
 
 import (
-	"encoding/json"
 	"errors"
-	"net"
 	"sync"
 
 	"github.com/buildbuildio/pebbles/executor"
 	"github.com/buildbuildio/pebbles/gqlerrors"
 	"github.com/buildbuildio/pebbles/planner"
 	"github.com/buildbuildio/pebbles/requests"
-	"github.com/gobwas/ws/wsutil"
 )
 
 type subscriptionEntry struct {
@@ -28,7 +25,7 @@ type subscriptionEntry struct {
 	sync.Mutex
 }
 
-func (g *Gateway) newSubscriptionEntry(id string, ctx *planner.PlanningContext) (*subscriptionEntry, error) {
+func (g *Gateway) newSubscriptionEntry(id string, ctx *planner.PlanningContext, nodesBatchingURLs map[string]struct{}) (*subscriptionEntry, error) {
 	subEntry := &subscriptionEntry{
 		id:             id,
 		closeCh:        make(chan struct{}),
@@ -42,6 +39,10 @@ func (g *Gateway) newSubscriptionEntry(id string, ctx *planner.PlanningContext)
 		return nil, err
 	}
 
+	if err := g.hooks.onPlan(ctx, plan); err != nil {
+		return nil, err
+	}
+
 	subEntry.originalPlan = plan
 
 	additionalRootSteps := make([]*planner.QueryPlanStep, 0)
@@ -98,9 +99,10 @@ func (g *Gateway) newSubscriptionEntry(id string, ctx *planner.PlanningContext)
 					RootSteps:   newRootSteps,
 					ScrubFields: plan.ScrubFields,
 				},
-				Request:       ctx.Request,
-				Queryers:      additionalQueryers,
-				InitialResult: initialResult,
+				Request:           ctx.Request,
+				Queryers:          additionalQueryers,
+				InitialResult:     initialResult,
+				NodesBatchingURLs: nodesBatchingURLs,
 			})
 
 			plan.ScrubFields.Clean(result)
@@ -135,14 +137,22 @@ func (se *subscriptionEntry) Close() {
 	se.closeCh <- struct{}{}
 }
 
-func (se *subscriptionEntry) Listen(conn net.Conn) {
+// IsClosed returns true if subscription is finished
+func (se *subscriptionEntry) IsClosed() bool {
+	se.Lock()
+	defer se.Unlock()
+
+	return se.isClosed
+}
+
+func (se *subscriptionEntry) Listen(sw subscriptionWriter) {
 	defer func() {
 		se.queryerCloseCh <- struct{}{}
 		se.Lock()
 		defer se.Unlock()
 		close(se.queryerCloseCh)
 		close(se.closeCh)
-		close(se.respCh)
+		// respCh isn't closed, queryer may still be sending to it until it handles queryerCloseCh
 		se.isClosed = true
 	}()
 
@@ -150,18 +160,16 @@ func (se *subscriptionEntry) Listen(conn net.Conn) {
 		select {
 		case resp := <-se.respCh:
 			if resp == nil {
+				// client is notified that stream has ended, after that it may reuse the id,
+				// so entry is marked as closed beforehand
+				se.Lock()
+				se.isClosed = true
+				se.Unlock()
+				sw.sendComplete(se.id)
 				return
 			}
 			resp = se.prepareResponse(resp)
-			bResp, err := json.Marshal(requests.ServerSubMsg{
-				ID:      se.id,
-				Type:    requests.SubData,
-				Payload: resp,
-			})
-			if err != nil {
-				return
-			}
-			if err := wsutil.WriteServerText(conn, bResp); err != nil {
+			if err := sw.sendData(se.id, resp); err != nil {
 				return
 			}
 		case <-se.closeCh:
@@ -0,0 +1,222 @@
+package pebbles
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strings"
+	"sync"
+
+	"github.com/buildbuildio/pebbles/gqlerrors"
+	"github.com/buildbuildio/pebbles/requests"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// Content types of subscriptions over http
+const (
+	sseContentType       = "text/event-stream"
+	multipartContentType = "multipart/mixed"
+	multipartBoundary    = "graphql"
+)
+
+// sseWriter sends events using GraphQL over Server-Sent Events protocol in distinct connections mode,
+// see https://github.com/enisdenjo/graphql-sse/blob/master/PROTOCOL.md
+type sseWriter struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+
+	sync.Mutex
+}
+
+func newSSEWriter(w http.ResponseWriter, flusher http.Flusher) *sseWriter {
+	w.Header().Set("Content-Type", sseContentType)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	return &sseWriter{w: w, flusher: flusher}
+}
+
+func (sw *sseWriter) write(s string) error {
+	sw.Lock()
+	defer sw.Unlock()
+
+	if _, err := sw.w.Write([]byte(s)); err != nil {
+		return err
+	}
+	sw.flusher.Flush()
+	return nil
+}
+
+func (sw *sseWriter) sendData(_ string, resp *requests.Response) error {
+	bResp, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+
+	return sw.write(fmt.Sprintf("event: next\ndata: %s\n\n", bResp))
+}
+
+func (sw *sseWriter) sendComplete(_ string) error {
+	return sw.write("event: complete\ndata:\n\n")
+}
+
+func (sw *sseWriter) sendHeartbeat() error {
+	// comments are ignored by clients
+	return sw.write(":\n\n")
+}
+
+var errMultipartWriterClosed = errors.New("multipart response is closed")
+
+// multipartWriter sends events as parts of multipart/mixed response, following Apollo multipart subscriptions protocol,
+// see https://www.apollographql.com/docs/router/executing-operations/subscription-multipart-protocol
+type multipartWriter struct {
+	mw      *multipart.Writer
+	flusher http.Flusher
+	// isClosed is set once the closing boundary is written, nothing can be written after it
+	isClosed bool
+
+	sync.Mutex
+}
+
+func newMultipartWriter(w http.ResponseWriter, flusher http.Flusher) *multipartWriter {
+	mw := multipart.NewWriter(w)
+	mw.SetBoundary(multipartBoundary)
+
+	w.Header().Set("Content-Type", fmt.Sprintf(`%s; boundary="%s"; subscriptionSpec=1.0`, multipartContentType, multipartBoundary))
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	return &multipartWriter{mw: mw, flusher: flusher}
+}
+
+func (mw *multipartWriter) writePart(v interface{}) error {
+	mw.Lock()
+	defer mw.Unlock()
+
+	if mw.isClosed {
+		return errMultipartWriterClosed
+	}
+
+	part, err := mw.mw.CreatePart(textproto.MIMEHeader{"Content-Type": []string{"application/json"}})
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(part).Encode(v); err != nil {
+		return err
+	}
+	mw.flusher.Flush()
+	return nil
+}
+
+func (mw *multipartWriter) sendData(_ string, resp *requests.Response) error {
+	return mw.writePart(map[string]interface{}{"payload": resp})
+}
+
+func (mw *multipartWriter) sendComplete(_ string) error {
+	mw.Lock()
+	defer mw.Unlock()
+
+	if mw.isClosed {
+		return nil
+	}
+	mw.isClosed = true
+
+	if err := mw.mw.Close(); err != nil {
+		return err
+	}
+	mw.flusher.Flush()
+	return nil
+}
+
+func (mw *multipartWriter) sendHeartbeat() error {
+	return mw.writePart(struct{}{})
+}
+
+// isStreamRequest returns true if client expects subscription events over http
+func isStreamRequest(r *http.Request) bool {
+	accept := r.Header.Get("Accept")
+	return strings.Contains(accept, sseContentType) ||
+		(strings.Contains(accept, multipartContentType) && strings.Contains(accept, "subscriptionSpec"))
+}
+
+// streamHandler serves subscription over Server-Sent Events or multipart http response, chosen by Accept header.
+// Other operations are executed as usual and sent as single event.
+func (g *Gateway) streamHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		emitError(w, http.StatusInternalServerError, errors.New("streaming is not supported"))
+		return
+	}
+
+	rs, err := requests.Parse(r)
+	if err != nil {
+		emitError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	if rs.IsBatchMode {
+		emitError(w, http.StatusUnprocessableEntity, errors.New("batch requests can't be streamed"))
+		return
+	}
+
+	request := rs.Requests[0]
+
+	var sw subscriptionWriter
+	if strings.Contains(r.Header.Get("Accept"), sseContentType) {
+		sw = newSSEWriter(w, flusher)
+	} else {
+		sw = newMultipartWriter(w, flusher)
+	}
+
+	// invalid requests are executed as other operations, so their errors are returned by regular execution
+	snapshot, query, err := g.loadSubscriptionQuery(request)
+	if err != nil || !isSubscription(query, request) {
+		result := g.executeRequest(request)
+		sw.sendData("", &requests.Response{Errors: result.Errors, Data: result.Data})
+		sw.sendComplete("")
+		return
+	}
+
+	subEntry, err := g.newSubscription("", request, snapshot, query)
+	if err != nil {
+		sw.sendData("", &requests.Response{Errors: gqlerrors.FormatError(err)})
+		sw.sendComplete("")
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+
+	heartbeatDone := make(chan struct{})
+	go func() {
+		defer close(heartbeatDone)
+		sendHeartbeat(ctx, sw)
+	}()
+
+	// response mustn't be written after handler returns
+	defer func() {
+		cancel()
+		<-heartbeatDone
+	}()
+
+	// stop subscription when client goes away
+	go func() {
+		<-ctx.Done()
+		subEntry.Close()
+	}()
+
+	g.metrics.SubscriptionStarted()
+	defer g.metrics.SubscriptionFinished()
+
+	subEntry.Listen(sw)
+}
+
+// isSubscription returns true if subscription operation of the query is requested
+func isSubscription(query *ast.QueryDocument, request *requests.Request) bool {
+	operation, err := selectOperation(query, request)
+	return err == nil && operation.Operation == ast.Subscription
+}
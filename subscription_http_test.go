@@ -0,0 +1,142 @@
+package pebbles
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/buildbuildio/pebbles/requests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func postStream(t *testing.T, url, accept, query string) *http.Response {
+	body, err := json.Marshal(requests.Request{Query: query})
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", accept)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	return resp
+}
+
+// readSSEEvent reads next event, skipping comments
+func readSSEEvent(t *testing.T, r *bufio.Reader) (string, map[string]interface{}) {
+	var event string
+	var data map[string]interface{}
+	for {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimSuffix(line, "\n")
+
+		switch {
+		case line == "" && event != "":
+			return event, data
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &data))
+		}
+	}
+}
+
+func TestGatewaySubscriptionSSE(t *testing.T) {
+	mq := mockStreamQueryer{MockQueryer{ResCh: make(chan *requests.Response)}}
+	server := newSubscriptionTestServer(t, mq)
+	defer server.Close()
+
+	resp := postStream(t, server.URL, "text/event-stream", "subscription { test }")
+	defer resp.Body.Close()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	r := bufio.NewReader(resp.Body)
+
+	mq.ResCh <- &requests.Response{Data: map[string]interface{}{"test": "YES"}}
+	event, data := readSSEEvent(t, r)
+	assert.Equal(t, "next", event)
+	assert.Equal(t, map[string]interface{}{"test": "YES"}, data["data"])
+
+	close(mq.ResCh)
+	event, _ = readSSEEvent(t, r)
+	assert.Equal(t, "complete", event)
+}
+
+func TestGatewaySubscriptionSSEError(t *testing.T) {
+	server := newSubscriptionTestServer(t, MockQueryer{})
+	defer server.Close()
+
+	resp := postStream(t, server.URL, "text/event-stream", "subscription { unknown }")
+	defer resp.Body.Close()
+
+	r := bufio.NewReader(resp.Body)
+
+	event, data := readSSEEvent(t, r)
+	assert.Equal(t, "next", event)
+	assert.Nil(t, data["data"])
+	assert.NotEmpty(t, data["errors"])
+
+	event, _ = readSSEEvent(t, r)
+	assert.Equal(t, "complete", event)
+}
+
+func TestGatewaySubscriptionMultipart(t *testing.T) {
+	mq := mockStreamQueryer{MockQueryer{ResCh: make(chan *requests.Response)}}
+	server := newSubscriptionTestServer(t, mq)
+	defer server.Close()
+
+	resp := postStream(t, server.URL, `multipart/mixed;boundary="graphql";subscriptionSpec=1.0,application/json`, "subscription { test }")
+	defer resp.Body.Close()
+
+	mediaType, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	assert.Equal(t, "multipart/mixed", mediaType)
+	assert.Equal(t, "1.0", params["subscriptionspec"])
+
+	mr := multipart.NewReader(resp.Body, params["boundary"])
+
+	readPart := func() map[string]interface{} {
+		for {
+			part, err := mr.NextPart()
+			require.NoError(t, err)
+
+			var body map[string]interface{}
+			require.NoError(t, json.NewDecoder(part).Decode(&body))
+			// skip heartbeats
+			if len(body) != 0 {
+				return body
+			}
+		}
+	}
+
+	mq.ResCh <- &requests.Response{Data: map[string]interface{}{"test": "YES"}}
+	assert.Equal(t, map[string]interface{}{"test": "YES"}, readPart()["payload"].(map[string]interface{})["data"])
+
+	close(mq.ResCh)
+	_, err = mr.NextPart()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestMultipartWriterClosed(t *testing.T) {
+	w := httptest.NewRecorder()
+	mw := newMultipartWriter(w, w)
+
+	require.NoError(t, mw.sendComplete(""))
+	body := w.Body.String()
+
+	// heartbeat may race with completion, it's dropped once response is closed
+	assert.ErrorIs(t, mw.sendHeartbeat(), errMultipartWriterClosed)
+	assert.NoError(t, mw.sendComplete(""))
+	assert.Equal(t, body, w.Body.String())
+}
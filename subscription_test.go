@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -391,3 +392,144 @@ func TestGatewaySubscriptionUnsupportedMessage(t *testing.T) {
 	_, _, err = wsutil.ReadServerData(conn)
 	require.Error(t, err)
 }
+
+func TestGatewaySubscriptionErrorsKeepConnection(t *testing.T) {
+	mq := mockStreamQueryer{MockQueryer{ResCh: make(chan *requests.Response)}}
+	server := newSubscriptionTestServer(t, mq)
+	defer server.Close()
+
+	conn, _ := dialSubscriptionServer(t, server, requests.GraphQLWSProtocol)
+	defer conn.Close()
+
+	writeClientMsg(t, conn, requests.ClientSubMsg{Type: requests.SubConnectionInit})
+	assert.Equal(t, requests.SubConnectionAck, readServerMsg(t, conn)["type"])
+
+	// invalid query
+	writeClientMsg(t, conn, requests.ClientSubMsg{
+		ID:      "1",
+		Type:    requests.SubStart,
+		Payload: &requests.Request{Query: "subscription { unknown }"},
+	})
+	msg := readServerMsg(t, conn)
+	assert.Equal(t, "1", msg["id"])
+	assert.Equal(t, requests.SubError, msg["type"])
+	require.Len(t, msg["payload"], 1)
+	assert.NotEmpty(t, msg["payload"].([]interface{})[0].(map[string]interface{})["message"])
+
+	// missing operation
+	operationName := "B"
+	writeClientMsg(t, conn, requests.ClientSubMsg{
+		ID:      "2",
+		Type:    requests.SubStart,
+		Payload: &requests.Request{Query: "subscription A { test }", OperationName: &operationName},
+	})
+	msg = readServerMsg(t, conn)
+	assert.Equal(t, "2", msg["id"])
+	assert.Equal(t, requests.SubError, msg["type"])
+
+	// missing payload
+	writeClientMsg(t, conn, requests.ClientSubMsg{ID: "3", Type: requests.SubStart})
+	msg = readServerMsg(t, conn)
+	assert.Equal(t, "3", msg["id"])
+	assert.Equal(t, requests.SubError, msg["type"])
+
+	// connection is still usable
+	writeClientMsg(t, conn, requests.ClientSubMsg{
+		ID:      "4",
+		Type:    requests.SubStart,
+		Payload: &requests.Request{Query: "subscription { test }"},
+	})
+
+	mq.ResCh <- &requests.Response{Data: map[string]interface{}{"test": "YES"}}
+	msg = readServerMsg(t, conn)
+	assert.Equal(t, "4", msg["id"])
+	assert.Equal(t, requests.SubData, msg["type"])
+
+	// stream ended upstream
+	close(mq.ResCh)
+	assert.Equal(t, map[string]interface{}{
+		"id":   "4",
+		"type": requests.SubComplete,
+	}, readServerMsg(t, conn))
+}
+
+func TestGatewaySubscriptionMultiplexing(t *testing.T) {
+	var connections int32
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, _, _, err := ws.HTTPUpgrader{
+			Protocol: func(subprotocol string) bool {
+				return subprotocol == requests.GraphQLWSProtocol
+			},
+		}.Upgrade(r, w)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		atomic.AddInt32(&connections, 1)
+
+		for {
+			msg, err := wsutil.ReadClientText(conn)
+			if err != nil {
+				return
+			}
+
+			var subMsg requests.ClientSubMsg
+			require.NoError(t, json.Unmarshal(msg, &subMsg))
+
+			if subMsg.Type == requests.SubStart {
+				b, _ := json.Marshal(requests.ServerSubMsg{
+					ID:      subMsg.ID,
+					Type:    requests.SubData,
+					Payload: &requests.Response{Data: map[string]interface{}{"test": subMsg.ID}},
+				})
+				wsutil.WriteServerText(conn, b)
+			}
+		}
+	}))
+	defer upstream.Close()
+
+	schema := gqlparser.MustLoadSchema(&ast.Source{Name: "fixture", Input: `
+		type Query {
+			test: String!
+		}
+
+		type Subscription {
+			test: String!
+		}
+	`})
+
+	gw, err := NewGateway(
+		[]string{upstream.URL},
+		WithRemoteSchemaIntrospector(&MockRemoteSchemaIntrospector{Res: []*ast.Schema{schema}}),
+		WithSubscriptionMultiplexing(time.Minute),
+	)
+	require.NoError(t, err)
+	defer gw.Stop()
+
+	server := httptest.NewServer(http.HandlerFunc(gw.Handler))
+	defer server.Close()
+
+	var ids []interface{}
+	for i := 0; i < 2; i++ {
+		conn, _ := dialSubscriptionServer(t, server, requests.GraphQLTransportWSProtocol)
+		defer conn.Close()
+
+		writeClientMsg(t, conn, requests.ClientSubMsg{Type: requests.SubConnectionInit})
+		assert.Equal(t, requests.SubConnectionAck, readServerMsg(t, conn)["type"])
+
+		writeClientMsg(t, conn, requests.ClientSubMsg{
+			ID:      "1",
+			Type:    requests.SubSubscribe,
+			Payload: &requests.Request{Query: "subscription { test }"},
+		})
+
+		msg := readServerMsg(t, conn)
+		assert.Equal(t, requests.SubNext, msg["type"])
+		ids = append(ids, msg["payload"].(map[string]interface{})["data"].(map[string]interface{})["test"])
+	}
+
+	// both subscriptions are run over the same connection with different operation ids
+	assert.EqualValues(t, 1, atomic.LoadInt32(&connections))
+	assert.Equal(t, []interface{}{"1", "2"}, ids)
+}
@@ -0,0 +1,227 @@
+package pebbles
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/buildbuildio/pebbles/planner"
+	"github.com/buildbuildio/pebbles/queryer"
+	"github.com/buildbuildio/pebbles/requests"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// mockStreamQueryer forwards responses from ResCh until it's closed, then finishes the stream
+type mockStreamQueryer struct {
+	MockQueryer
+}
+
+func (m mockStreamQueryer) Subscribe(_ *requests.Request, closeCh <-chan struct{}, resCh chan *requests.Response) error {
+	go func() {
+		<-closeCh
+	}()
+
+	go func() {
+		for res := range m.ResCh {
+			resCh <- res
+		}
+		resCh <- nil
+	}()
+
+	return nil
+}
+
+func newSubscriptionTestServer(t *testing.T, q queryer.Queryer, options ...GatewayOption) *httptest.Server {
+	schema := gqlparser.MustLoadSchema(&ast.Source{Name: "fixture", Input: `
+		type Subscription {
+			test: String!
+		}
+	`})
+
+	mp := &MockPlanner{
+		Res: &planner.QueryPlan{
+			RootSteps: []*planner.QueryPlanStep{{
+				URL:          "0",
+				ParentType:   "Subscription",
+				SelectionSet: ast.SelectionSet{&ast.Field{Name: "test"}},
+			}},
+		},
+	}
+
+	options = append(
+		options,
+		WithRemoteSchemaIntrospector(&MockRemoteSchemaIntrospector{Res: []*ast.Schema{schema}}),
+		WithPlanner(mp),
+		WithQueryerFactory(func(pc *planner.PlanningContext, s string) queryer.Queryer {
+			return q
+		}),
+	)
+
+	gw, err := NewGateway([]string{""}, options...)
+	require.NoError(t, err)
+
+	return httptest.NewServer(http.HandlerFunc(gw.Handler))
+}
+
+func dialSubscriptionServer(t *testing.T, server *httptest.Server, protocol string) (net.Conn, string) {
+	dialer := ws.Dialer{
+		Timeout:   time.Second,
+		Protocols: []string{protocol},
+	}
+
+	conn, _, hs, err := dialer.Dial(context.Background(), strings.Replace(server.URL, "http", "ws", 1))
+	require.NoError(t, err)
+
+	return conn, hs.Protocol
+}
+
+func writeClientMsg(t *testing.T, conn net.Conn, msg requests.ClientSubMsg) {
+	b, err := json.Marshal(msg)
+	require.NoError(t, err)
+	require.NoError(t, wsutil.WriteClientText(conn, b))
+}
+
+func readServerMsg(t *testing.T, conn net.Conn) map[string]interface{} {
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	b, err := wsutil.ReadServerText(conn)
+	require.NoError(t, err)
+
+	var msg map[string]interface{}
+	require.NoError(t, json.Unmarshal(b, &msg))
+	return msg
+}
+
+func readCloseCode(t *testing.T, conn net.Conn) ws.StatusCode {
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, err := wsutil.ReadServerText(conn)
+
+	closedErr, ok := err.(wsutil.ClosedError)
+	require.True(t, ok, "unexpected error: %v", err)
+	return closedErr.Code
+}
+
+func TestGatewaySubscriptionTransportWS(t *testing.T) {
+	mq := mockStreamQueryer{MockQueryer{ResCh: make(chan *requests.Response)}}
+	server := newSubscriptionTestServer(t, mq)
+	defer server.Close()
+
+	conn, protocol := dialSubscriptionServer(t, server, requests.GraphQLTransportWSProtocol)
+	defer conn.Close()
+	assert.Equal(t, requests.GraphQLTransportWSProtocol, protocol)
+
+	writeClientMsg(t, conn, requests.ClientSubMsg{Type: requests.SubConnectionInit})
+	assert.Equal(t, requests.SubConnectionAck, readServerMsg(t, conn)["type"])
+
+	writeClientMsg(t, conn, requests.ClientSubMsg{Type: requests.SubPing})
+	assert.Equal(t, requests.SubPong, readServerMsg(t, conn)["type"])
+
+	writeClientMsg(t, conn, requests.ClientSubMsg{
+		ID:      "1",
+		Type:    requests.SubSubscribe,
+		Payload: &requests.Request{Query: "subscription { test }"},
+	})
+
+	mq.ResCh <- &requests.Response{Data: map[string]interface{}{"test": "YES"}}
+	msg := readServerMsg(t, conn)
+	assert.Equal(t, "1", msg["id"])
+	assert.Equal(t, requests.SubNext, msg["type"])
+	assert.Equal(t, map[string]interface{}{"test": "YES"}, msg["payload"].(map[string]interface{})["data"])
+
+	close(mq.ResCh)
+	assert.Equal(t, map[string]interface{}{
+		"id":   "1",
+		"type": requests.SubComplete,
+	}, readServerMsg(t, conn))
+
+	// invalid query is reported with error message, connection is kept
+	writeClientMsg(t, conn, requests.ClientSubMsg{
+		ID:      "2",
+		Type:    requests.SubSubscribe,
+		Payload: &requests.Request{Query: "subscription { unknown }"},
+	})
+	msg = readServerMsg(t, conn)
+	assert.Equal(t, requests.SubError, msg["type"])
+	assert.Equal(t, "2", msg["id"])
+	assert.NotEmpty(t, msg["payload"])
+
+	writeClientMsg(t, conn, requests.ClientSubMsg{Type: requests.SubPing})
+	assert.Equal(t, requests.SubPong, readServerMsg(t, conn)["type"])
+}
+
+func TestGatewaySubscriptionTransportWSCloseCodes(t *testing.T) {
+	mq := mockStreamQueryer{MockQueryer{ResCh: make(chan *requests.Response)}}
+	server := newSubscriptionTestServer(t, mq, WithConnectionInitTimeout(50*time.Millisecond))
+	defer server.Close()
+
+	subscribe := requests.ClientSubMsg{
+		ID:      "1",
+		Type:    requests.SubSubscribe,
+		Payload: &requests.Request{Query: "subscription { test }"},
+	}
+
+	t.Run("init timeout", func(t *testing.T) {
+		conn, _ := dialSubscriptionServer(t, server, requests.GraphQLTransportWSProtocol)
+		defer conn.Close()
+
+		assert.EqualValues(t, requests.CloseConnectionInitTimeout, readCloseCode(t, conn))
+	})
+
+	t.Run("subscribe before init", func(t *testing.T) {
+		conn, _ := dialSubscriptionServer(t, server, requests.GraphQLTransportWSProtocol)
+		defer conn.Close()
+
+		writeClientMsg(t, conn, subscribe)
+		assert.EqualValues(t, requests.CloseUnauthorized, readCloseCode(t, conn))
+	})
+
+	t.Run("too many init requests", func(t *testing.T) {
+		conn, _ := dialSubscriptionServer(t, server, requests.GraphQLTransportWSProtocol)
+		defer conn.Close()
+
+		writeClientMsg(t, conn, requests.ClientSubMsg{Type: requests.SubConnectionInit})
+		readServerMsg(t, conn)
+		writeClientMsg(t, conn, requests.ClientSubMsg{Type: requests.SubConnectionInit})
+		assert.EqualValues(t, requests.CloseTooManyInitRequests, readCloseCode(t, conn))
+	})
+
+	t.Run("subscriber already exists", func(t *testing.T) {
+		conn, _ := dialSubscriptionServer(t, server, requests.GraphQLTransportWSProtocol)
+		defer conn.Close()
+
+		writeClientMsg(t, conn, requests.ClientSubMsg{Type: requests.SubConnectionInit})
+		readServerMsg(t, conn)
+		writeClientMsg(t, conn, subscribe)
+		writeClientMsg(t, conn, subscribe)
+		assert.EqualValues(t, requests.CloseSubscriberAlreadyExists, readCloseCode(t, conn))
+	})
+
+	t.Run("invalid message", func(t *testing.T) {
+		conn, _ := dialSubscriptionServer(t, server, requests.GraphQLTransportWSProtocol)
+		defer conn.Close()
+
+		writeClientMsg(t, conn, requests.ClientSubMsg{Type: requests.SubStart})
+		assert.EqualValues(t, requests.CloseBadRequest, readCloseCode(t, conn))
+	})
+}
+
+func TestGatewaySubscriptionProtocolNegotiation(t *testing.T) {
+	server := newSubscriptionTestServer(t, MockQueryer{})
+	defer server.Close()
+
+	conn, protocol := dialSubscriptionServer(t, server, requests.GraphQLWSProtocol)
+	defer conn.Close()
+	assert.Equal(t, requests.GraphQLWSProtocol, protocol)
+
+	writeClientMsg(t, conn, requests.ClientSubMsg{Type: requests.SubConnectionInit})
+	assert.Equal(t, requests.SubConnectionAck, readServerMsg(t, conn)["type"])
+}
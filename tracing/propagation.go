@@ -0,0 +1,79 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// TraceparentHeader is a W3C Trace Context header, f.e.
+// traceparent: 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+const TraceparentHeader = "traceparent"
+
+const sampledFlag = 0x01
+
+// Inject sets traceparent header from the span context of ctx, see ParentFromContext.
+// Header is left as is, if there's no valid span context.
+func Inject(ctx context.Context, header http.Header) {
+	sc := ParentFromContext(ctx)
+	if !sc.IsValid() {
+		return
+	}
+
+	var flags byte
+	if sc.Sampled {
+		flags = sampledFlag
+	}
+
+	header.Set(TraceparentHeader, fmt.Sprintf("00-%s-%s-%02x", sc.TraceID, sc.SpanID, flags))
+}
+
+// Extract returns context with remote span context from traceparent header.
+// If header is missing or malformed, ctx is returned as is.
+func Extract(ctx context.Context, header http.Header) context.Context {
+	sc, ok := ParseTraceparent(header.Get(TraceparentHeader))
+	if !ok {
+		return ctx
+	}
+
+	return ContextWithRemoteSpanContext(ctx, sc)
+}
+
+// ParseTraceparent parses value of traceparent header
+func ParseTraceparent(value string) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 {
+		return SpanContext{}, false
+	}
+
+	version, err := hex.DecodeString(parts[0])
+	// version ff is forbidden, version 00 has exactly 4 parts
+	if err != nil || len(version) != 1 || version[0] == 0xff || (version[0] == 0 && len(parts) != 4) {
+		return SpanContext{}, false
+	}
+
+	var sc SpanContext
+	if !decodeHex(parts[1], sc.TraceID[:]) || !decodeHex(parts[2], sc.SpanID[:]) || !sc.IsValid() {
+		return SpanContext{}, false
+	}
+
+	var flags [1]byte
+	if !decodeHex(parts[3], flags[:]) {
+		return SpanContext{}, false
+	}
+	sc.Sampled = flags[0]&sampledFlag != 0
+
+	return sc, true
+}
+
+func decodeHex(s string, dst []byte) bool {
+	// only lowercase is allowed by the spec
+	if len(s) != hex.EncodedLen(len(dst)) || strings.ToLower(s) != s {
+		return false
+	}
+
+	_, err := hex.Decode(dst, []byte(s))
+	return err == nil
+}
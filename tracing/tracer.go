@@ -0,0 +1,154 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"sync"
+	"time"
+)
+
+// SpanData is a snapshot of ended span
+type SpanData struct {
+	Name        string
+	SpanContext SpanContext
+	// Parent is invalid for root spans
+	Parent     SpanContext
+	Attributes map[string]interface{}
+	Err        error
+	StartTime  time.Time
+	EndTime    time.Time
+}
+
+// Exporter receives spans once they are ended
+type Exporter interface {
+	Export(span *SpanData)
+}
+
+// BasicTracer generates ids for spans and passes them to exporter once they are ended
+type BasicTracer struct {
+	exporter Exporter
+}
+
+var _ Tracer = &BasicTracer{}
+
+// NewTracer returns tracer, which exports ended spans with provided exporter
+func NewTracer(exporter Exporter) *BasicTracer {
+	return &BasicTracer{exporter: exporter}
+}
+
+func (t *BasicTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	parent := ParentFromContext(ctx)
+
+	sc := SpanContext{
+		TraceID: parent.TraceID,
+		Sampled: true,
+	}
+	if parent.IsValid() {
+		sc.Sampled = parent.Sampled
+	} else {
+		rand.Read(sc.TraceID[:])
+	}
+	rand.Read(sc.SpanID[:])
+
+	span := &basicSpan{
+		tracer: t,
+		data: SpanData{
+			Name:        name,
+			SpanContext: sc,
+			Parent:      parent,
+			Attributes:  make(map[string]interface{}),
+			StartTime:   time.Now(),
+		},
+	}
+
+	return ContextWithSpan(ctx, span), span
+}
+
+type basicSpan struct {
+	tracer  *BasicTracer
+	data    SpanData
+	isEnded bool
+
+	sync.Mutex
+}
+
+func (s *basicSpan) SpanContext() SpanContext {
+	return s.data.SpanContext
+}
+
+func (s *basicSpan) SetAttributes(attrs ...Attribute) {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, attr := range attrs {
+		s.data.Attributes[attr.Key] = attr.Value
+	}
+}
+
+func (s *basicSpan) RecordError(err error) {
+	if err == nil {
+		return
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	s.data.Err = err
+}
+
+func (s *basicSpan) End() {
+	s.Lock()
+	if s.isEnded {
+		s.Unlock()
+		return
+	}
+	s.isEnded = true
+	s.data.EndTime = time.Now()
+
+	data := s.data
+	data.Attributes = make(map[string]interface{}, len(s.data.Attributes))
+	for k, v := range s.data.Attributes {
+		data.Attributes[k] = v
+	}
+	s.Unlock()
+
+	if data.SpanContext.Sampled && s.tracer.exporter != nil {
+		s.tracer.exporter.Export(&data)
+	}
+}
+
+// InMemoryExporter keeps exported spans in memory. It's meant for tests.
+type InMemoryExporter struct {
+	spans []*SpanData
+
+	sync.Mutex
+}
+
+var _ Exporter = &InMemoryExporter{}
+
+func NewInMemoryExporter() *InMemoryExporter {
+	return &InMemoryExporter{}
+}
+
+func (e *InMemoryExporter) Export(span *SpanData) {
+	e.Lock()
+	defer e.Unlock()
+
+	e.spans = append(e.spans, span)
+}
+
+// Spans returns exported spans in order they were ended
+func (e *InMemoryExporter) Spans() []*SpanData {
+	e.Lock()
+	defer e.Unlock()
+
+	return append([]*SpanData{}, e.spans...)
+}
+
+// Reset drops all exported spans
+func (e *InMemoryExporter) Reset() {
+	e.Lock()
+	defer e.Unlock()
+
+	e.spans = nil
+}
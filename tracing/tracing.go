@@ -0,0 +1,132 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+)
+
+// TraceID identifies the whole trace
+type TraceID [16]byte
+
+// SpanID identifies single span of the trace
+type SpanID [8]byte
+
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+// SpanContext is a part of the span, which is propagated to other services
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+// IsValid returns true if both trace and span ids are set
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != TraceID{} && sc.SpanID != SpanID{}
+}
+
+// Attribute is a key-value pair describing the span
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+func String(key, value string) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+func Int(key string, value int) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+func Bool(key string, value bool) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Span is a single timed operation
+type Span interface {
+	SpanContext() SpanContext
+	SetAttributes(attrs ...Attribute)
+	// RecordError marks span as failed
+	RecordError(err error)
+	End()
+}
+
+// Tracer starts spans. Parent of the new span is taken from ctx, see ParentFromContext.
+// Returned context contains started span.
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+type tracerKey struct{}
+type spanKey struct{}
+type remoteSpanContextKey struct{}
+
+// ContextWithTracer returns context, which spans are started with provided tracer
+func ContextWithTracer(ctx context.Context, tracer Tracer) context.Context {
+	return context.WithValue(ctx, tracerKey{}, tracer)
+}
+
+// TracerFromContext returns tracer of the context or NoopTracer if there's none
+func TracerFromContext(ctx context.Context) Tracer {
+	if tracer, ok := ctx.Value(tracerKey{}).(Tracer); ok {
+		return tracer
+	}
+
+	return NoopTracer{}
+}
+
+// ContextWithSpan returns context with provided span as current one
+func ContextWithSpan(ctx context.Context, span Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// SpanFromContext returns current span of the context or noop span if there's none
+func SpanFromContext(ctx context.Context) Span {
+	if span, ok := ctx.Value(spanKey{}).(Span); ok {
+		return span
+	}
+
+	return noopSpan{}
+}
+
+// ContextWithRemoteSpanContext returns context with span context received from another service
+func ContextWithRemoteSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, remoteSpanContextKey{}, sc)
+}
+
+// ParentFromContext returns span context, which should be used as parent of new span:
+// span context of current span or, if there's none, the one received from another service
+func ParentFromContext(ctx context.Context) SpanContext {
+	if sc := SpanFromContext(ctx).SpanContext(); sc.IsValid() {
+		return sc
+	}
+
+	sc, _ := ctx.Value(remoteSpanContextKey{}).(SpanContext)
+	return sc
+}
+
+// Start starts span with the tracer of the context
+func Start(ctx context.Context, name string) (context.Context, Span) {
+	return TracerFromContext(ctx).Start(ctx, name)
+}
+
+// NoopTracer starts spans, which record nothing
+type NoopTracer struct{}
+
+func (NoopTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SpanContext() SpanContext   { return SpanContext{} }
+func (noopSpan) SetAttributes(...Attribute) {}
+func (noopSpan) RecordError(error)          {}
+func (noopSpan) End()                       {}
@@ -0,0 +1,91 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTracerParentChild(t *testing.T) {
+	exporter := NewInMemoryExporter()
+	ctx := ContextWithTracer(context.Background(), NewTracer(exporter))
+
+	ctx, root := Start(ctx, "root")
+	_, child := Start(ctx, "child")
+	child.SetAttributes(String("a", "b"), Int("c", 1))
+	child.RecordError(errors.New("failed"))
+	child.End()
+	root.End()
+	// ending span twice exports it once
+	root.End()
+
+	spans := exporter.Spans()
+	require.Len(t, spans, 2)
+
+	assert.Equal(t, "child", spans[0].Name)
+	assert.Equal(t, map[string]interface{}{"a": "b", "c": 1}, spans[0].Attributes)
+	assert.EqualError(t, spans[0].Err, "failed")
+	assert.Equal(t, root.SpanContext(), spans[0].Parent)
+	assert.Equal(t, root.SpanContext().TraceID, spans[0].SpanContext.TraceID)
+
+	assert.Equal(t, "root", spans[1].Name)
+	assert.False(t, spans[1].Parent.IsValid())
+	assert.True(t, spans[1].SpanContext.IsValid())
+
+	exporter.Reset()
+	assert.Empty(t, exporter.Spans())
+}
+
+func TestNoopTracer(t *testing.T) {
+	ctx, span := Start(context.Background(), "root")
+	span.End()
+
+	assert.False(t, span.SpanContext().IsValid())
+	assert.False(t, SpanFromContext(ctx).SpanContext().IsValid())
+}
+
+func TestParseTraceparent(t *testing.T) {
+	sc, ok := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	require.True(t, ok)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID.String())
+	assert.Equal(t, "00f067aa0ba902b7", sc.SpanID.String())
+	assert.True(t, sc.Sampled)
+
+	for _, value := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-ff",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e47-00f067aa0ba902b7-01",
+	} {
+		_, ok := ParseTraceparent(value)
+		assert.False(t, ok, value)
+	}
+}
+
+func TestInjectExtract(t *testing.T) {
+	header := http.Header{}
+	header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+
+	exporter := NewInMemoryExporter()
+	ctx := ContextWithTracer(Extract(context.Background(), header), NewTracer(exporter))
+
+	// before any span is started incoming span context is propagated as is
+	out := http.Header{}
+	Inject(ctx, out)
+	assert.Equal(t, header.Get(TraceparentHeader), out.Get(TraceparentHeader))
+
+	ctx, span := Start(ctx, "root")
+	Inject(ctx, out)
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-"+span.SpanContext().SpanID.String()+"-00", out.Get(TraceparentHeader))
+
+	// not sampled spans aren't exported
+	span.End()
+	assert.Empty(t, exporter.Spans())
+}
@@ -0,0 +1,136 @@
+package pebbles
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/buildbuildio/pebbles/executor"
+	"github.com/buildbuildio/pebbles/planner"
+	"github.com/buildbuildio/pebbles/queryer"
+	"github.com/buildbuildio/pebbles/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+func TestGatewayTracing(t *testing.T) {
+	var traceparents []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparents = append(traceparents, r.Header.Get(tracing.TraceparentHeader))
+		w.Write([]byte(`[{"data": {"test": "YES"}}]`))
+	}))
+	defer server.Close()
+
+	schema := gqlparser.MustLoadSchema(&ast.Source{Name: "fixture", Input: `
+		type Query {
+			test: String!
+		}
+	`})
+
+	exporter := tracing.NewInMemoryExporter()
+	gw, err := NewGateway(
+		[]string{server.URL},
+		WithRemoteSchemaIntrospector(&MockRemoteSchemaIntrospector{Res: []*ast.Schema{schema}}),
+		WithPlanner(planner.NewCachedPlanner(time.Minute)),
+		WithTracer(tracing.NewTracer(exporter)),
+		// traceparent of the client is forwarded, though it's replaced by the downstream span
+		WithHeaderForwarding(queryer.HeaderForwardingPolicy{AllowPrefixes: []string{"Trace"}}),
+	)
+	require.NoError(t, err)
+
+	query := func() {
+		request := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"query": "query Q { test }"}`))
+		request.Header.Set(tracing.TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		w := httptest.NewRecorder()
+		gw.Handler(w, request)
+
+		var res map[string]interface{}
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&res))
+		assert.Equal(t, map[string]interface{}{"test": "YES"}, res["data"])
+	}
+
+	query()
+
+	spans := make(map[string]*tracing.SpanData)
+	for _, span := range exporter.Spans() {
+		spans[span.Name] = span
+	}
+
+	request := spans["graphql.request"]
+	require.NotNil(t, request)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", request.SpanContext.TraceID.String())
+	assert.Equal(t, "00f067aa0ba902b7", request.Parent.SpanID.String())
+	assert.Equal(t, "Q", request.Attributes["graphql.operation.name"])
+	assert.Equal(t, "query", request.Attributes["graphql.operation.type"])
+
+	for _, name := range []string{"graphql.parse", "graphql.plan", "graphql.depth", "graphql.scrub"} {
+		require.Contains(t, spans, name)
+		assert.Equal(t, request.SpanContext, spans[name].Parent, name)
+	}
+
+	assert.Equal(t, false, spans["graphql.plan"].Attributes[planner.CacheHitAttribute])
+	assert.Equal(t, 0, spans["graphql.depth"].Attributes["graphql.depth"])
+
+	downstream := spans["graphql.downstream"]
+	require.NotNil(t, downstream)
+	// downstream request is nested under the depth it's executed in
+	assert.Equal(t, spans["graphql.depth"].SpanContext, downstream.Parent)
+	assert.Equal(t, server.URL, downstream.Attributes["http.url"])
+	assert.Equal(t, 1, downstream.Attributes["graphql.batch_size"])
+	assert.Equal(t, http.StatusOK, downstream.Attributes["http.status_code"])
+
+	require.Len(t, traceparents, 1)
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-"+downstream.SpanContext.SpanID.String()+"-01", traceparents[0])
+
+	exporter.Reset()
+	query()
+
+	for _, span := range exporter.Spans() {
+		if span.Name == "graphql.plan" {
+			assert.Equal(t, true, span.Attributes[planner.CacheHitAttribute])
+		}
+	}
+}
+
+func TestGatewayTracingPipelined(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"data": {"test": "YES"}}]`))
+	}))
+	defer server.Close()
+
+	schema := gqlparser.MustLoadSchema(&ast.Source{Name: "fixture", Input: `
+		type Query {
+			test: String!
+		}
+	`})
+
+	exporter := tracing.NewInMemoryExporter()
+	gw, err := NewGateway(
+		[]string{server.URL},
+		WithRemoteSchemaIntrospector(&MockRemoteSchemaIntrospector{Res: []*ast.Schema{schema}}),
+		WithExecutor(executor.PipelinedExecutor{}),
+		WithTracer(tracing.NewTracer(exporter)),
+	)
+	require.NoError(t, err)
+
+	request := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"query": "{ test }"}`))
+	w := httptest.NewRecorder()
+	gw.Handler(w, request)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	spans := make(map[string]*tracing.SpanData)
+	for _, span := range exporter.Spans() {
+		spans[span.Name] = span
+	}
+
+	require.Contains(t, spans, "graphql.request")
+	require.Contains(t, spans, "graphql.batch")
+	require.Contains(t, spans, "graphql.downstream")
+	assert.Equal(t, spans["graphql.request"].SpanContext, spans["graphql.batch"].Parent)
+	assert.Equal(t, spans["graphql.batch"].SpanContext, spans["graphql.downstream"].Parent)
+}